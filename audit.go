@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	generated "code/db/generated"
+	"code/pagination"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// действия со ссылками, которые попадают в журнал
+const (
+	auditCreate   = "create"
+	auditUpdate   = "update"
+	auditDelete   = "delete"
+	auditRestore  = "restore"
+	auditRollback = "rollback"
+)
+
+// заголовок с именем автора изменения. API не проверяет личность клиента,
+// поэтому имя — заявленное клиентом и в ответах называется claimed_actor
+const actorHeader = "X-Actor"
+
+// максимальная длина имени автора изменения
+const maxActorLength = 128
+
+// поля ссылки, изменения которых сохраняются в журнале
+var auditFields = []string{"original_url", "short_name", "short_url", "title", "folder_id", "tags", "deleted"}
+
+// состояние ссылки для журнала: значения полей, NULL хранится как nil
+type linkSnapshot map[string]any
+
+// снимок состояния ссылки
+func newLinkSnapshot(originalUrl string, shortName pgtype.Text, shortUrl pgtype.Text, title pgtype.Text, folderID pgtype.Int8, tags []string, deleted bool) linkSnapshot {
+	return linkSnapshot{
+		"original_url": originalUrl,
+		"short_name":   textValue(shortName),
+		"short_url":    textValue(shortUrl),
+		"title":        textValue(title),
+		"folder_id":    int8Value(folderID),
+		"tags":         tagsValue(tags),
+		"deleted":      deleted,
+	}
+}
+
+// снимок состояния неудалённой ссылки
+func snapshotFromRow(link generated.GetLinkRow) linkSnapshot {
+	return newLinkSnapshot(link.OriginalUrl, link.ShortName, link.ShortUrl, link.Title, link.FolderID, link.Tags, false)
+}
+
+// значение текстового поля, nil для NULL
+func textValue(t pgtype.Text) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.String
+}
+
+// значение числового поля, nil для NULL
+func int8Value(i pgtype.Int8) any {
+	if !i.Valid {
+		return nil
+	}
+	return i.Int64
+}
+
+// метки одной строкой через запятую, чтобы снимки можно было сравнить. nil без меток
+func tagsValue(tags []string) any {
+	if len(tags) == 0 {
+		return nil
+	}
+	return strings.Join(tags, ",")
+}
+
+// значение поля до и после изменения
+type auditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// изменённые поля ссылки. before равен nil при создании ссылки
+func diffSnapshots(before linkSnapshot, after linkSnapshot) map[string]auditChange {
+	changes := make(map[string]auditChange)
+	for _, field := range auditFields {
+		if before[field] == after[field] {
+			continue
+		}
+		changes[field] = auditChange{Before: before[field], After: after[field]}
+	}
+	return changes
+}
+
+// запись изменения ссылки в журнал. Вызывается в транзакции изменения:
+// ошибка записи журнала отменяет и само изменение
+func recordAudit(c *gin.Context, db Store, linkID int64, action string, before linkSnapshot, after linkSnapshot) error {
+	changes, err := json.Marshal(diffSnapshots(before, after))
+	if err != nil {
+		return failedStep("unable to encode audit changes", err)
+	}
+	var auditParams generated.CreateAuditEntryParams
+	auditParams.LinkID = linkID
+	auditParams.Action = action
+	auditParams.Actor = textOrNull(truncateString(c.GetHeader(actorHeader), maxActorLength))
+	auditParams.Ip = textOrNull(c.ClientIP())
+	auditParams.Changes = changes
+	return failedStep("unable to record audit entry", db.CreateAuditEntry(c.Request.Context(), auditParams))
+}
+
+// запись журнала для ответа API
+type auditEntry struct {
+	ID        int64              `json:"id"`
+	LinkID    int64              `json:"link_id"`
+	Action    string             `json:"action"`
+	Actor     pgtype.Text        `json:"claimed_actor"`
+	Ip        pgtype.Text        `json:"ip"`
+	Changes   json.RawMessage    `json:"changes"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+// фильтр журнала в формате react-admin
+type auditFilter struct {
+	LinkID *int64 `json:"link_id"`
+	Actor  string `json:"claimed_actor"`
+}
+
+// разбор параметра filter журнала, неизвестные поля считаются ошибкой
+func parseAuditFilter(raw string) (pgtype.Int8, pgtype.Text, error) {
+	if raw == "" {
+		return pgtype.Int8{}, pgtype.Text{}, nil
+	}
+	var filter auditFilter
+	dec := json.NewDecoder(bytes.NewBufferString(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		return pgtype.Int8{}, pgtype.Text{}, errors.New("the filter must be a JSON object with fields: link_id, claimed_actor")
+	}
+	var linkID pgtype.Int8
+	if filter.LinkID != nil {
+		linkID = pgtype.Int8{Int64: *filter.LinkID, Valid: true}
+	}
+	return linkID, textOrNull(filter.Actor), nil
+}
+
+// журнал изменений ссылок, последние изменения первыми
+func listAudit(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := pagination.FromRequest(c.Request, "audit")
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		linkID, actor, err := parseAuditFilter(c.Query("filter"))
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		var paginParams generated.ListAuditEntriesParams
+		paginParams.LinkID = linkID
+		paginParams.Actor = actor
+		paginParams.Limit = int32(page.Limit)
+		paginParams.Offset = int32(page.Offset)
+		rows, err := db.ListAuditEntries(c.Request.Context(), paginParams)
+		if err != nil {
+			dbError(c, "unable to get audit entries", err)
+			return
+		}
+		var countParams generated.CounterAuditEntriesParams
+		countParams.LinkID = linkID
+		countParams.Actor = actor
+		count, err := db.CounterAuditEntries(c.Request.Context(), countParams)
+		if err != nil {
+			dbError(c, "unable to count the number of records", err)
+			return
+		}
+		entries := make([]auditEntry, 0, len(rows))
+		for _, row := range rows {
+			entries = append(entries, auditEntry{
+				ID:        row.ID,
+				LinkID:    row.LinkID,
+				Action:    row.Action,
+				Actor:     row.Actor,
+				Ip:        row.Ip,
+				Changes:   row.Changes,
+				CreatedAt: row.CreatedAt,
+			})
+		}
+		writePage(c, page, "audit", entries, count)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	name := pgtype.Text{String: "exmpl", Valid: true}
+	before := newLinkSnapshot("https://example.com/a", name, pgtype.Text{}, pgtype.Text{}, pgtype.Int8{}, nil, false)
+	after := newLinkSnapshot("https://example.com/b", name, pgtype.Text{}, pgtype.Text{String: "Title", Valid: true}, pgtype.Int8{Int64: 2, Valid: true}, []string{"promo", "q3"}, false)
+	assert.Equal(t, map[string]auditChange{
+		"original_url": {Before: "https://example.com/a", After: "https://example.com/b"},
+		"title":        {Before: nil, After: "Title"},
+		"folder_id":    {Before: nil, After: int64(2)},
+		"tags":         {Before: nil, After: "promo,q3"},
+	}, diffSnapshots(before, after))
+
+	// при создании ссылки сохраняются все заполненные поля
+	assert.Equal(t, map[string]auditChange{
+		"original_url": {Before: nil, After: "https://example.com/a"},
+		"short_name":   {Before: nil, After: "exmpl"},
+		"deleted":      {Before: nil, After: false},
+	}, diffSnapshots(nil, before))
+
+	assert.Empty(t, diffSnapshots(before, before))
+}
+
+func TestParseAuditFilter(t *testing.T) {
+	linkID, actor, err := parseAuditFilter(`{"link_id":5,"claimed_actor":"alice"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Int8{Int64: 5, Valid: true}, linkID)
+	assert.Equal(t, pgtype.Text{String: "alice", Valid: true}, actor)
+
+	linkID, actor, err = parseAuditFilter("")
+	assert.NoError(t, err)
+	assert.False(t, linkID.Valid)
+	assert.False(t, actor.Valid)
+
+	_, _, err = parseAuditFilter(`{"action":"delete"}`)
+	assert.Error(t, err)
+}
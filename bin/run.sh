@@ -3,8 +3,16 @@ set -euo pipefail
 
 echo "[run.sh] Starting service"
 
-echo "[run.sh] Running DB migrations"
-goose -dir ./db/migrations postgres "${DATABASE_URL}" up
+case "${DATABASE_URL}" in
+  sqlite://*)
+    # миграции SQLite приложение применяет само при запуске
+    echo "[run.sh] Using SQLite storage"
+    ;;
+  *)
+    echo "[run.sh] Running DB migrations"
+    goose -dir ./db/migrations postgres "${DATABASE_URL}" up
+    ;;
+esac
 
 echo "[run.sh] Starting Caddy"
 caddy run --config /etc/caddy/Caddyfile &
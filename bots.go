@@ -0,0 +1,221 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// код ошибки PostgreSQL при нарушении уникальности
+const pgUniqueViolation = "23505"
+
+// встроенные сигнатуры ботов, сравниваются с User-Agent без учёта регистра
+var defaultBotSignatures = []string{
+	// сервисы предпросмотра ссылок в мессенджерах и соцсетях
+	"slackbot",
+	"slack-imgproxy",
+	"telegrambot",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebookcatalog",
+	"discordbot",
+	"whatsapp",
+	"linkedinbot",
+	"skypeuripreview",
+	"vkshare",
+	"viber",
+	"pinterestbot",
+	"redditbot",
+	"embedly",
+	// поисковые роботы
+	"googlebot",
+	"google-inspectiontool",
+	"bingbot",
+	// роботы Яндекса по отдельности: подстрока yandex есть и в
+	// User-Agent приложений Яндекса со встроенным браузером
+	"yandexbot",
+	"yandeximages",
+	"yandexvideo",
+	"yandexmedia",
+	"yandexmetrika",
+	"yandexfavicons",
+	"yandexwebmaster",
+	"yandexdirect",
+	"yandexsitelinks",
+	"duckduckbot",
+	"baiduspider",
+	"applebot",
+	"petalbot",
+	"ahrefsbot",
+	"semrushbot",
+	"mj12bot",
+	// http-клиенты и безголовые браузеры
+	"curl/",
+	"wget/",
+	"python-requests",
+	"python-urllib",
+	"go-http-client",
+	"okhttp",
+	"axios/",
+	"headlesschrome",
+	"phantomjs",
+	// общие признаки
+	"bot",
+	"crawler",
+	"spider",
+	"preview",
+}
+
+// классификатор посетителей на ботов и людей
+type botDetector struct {
+	mu     sync.RWMutex
+	custom []string
+}
+
+// создание классификатора только со встроенными сигнатурами
+func newBotDetector() *botDetector {
+	return &botDetector{}
+}
+
+// загрузка пользовательских сигнатур из БД
+func (d *botDetector) reload(ctx context.Context, db Store) error {
+	signatures, err := db.ListBotSignatures(ctx)
+	if err != nil {
+		return err
+	}
+	custom := make([]string, 0, len(signatures))
+	for _, s := range signatures {
+		custom = append(custom, strings.ToLower(s.Pattern))
+	}
+	d.mu.Lock()
+	d.custom = custom
+	d.mu.Unlock()
+	return nil
+}
+
+// проверка User-Agent по встроенным и пользовательским сигнатурам
+func (d *botDetector) matchUserAgent(ua string) bool {
+	lower := strings.ToLower(ua)
+	for _, s := range defaultBotSignatures {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, s := range d.custom {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// классификация запроса: сигнатуры User-Agent и эвристики по заголовкам
+func (d *botDetector) isBot(r *http.Request) bool {
+	ua := r.UserAgent()
+	// без User-Agent ходят только скрипты
+	if ua == "" {
+		return true
+	}
+	if d.matchUserAgent(ua) {
+		return true
+	}
+	// предзагрузка страницы браузером не является переходом
+	if r.Header.Get("Sec-Purpose") != "" || r.Header.Get("Purpose") == "prefetch" {
+		return true
+	}
+	// без языка ходят и браузеры с защитой приватности, и встроенные
+	// в приложения браузеры, поэтому это слабый признак: ботом считается
+	// только запрос, который к тому же не просит HTML, как браузер при переходе
+	return r.Header.Get("Accept-Language") == "" && !strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// список встроенных и пользовательских сигнатур ботов
+func listBotSignatures(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signatures, err := db.ListBotSignatures(c.Request.Context())
+		if err != nil {
+			dbError(c, "unable to get bot signatures", err)
+			return
+		}
+		if signatures == nil {
+			signatures = []generated.BotSignature{}
+		}
+		c.JSON(http.StatusOK, gin.H{"builtin": defaultBotSignatures, "custom": signatures})
+	}
+}
+
+// структура для валидации сигнатуры бота
+type BotSignatureRequest struct {
+	Pattern string `json:"pattern" binding:"required,min=3,max=128"`
+}
+
+// добавление пользовательской сигнатуры бота
+func createBotSignature(db Store, bots *botDetector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BotSignatureRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				errorsMap := make(map[string]string)
+				for _, e := range ve {
+					errorsMap[e.Field()] = e.Tag()
+				}
+				writeValidationProblem(c, errorsMap)
+				return
+			}
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
+			return
+		}
+		pattern := strings.ToLower(strings.TrimSpace(req.Pattern))
+		res, err := db.CreateBotSignature(c.Request.Context(), pattern)
+		if isUniqueViolation(err) {
+			writeProblem(c, http.StatusConflict, codeBotSignatureExists, "bot signature already exists")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to create bot signature", err)
+			return
+		}
+		// обновляем сигнатуры в памяти
+		if err := bots.reload(c.Request.Context(), db); err != nil {
+			slog.ErrorContext(c, "unable to reload bot signatures", "error", err)
+		}
+		c.JSON(http.StatusCreated, res)
+	}
+}
+
+// удаление пользовательской сигнатуры бота
+func deleteBotSignature(db Store, bots *botDetector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		deleted, err := db.DeleteBotSignature(c.Request.Context(), id)
+		if err != nil {
+			dbError(c, "unable to delete bot signature", err)
+			return
+		}
+		if deleted == 0 {
+			writeProblem(c, http.StatusNotFound, codeBotSignatureNotFound, "bot signature not found")
+			return
+		}
+		// обновляем сигнатуры в памяти
+		if err := bots.reload(c.Request.Context(), db); err != nil {
+			slog.ErrorContext(c, "unable to reload bot signatures", "error", err)
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotDetectorIsBot(t *testing.T) {
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		wantBool bool
+	}{
+		{"browser", http.MethodGet, map[string]string{"User-Agent": chrome, "Accept-Language": "ru"}, false},
+		{"slack unfurler", http.MethodGet, map[string]string{"User-Agent": "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "Accept-Language": "en"}, true},
+		{"telegram", http.MethodGet, map[string]string{"User-Agent": "TelegramBot (like TwitterBot)", "Accept-Language": "en"}, true},
+		{"empty user agent", http.MethodGet, map[string]string{"Accept-Language": "ru"}, true},
+		{"prefetch", http.MethodGet, map[string]string{"User-Agent": chrome, "Accept-Language": "ru", "Sec-Purpose": "prefetch"}, true},
+		{"privacy browser without accept-language", http.MethodGet, map[string]string{"User-Agent": chrome, "Accept": "text/html,application/xhtml+xml,*/*;q=0.8"}, false},
+		{"no accept-language and no html", http.MethodGet, map[string]string{"User-Agent": chrome, "Accept": "*/*"}, true},
+		{"no accept headers", http.MethodGet, map[string]string{"User-Agent": chrome}, true},
+		{"yandex browser", http.MethodGet, map[string]string{"User-Agent": chrome + " YaBrowser/24.4.0.0 Yowser/2.5", "Accept-Language": "ru"}, false},
+		{"yandex app", http.MethodGet, map[string]string{"User-Agent": "Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36 YandexSearch/24.41", "Accept-Language": "ru"}, false},
+		{"yandex crawler", http.MethodGet, map[string]string{"User-Agent": "Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)", "Accept-Language": "ru"}, true},
+		{"yandex images", http.MethodGet, map[string]string{"User-Agent": "Mozilla/5.0 (compatible; YandexImages/3.0; +http://yandex.com/bots)"}, true},
+	}
+
+	d := newBotDetector()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/r/exmpl", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.wantBool, d.isBot(r))
+		})
+	}
+}
+
+func TestBotDetectorCustomSignatures(t *testing.T) {
+	d := newBotDetector()
+	assert.False(t, d.matchUserAgent("Mozilla/5.0 (compatible; LinkChecker/1.0)"))
+	d.custom = []string{"linkchecker"}
+	assert.True(t, d.matchUserAgent("Mozilla/5.0 (compatible; LinkChecker/1.0)"))
+}
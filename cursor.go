@@ -0,0 +1,150 @@
+package main
+
+import (
+	"code/pagination"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// заголовки с курсорами соседних страниц
+const (
+	nextCursorHeader = "X-Next-Cursor"
+	prevCursorHeader = "X-Prev-Cursor"
+)
+
+// содержимое курсора: id последней (или первой) записи страницы
+type cursorPayload struct {
+	ID int64 `json:"id"`
+}
+
+// кодирование курсора в непрозрачную строку
+func encodeCursor(id int64) string {
+	data, _ := json.Marshal(cursorPayload{ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// декодирование курсора, пустая строка означает начало списка
+func decodeCursor(raw string) (pgtype.Int8, error) {
+	if raw == "" {
+		return pgtype.Int8{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return pgtype.Int8{}, errors.New("invalid cursor")
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return pgtype.Int8{}, errors.New("invalid cursor")
+	}
+	return pgtype.Int8{Int64: payload.ID, Valid: true}, nil
+}
+
+// страница списка по курсору: записи после after или перед before
+type keysetPage struct {
+	Cursor pgtype.Int8
+	// true для before, записи читаются в обратном порядке
+	Backward bool
+	Limit    int
+}
+
+// разбор параметров after, before и limit. Второе значение false,
+// если курсор не передан и используется постраничный вывод по range
+func parseKeysetPage(c *gin.Context) (keysetPage, bool, error) {
+	after, hasAfter := c.GetQuery("after")
+	before, hasBefore := c.GetQuery("before")
+	if !hasAfter && !hasBefore {
+		return keysetPage{}, false, nil
+	}
+	if hasAfter && hasBefore {
+		return keysetPage{}, true, errors.New("only one of after and before can be specified")
+	}
+	var page keysetPage
+	raw := after
+	if hasBefore {
+		raw = before
+		page.Backward = true
+	}
+	var err error
+	page.Cursor, err = decodeCursor(raw)
+	if err != nil {
+		return page, true, err
+	}
+	page.Limit = pagination.MaxPageSize
+	if value, ok := c.GetQuery("limit"); ok {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return page, true, errors.New("the limit must be a positive number")
+		}
+		page.Limit = min(limit, pagination.MaxPageSize)
+	}
+	return page, true, nil
+}
+
+// направление чтения из БД: обратное порядку вывода для before
+func (p keysetPage) scanDesc(desc bool) bool {
+	return desc != p.Backward
+}
+
+// граница id для запросов ...ByCursorAsc и ...ByCursorDesc. Без курсора
+// чтение начинается с края списка: все id больше 0 и меньше MaxInt64
+func (p keysetPage) cursorID(scanDesc bool) int64 {
+	switch {
+	case p.Cursor.Valid:
+		return p.Cursor.Int64
+	case scanDesc:
+		return math.MaxInt64
+	}
+	return 0
+}
+
+// обрезка лишней записи, восстановление порядка вывода
+// и курсоры соседних страниц. Из БД читается на одну запись больше limit,
+// чтобы узнать, есть ли следующая страница
+func keysetResult[T any](rows []T, page keysetPage, id func(T) int64) ([]T, string, string) {
+	hasMore := len(rows) > page.Limit
+	if hasMore {
+		rows = rows[:page.Limit]
+	}
+	if page.Backward {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+	first := encodeCursor(id(rows[0]))
+	last := encodeCursor(id(rows[len(rows)-1]))
+	if page.Backward {
+		// записи после страницы есть всегда: с них начинался запрос
+		if hasMore {
+			return rows, last, first
+		}
+		return rows, last, ""
+	}
+	next := ""
+	if hasMore {
+		next = last
+	}
+	// предыдущая страница есть, если запрос начинался не с начала списка
+	prev := ""
+	if page.Cursor.Valid {
+		prev = first
+	}
+	return rows, next, prev
+}
+
+// запись курсоров соседних страниц в заголовки ответа
+func setCursorHeaders(c *gin.Context, next string, prev string) {
+	if next != "" {
+		c.Header(nextCursorHeader, next)
+	}
+	if prev != "" {
+		c.Header(prevCursorHeader, prev)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id, err := decodeCursor(encodeCursor(42))
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Int8{Int64: 42, Valid: true}, id)
+
+	id, err = decodeCursor("")
+	assert.NoError(t, err)
+	assert.False(t, id.Valid)
+
+	for _, raw := range []string{"***", "bm90IGpzb24"} {
+		_, err = decodeCursor(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestKeysetResult(t *testing.T) {
+	id := func(v int64) int64 { return v }
+
+	// первая страница, есть следующая
+	rows, next, prev := keysetResult([]int64{1, 2, 3}, keysetPage{Limit: 2}, id)
+	assert.Equal(t, []int64{1, 2}, rows)
+	assert.Equal(t, encodeCursor(2), next)
+	assert.Equal(t, "", prev)
+
+	// последняя страница после курсора
+	after := pgtype.Int8{Int64: 2, Valid: true}
+	rows, next, prev = keysetResult([]int64{3}, keysetPage{Cursor: after, Limit: 2}, id)
+	assert.Equal(t, []int64{3}, rows)
+	assert.Equal(t, "", next)
+	assert.Equal(t, encodeCursor(3), prev)
+
+	// страница перед курсором читается в обратном порядке
+	before := pgtype.Int8{Int64: 5, Valid: true}
+	rows, next, prev = keysetResult([]int64{4, 3, 2}, keysetPage{Cursor: before, Backward: true, Limit: 2}, id)
+	assert.Equal(t, []int64{3, 4}, rows)
+	assert.Equal(t, encodeCursor(4), next)
+	assert.Equal(t, encodeCursor(3), prev)
+
+	rows, next, prev = keysetResult([]int64{}, keysetPage{Cursor: after, Limit: 2}, id)
+	assert.Empty(t, rows)
+	assert.Equal(t, "", next)
+	assert.Equal(t, "", prev)
+}
+
+func TestKeysetScanDesc(t *testing.T) {
+	assert.False(t, keysetPage{}.scanDesc(false))
+	assert.True(t, keysetPage{}.scanDesc(true))
+	assert.True(t, keysetPage{Backward: true}.scanDesc(false))
+	assert.False(t, keysetPage{Backward: true}.scanDesc(true))
+}
+
+func TestKeysetCursorID(t *testing.T) {
+	assert.Equal(t, int64(0), keysetPage{}.cursorID(false))
+	assert.Equal(t, int64(math.MaxInt64), keysetPage{}.cursorID(true))
+	page := keysetPage{Cursor: pgtype.Int8{Int64: 42, Valid: true}}
+	assert.Equal(t, int64(42), page.cursorID(false))
+	assert.Equal(t, int64(42), page.cursorID(true))
+}
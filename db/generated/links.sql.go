@@ -13,10 +13,47 @@ import (
 
 const counterLinks = `-- name: CounterLinks :one
 SELECT COUNT(*) FROM links
+WHERE deleted_at IS NULL
+AND ($1::text IS NULL OR short_name LIKE $1::text || '%')
+AND ($2::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || $2::text || '%')
+AND ($3::timestamptz IS NULL OR created_at >= $3::timestamptz)
+AND ($4::timestamptz IS NULL OR created_at <= $4::timestamptz)
+AND ($5::bigint[] IS NULL OR id = ANY($5::bigint[]))
+AND ($6::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', $6::text)
+	OR original_url ILIKE '%' || $7::text || '%'
+	OR short_name ILIKE '%' || $7::text || '%'
+	OR title ILIKE '%' || $7::text || '%')
+AND ($8::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = $8::text))
+AND ($9::bigint IS NULL OR folder_id = $9::bigint)
 `
 
-func (q *Queries) CounterLinks(ctx context.Context) (int64, error) {
-	row := q.db.QueryRow(ctx, counterLinks)
+type CounterLinksParams struct {
+	ShortNamePrefix pgtype.Text        `json:"short_name_prefix"`
+	Domain          pgtype.Text        `json:"domain"`
+	CreatedFrom     pgtype.Timestamptz `json:"created_from"`
+	CreatedTo       pgtype.Timestamptz `json:"created_to"`
+	Ids             []int64            `json:"ids"`
+	Search          pgtype.Text        `json:"search"`
+	SearchPattern   pgtype.Text        `json:"search_pattern"`
+	Tag             pgtype.Text        `json:"tag"`
+	FolderID        pgtype.Int8        `json:"folder_id"`
+}
+
+func (q *Queries) CounterLinks(ctx context.Context, arg CounterLinksParams) (int64, error) {
+	row := q.db.QueryRow(ctx, counterLinks,
+		arg.ShortNamePrefix,
+		arg.Domain,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Ids,
+		arg.Search,
+		arg.SearchPattern,
+		arg.Tag,
+		arg.FolderID,
+	)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
@@ -24,10 +61,32 @@ func (q *Queries) CounterLinks(ctx context.Context) (int64, error) {
 
 const counterVisits = `-- name: CounterVisits :one
 SELECT COUNT(*) FROM link_visits
+WHERE ($1::bigint IS NULL OR link_id = $1::bigint)
+AND ($2::timestamptz IS NULL OR created_at >= $2::timestamptz)
+AND ($3::timestamptz IS NULL OR created_at <= $3::timestamptz)
+AND ($4::int IS NULL OR status = $4::int)
+AND ($5::text IS NULL OR referer ILIKE '%' || $5::text || '%')
+AND ($6::text IS NULL OR user_agent ILIKE '%' || $6::text || '%')
 `
 
-func (q *Queries) CounterVisits(ctx context.Context) (int64, error) {
-	row := q.db.QueryRow(ctx, counterVisits)
+type CounterVisitsParams struct {
+	LinkID      pgtype.Int8        `json:"link_id"`
+	CreatedFrom pgtype.Timestamptz `json:"created_from"`
+	CreatedTo   pgtype.Timestamptz `json:"created_to"`
+	Status      pgtype.Int4        `json:"status"`
+	Referer     pgtype.Text        `json:"referer"`
+	UserAgent   pgtype.Text        `json:"user_agent"`
+}
+
+func (q *Queries) CounterVisits(ctx context.Context, arg CounterVisitsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, counterVisits,
+		arg.LinkID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Status,
+		arg.Referer,
+		arg.UserAgent,
+	)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
@@ -35,20 +94,27 @@ func (q *Queries) CounterVisits(ctx context.Context) (int64, error) {
 
 const createLink = `-- name: CreateLink :one
 INSERT INTO links (
-original_url, short_name
+original_url, short_name, title, folder_id
 ) VALUES (
-$1, $2 
+$1, $2, $3, $4
 )
-RETURNING id, original_url, short_name, short_url, created_at
+RETURNING id, original_url, short_name, short_url, created_at, title, deleted_at, destination_version, folder_id
 `
 
 type CreateLinkParams struct {
 	OriginalUrl string      `json:"original_url"`
 	ShortName   pgtype.Text `json:"short_name"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
 }
 
 func (q *Queries) CreateLink(ctx context.Context, arg CreateLinkParams) (Link, error) {
-	row := q.db.QueryRow(ctx, createLink, arg.OriginalUrl, arg.ShortName)
+	row := q.db.QueryRow(ctx, createLink,
+		arg.OriginalUrl,
+		arg.ShortName,
+		arg.Title,
+		arg.FolderID,
+	)
 	var i Link
 	err := row.Scan(
 		&i.ID,
@@ -56,25 +122,36 @@ func (q *Queries) CreateLink(ctx context.Context, arg CreateLinkParams) (Link, e
 		&i.ShortName,
 		&i.ShortUrl,
 		&i.CreatedAt,
+		&i.Title,
+		&i.DeletedAt,
+		&i.DestinationVersion,
+		&i.FolderID,
 	)
 	return i, err
 }
 
 const createLinkVisits = `-- name: CreateLinkVisits :one
 INSERT INTO link_visits (
-link_id, ip, user_agent, referer, status
+link_id, ip, user_agent, referer, status, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
 ) VALUES (
-$1, $2, $3, $4, $5
+$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
 )
-RETURNING id, link_id, ip, user_agent, referer, status, created_at
+RETURNING id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
 `
 
 type CreateLinkVisitsParams struct {
-	LinkID    int64       `json:"link_id"`
-	Ip        pgtype.Text `json:"ip"`
-	UserAgent pgtype.Text `json:"user_agent"`
-	Referer   pgtype.Text `json:"referer"`
-	Status    pgtype.Int4 `json:"status"`
+	LinkID             int64       `json:"link_id"`
+	Ip                 pgtype.Text `json:"ip"`
+	UserAgent          pgtype.Text `json:"user_agent"`
+	Referer            pgtype.Text `json:"referer"`
+	Status             pgtype.Int4 `json:"status"`
+	Browser            pgtype.Text `json:"browser"`
+	BrowserVersion     pgtype.Text `json:"browser_version"`
+	Os                 pgtype.Text `json:"os"`
+	DeviceType         pgtype.Text `json:"device_type"`
+	IsBot              bool        `json:"is_bot"`
+	VisitorHash        pgtype.Text `json:"visitor_hash"`
+	DestinationVersion pgtype.Int4 `json:"destination_version"`
 }
 
 func (q *Queries) CreateLinkVisits(ctx context.Context, arg CreateLinkVisitsParams) (LinkVisit, error) {
@@ -84,6 +161,13 @@ func (q *Queries) CreateLinkVisits(ctx context.Context, arg CreateLinkVisitsPara
 		arg.UserAgent,
 		arg.Referer,
 		arg.Status,
+		arg.Browser,
+		arg.BrowserVersion,
+		arg.Os,
+		arg.DeviceType,
+		arg.IsBot,
+		arg.VisitorHash,
+		arg.DestinationVersion,
 	)
 	var i LinkVisit
 	err := row.Scan(
@@ -94,24 +178,22 @@ func (q *Queries) CreateLinkVisits(ctx context.Context, arg CreateLinkVisitsPara
 		&i.Referer,
 		&i.Status,
 		&i.CreatedAt,
+		&i.Browser,
+		&i.BrowserVersion,
+		&i.Os,
+		&i.DeviceType,
+		&i.IsBot,
+		&i.VisitorHash,
+		&i.DestinationVersion,
 	)
 	return i, err
 }
 
-const deleteLink = `-- name: DeleteLink :exec
-DELETE FROM links
-WHERE id = $1
-`
-
-func (q *Queries) DeleteLink(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, deleteLink, id)
-	return err
-}
-
 const getLink = `-- name: GetLink :one
-SELECT id, original_url, short_name, short_url
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
 FROM links
-WHERE id = $1 LIMIT 1
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 type GetLinkRow struct {
@@ -119,6 +201,9 @@ type GetLinkRow struct {
 	OriginalUrl string      `json:"original_url"`
 	ShortName   pgtype.Text `json:"short_name"`
 	ShortUrl    pgtype.Text `json:"short_url"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
+	Tags        []string    `json:"tags"`
 }
 
 func (q *Queries) GetLink(ctx context.Context, id int64) (GetLinkRow, error) {
@@ -129,30 +214,73 @@ func (q *Queries) GetLink(ctx context.Context, id int64) (GetLinkRow, error) {
 		&i.OriginalUrl,
 		&i.ShortName,
 		&i.ShortUrl,
+		&i.Title,
+		&i.FolderID,
+		&i.Tags,
+	)
+	return i, err
+}
+
+const getLinkForUpdate = `-- name: GetLinkForUpdate :one
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
+FROM links
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+FOR UPDATE
+`
+
+type GetLinkForUpdateRow struct {
+	ID          int64       `json:"id"`
+	OriginalUrl string      `json:"original_url"`
+	ShortName   pgtype.Text `json:"short_name"`
+	ShortUrl    pgtype.Text `json:"short_url"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
+	Tags        []string    `json:"tags"`
+}
+
+func (q *Queries) GetLinkForUpdate(ctx context.Context, id int64) (GetLinkForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getLinkForUpdate, id)
+	var i GetLinkForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.OriginalUrl,
+		&i.ShortName,
+		&i.ShortUrl,
+		&i.Title,
+		&i.FolderID,
+		&i.Tags,
 	)
 	return i, err
 }
 
 const getLinkFromCode = `-- name: GetLinkFromCode :one
-SELECT id, original_url
+SELECT id, original_url, deleted_at, destination_version
 FROM links 
 WHERE short_name = $1
 `
 
 type GetLinkFromCodeRow struct {
-	ID          int64  `json:"id"`
-	OriginalUrl string `json:"original_url"`
+	ID                 int64              `json:"id"`
+	OriginalUrl        string             `json:"original_url"`
+	DeletedAt          pgtype.Timestamptz `json:"deleted_at"`
+	DestinationVersion int32              `json:"destination_version"`
 }
 
 func (q *Queries) GetLinkFromCode(ctx context.Context, shortName pgtype.Text) (GetLinkFromCodeRow, error) {
 	row := q.db.QueryRow(ctx, getLinkFromCode, shortName)
 	var i GetLinkFromCodeRow
-	err := row.Scan(&i.ID, &i.OriginalUrl)
+	err := row.Scan(
+		&i.ID,
+		&i.OriginalUrl,
+		&i.DeletedAt,
+		&i.DestinationVersion,
+	)
 	return i, err
 }
 
 const lastLink = `-- name: LastLink :one
-SELECT id, original_url, short_name, short_url, created_at FROM links
+SELECT id, original_url, short_name, short_url, created_at, title, deleted_at, destination_version, folder_id FROM links
 ORDER BY id DESC
 LIMIT 1
 `
@@ -166,47 +294,217 @@ func (q *Queries) LastLink(ctx context.Context) (Link, error) {
 		&i.ShortName,
 		&i.ShortUrl,
 		&i.CreatedAt,
+		&i.Title,
+		&i.DeletedAt,
+		&i.DestinationVersion,
+		&i.FolderID,
 	)
 	return i, err
 }
 
 const listLinkVisits = `-- name: ListLinkVisits :many
-SELECT id, link_id, created_at, ip, user_agent, status
+SELECT id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
 FROM link_visits
-ORDER BY id
-LIMIT $1 OFFSET $2
+WHERE ($1::bigint IS NULL OR link_id = $1::bigint)
+AND ($2::timestamptz IS NULL OR created_at >= $2::timestamptz)
+AND ($3::timestamptz IS NULL OR created_at <= $3::timestamptz)
+AND ($4::int IS NULL OR status = $4::int)
+AND ($5::text IS NULL OR referer ILIKE '%' || $5::text || '%')
+AND ($6::text IS NULL OR user_agent ILIKE '%' || $6::text || '%')
+ORDER BY
+	CASE WHEN $7::text = 'created_at' AND NOT $8::bool THEN created_at END ASC,
+	CASE WHEN $7::text = 'created_at' AND $8::bool THEN created_at END DESC,
+	CASE WHEN $8::bool THEN id END DESC,
+	id
+LIMIT $9 OFFSET $10
 `
 
 type ListLinkVisitsParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	LinkID      pgtype.Int8        `json:"link_id"`
+	CreatedFrom pgtype.Timestamptz `json:"created_from"`
+	CreatedTo   pgtype.Timestamptz `json:"created_to"`
+	Status      pgtype.Int4        `json:"status"`
+	Referer     pgtype.Text        `json:"referer"`
+	UserAgent   pgtype.Text        `json:"user_agent"`
+	SortField   string             `json:"sort_field"`
+	SortDesc    bool               `json:"sort_desc"`
+	Limit       int32              `json:"limit"`
+	Offset      int32              `json:"offset"`
 }
 
-type ListLinkVisitsRow struct {
-	ID        int64              `json:"id"`
-	LinkID    int64              `json:"link_id"`
-	CreatedAt pgtype.Timestamptz `json:"created_at"`
-	Ip        pgtype.Text        `json:"ip"`
-	UserAgent pgtype.Text        `json:"user_agent"`
-	Status    pgtype.Int4        `json:"status"`
+func (q *Queries) ListLinkVisits(ctx context.Context, arg ListLinkVisitsParams) ([]LinkVisit, error) {
+	rows, err := q.db.Query(ctx, listLinkVisits,
+		arg.LinkID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Status,
+		arg.Referer,
+		arg.UserAgent,
+		arg.SortField,
+		arg.SortDesc,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LinkVisit
+	for rows.Next() {
+		var i LinkVisit
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.Referer,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Browser,
+			&i.BrowserVersion,
+			&i.Os,
+			&i.DeviceType,
+			&i.IsBot,
+			&i.VisitorHash,
+			&i.DestinationVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinkVisitsByCursorAsc = `-- name: ListLinkVisitsByCursorAsc :many
+SELECT id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
+FROM link_visits
+WHERE ($1::bigint IS NULL OR link_id = $1::bigint)
+AND ($2::timestamptz IS NULL OR created_at >= $2::timestamptz)
+AND ($3::timestamptz IS NULL OR created_at <= $3::timestamptz)
+AND ($4::int IS NULL OR status = $4::int)
+AND ($5::text IS NULL OR referer ILIKE '%' || $5::text || '%')
+AND ($6::text IS NULL OR user_agent ILIKE '%' || $6::text || '%')
+AND id > $7::bigint
+ORDER BY id
+LIMIT $8
+`
+
+type ListLinkVisitsByCursorAscParams struct {
+	LinkID      pgtype.Int8        `json:"link_id"`
+	CreatedFrom pgtype.Timestamptz `json:"created_from"`
+	CreatedTo   pgtype.Timestamptz `json:"created_to"`
+	Status      pgtype.Int4        `json:"status"`
+	Referer     pgtype.Text        `json:"referer"`
+	UserAgent   pgtype.Text        `json:"user_agent"`
+	CursorID    int64              `json:"cursor_id"`
+	Limit       int32              `json:"limit"`
 }
 
-func (q *Queries) ListLinkVisits(ctx context.Context, arg ListLinkVisitsParams) ([]ListLinkVisitsRow, error) {
-	rows, err := q.db.Query(ctx, listLinkVisits, arg.Limit, arg.Offset)
+func (q *Queries) ListLinkVisitsByCursorAsc(ctx context.Context, arg ListLinkVisitsByCursorAscParams) ([]LinkVisit, error) {
+	rows, err := q.db.Query(ctx, listLinkVisitsByCursorAsc,
+		arg.LinkID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Status,
+		arg.Referer,
+		arg.UserAgent,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListLinkVisitsRow
+	var items []LinkVisit
 	for rows.Next() {
-		var i ListLinkVisitsRow
+		var i LinkVisit
 		if err := rows.Scan(
 			&i.ID,
 			&i.LinkID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.Referer,
+			&i.Status,
 			&i.CreatedAt,
+			&i.Browser,
+			&i.BrowserVersion,
+			&i.Os,
+			&i.DeviceType,
+			&i.IsBot,
+			&i.VisitorHash,
+			&i.DestinationVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinkVisitsByCursorDesc = `-- name: ListLinkVisitsByCursorDesc :many
+SELECT id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
+FROM link_visits
+WHERE ($1::bigint IS NULL OR link_id = $1::bigint)
+AND ($2::timestamptz IS NULL OR created_at >= $2::timestamptz)
+AND ($3::timestamptz IS NULL OR created_at <= $3::timestamptz)
+AND ($4::int IS NULL OR status = $4::int)
+AND ($5::text IS NULL OR referer ILIKE '%' || $5::text || '%')
+AND ($6::text IS NULL OR user_agent ILIKE '%' || $6::text || '%')
+AND id < $7::bigint
+ORDER BY id DESC
+LIMIT $8
+`
+
+type ListLinkVisitsByCursorDescParams struct {
+	LinkID      pgtype.Int8        `json:"link_id"`
+	CreatedFrom pgtype.Timestamptz `json:"created_from"`
+	CreatedTo   pgtype.Timestamptz `json:"created_to"`
+	Status      pgtype.Int4        `json:"status"`
+	Referer     pgtype.Text        `json:"referer"`
+	UserAgent   pgtype.Text        `json:"user_agent"`
+	CursorID    int64              `json:"cursor_id"`
+	Limit       int32              `json:"limit"`
+}
+
+func (q *Queries) ListLinkVisitsByCursorDesc(ctx context.Context, arg ListLinkVisitsByCursorDescParams) ([]LinkVisit, error) {
+	rows, err := q.db.Query(ctx, listLinkVisitsByCursorDesc,
+		arg.LinkID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Status,
+		arg.Referer,
+		arg.UserAgent,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LinkVisit
+	for rows.Next() {
+		var i LinkVisit
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkID,
 			&i.Ip,
 			&i.UserAgent,
+			&i.Referer,
 			&i.Status,
+			&i.CreatedAt,
+			&i.Browser,
+			&i.BrowserVersion,
+			&i.Os,
+			&i.DeviceType,
+			&i.IsBot,
+			&i.VisitorHash,
+			&i.DestinationVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -219,15 +517,50 @@ func (q *Queries) ListLinkVisits(ctx context.Context, arg ListLinkVisitsParams)
 }
 
 const listLinks = `-- name: ListLinks :many
-SELECT id, original_url, short_name, short_url 
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
 FROM links
-ORDER BY id
-LIMIT $1 OFFSET $2
+WHERE deleted_at IS NULL
+AND ($1::text IS NULL OR short_name LIKE $1::text || '%')
+AND ($2::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || $2::text || '%')
+AND ($3::timestamptz IS NULL OR created_at >= $3::timestamptz)
+AND ($4::timestamptz IS NULL OR created_at <= $4::timestamptz)
+AND ($5::bigint[] IS NULL OR id = ANY($5::bigint[]))
+AND ($6::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', $6::text)
+	OR original_url ILIKE '%' || $7::text || '%'
+	OR short_name ILIKE '%' || $7::text || '%'
+	OR title ILIKE '%' || $7::text || '%')
+AND ($8::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = $8::text))
+AND ($9::bigint IS NULL OR folder_id = $9::bigint)
+ORDER BY
+	CASE WHEN $10::text = 'original_url' AND NOT $11::bool THEN original_url END ASC,
+	CASE WHEN $10::text = 'original_url' AND $11::bool THEN original_url END DESC,
+	CASE WHEN $10::text = 'short_name' AND NOT $11::bool THEN short_name END ASC,
+	CASE WHEN $10::text = 'short_name' AND $11::bool THEN short_name END DESC,
+	CASE WHEN $10::text = 'created_at' AND NOT $11::bool THEN created_at END ASC,
+	CASE WHEN $10::text = 'created_at' AND $11::bool THEN created_at END DESC,
+	CASE WHEN $11::bool THEN id END DESC,
+	id
+LIMIT $12 OFFSET $13
 `
 
 type ListLinksParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	ShortNamePrefix pgtype.Text        `json:"short_name_prefix"`
+	Domain          pgtype.Text        `json:"domain"`
+	CreatedFrom     pgtype.Timestamptz `json:"created_from"`
+	CreatedTo       pgtype.Timestamptz `json:"created_to"`
+	Ids             []int64            `json:"ids"`
+	Search          pgtype.Text        `json:"search"`
+	SearchPattern   pgtype.Text        `json:"search_pattern"`
+	Tag             pgtype.Text        `json:"tag"`
+	FolderID        pgtype.Int8        `json:"folder_id"`
+	SortField       string             `json:"sort_field"`
+	SortDesc        bool               `json:"sort_desc"`
+	Limit           int32              `json:"limit"`
+	Offset          int32              `json:"offset"`
 }
 
 type ListLinksRow struct {
@@ -235,10 +568,27 @@ type ListLinksRow struct {
 	OriginalUrl string      `json:"original_url"`
 	ShortName   pgtype.Text `json:"short_name"`
 	ShortUrl    pgtype.Text `json:"short_url"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
+	Tags        []string    `json:"tags"`
 }
 
 func (q *Queries) ListLinks(ctx context.Context, arg ListLinksParams) ([]ListLinksRow, error) {
-	rows, err := q.db.Query(ctx, listLinks, arg.Limit, arg.Offset)
+	rows, err := q.db.Query(ctx, listLinks,
+		arg.ShortNamePrefix,
+		arg.Domain,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Ids,
+		arg.Search,
+		arg.SearchPattern,
+		arg.Tag,
+		arg.FolderID,
+		arg.SortField,
+		arg.SortDesc,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +601,97 @@ func (q *Queries) ListLinks(ctx context.Context, arg ListLinksParams) ([]ListLin
 			&i.OriginalUrl,
 			&i.ShortName,
 			&i.ShortUrl,
+			&i.Title,
+			&i.FolderID,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinksByCursorAsc = `-- name: ListLinksByCursorAsc :many
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
+FROM links
+WHERE deleted_at IS NULL
+AND ($1::text IS NULL OR short_name LIKE $1::text || '%')
+AND ($2::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || $2::text || '%')
+AND ($3::timestamptz IS NULL OR created_at >= $3::timestamptz)
+AND ($4::timestamptz IS NULL OR created_at <= $4::timestamptz)
+AND ($5::bigint[] IS NULL OR id = ANY($5::bigint[]))
+AND ($6::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', $6::text)
+	OR original_url ILIKE '%' || $7::text || '%'
+	OR short_name ILIKE '%' || $7::text || '%'
+	OR title ILIKE '%' || $7::text || '%')
+AND ($8::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = $8::text))
+AND ($9::bigint IS NULL OR folder_id = $9::bigint)
+AND id > $10::bigint
+ORDER BY id
+LIMIT $11
+`
+
+type ListLinksByCursorAscParams struct {
+	ShortNamePrefix pgtype.Text        `json:"short_name_prefix"`
+	Domain          pgtype.Text        `json:"domain"`
+	CreatedFrom     pgtype.Timestamptz `json:"created_from"`
+	CreatedTo       pgtype.Timestamptz `json:"created_to"`
+	Ids             []int64            `json:"ids"`
+	Search          pgtype.Text        `json:"search"`
+	SearchPattern   pgtype.Text        `json:"search_pattern"`
+	Tag             pgtype.Text        `json:"tag"`
+	FolderID        pgtype.Int8        `json:"folder_id"`
+	CursorID        int64              `json:"cursor_id"`
+	Limit           int32              `json:"limit"`
+}
+
+type ListLinksByCursorAscRow struct {
+	ID          int64       `json:"id"`
+	OriginalUrl string      `json:"original_url"`
+	ShortName   pgtype.Text `json:"short_name"`
+	ShortUrl    pgtype.Text `json:"short_url"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
+	Tags        []string    `json:"tags"`
+}
+
+func (q *Queries) ListLinksByCursorAsc(ctx context.Context, arg ListLinksByCursorAscParams) ([]ListLinksByCursorAscRow, error) {
+	rows, err := q.db.Query(ctx, listLinksByCursorAsc,
+		arg.ShortNamePrefix,
+		arg.Domain,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Ids,
+		arg.Search,
+		arg.SearchPattern,
+		arg.Tag,
+		arg.FolderID,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksByCursorAscRow
+	for rows.Next() {
+		var i ListLinksByCursorAscRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalUrl,
+			&i.ShortName,
+			&i.ShortUrl,
+			&i.Title,
+			&i.FolderID,
+			&i.Tags,
 		); err != nil {
 			return nil, err
 		}
@@ -262,9 +703,180 @@ func (q *Queries) ListLinks(ctx context.Context, arg ListLinksParams) ([]ListLin
 	return items, nil
 }
 
+const listLinksByCursorDesc = `-- name: ListLinksByCursorDesc :many
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
+FROM links
+WHERE deleted_at IS NULL
+AND ($1::text IS NULL OR short_name LIKE $1::text || '%')
+AND ($2::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || $2::text || '%')
+AND ($3::timestamptz IS NULL OR created_at >= $3::timestamptz)
+AND ($4::timestamptz IS NULL OR created_at <= $4::timestamptz)
+AND ($5::bigint[] IS NULL OR id = ANY($5::bigint[]))
+AND ($6::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', $6::text)
+	OR original_url ILIKE '%' || $7::text || '%'
+	OR short_name ILIKE '%' || $7::text || '%'
+	OR title ILIKE '%' || $7::text || '%')
+AND ($8::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = $8::text))
+AND ($9::bigint IS NULL OR folder_id = $9::bigint)
+AND id < $10::bigint
+ORDER BY id DESC
+LIMIT $11
+`
+
+type ListLinksByCursorDescParams struct {
+	ShortNamePrefix pgtype.Text        `json:"short_name_prefix"`
+	Domain          pgtype.Text        `json:"domain"`
+	CreatedFrom     pgtype.Timestamptz `json:"created_from"`
+	CreatedTo       pgtype.Timestamptz `json:"created_to"`
+	Ids             []int64            `json:"ids"`
+	Search          pgtype.Text        `json:"search"`
+	SearchPattern   pgtype.Text        `json:"search_pattern"`
+	Tag             pgtype.Text        `json:"tag"`
+	FolderID        pgtype.Int8        `json:"folder_id"`
+	CursorID        int64              `json:"cursor_id"`
+	Limit           int32              `json:"limit"`
+}
+
+type ListLinksByCursorDescRow struct {
+	ID          int64       `json:"id"`
+	OriginalUrl string      `json:"original_url"`
+	ShortName   pgtype.Text `json:"short_name"`
+	ShortUrl    pgtype.Text `json:"short_url"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
+	Tags        []string    `json:"tags"`
+}
+
+func (q *Queries) ListLinksByCursorDesc(ctx context.Context, arg ListLinksByCursorDescParams) ([]ListLinksByCursorDescRow, error) {
+	rows, err := q.db.Query(ctx, listLinksByCursorDesc,
+		arg.ShortNamePrefix,
+		arg.Domain,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Ids,
+		arg.Search,
+		arg.SearchPattern,
+		arg.Tag,
+		arg.FolderID,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksByCursorDescRow
+	for rows.Next() {
+		var i ListLinksByCursorDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalUrl,
+			&i.ShortName,
+			&i.ShortUrl,
+			&i.Title,
+			&i.FolderID,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchLinks = `-- name: SearchLinks :many
+SELECT id, original_url, short_name, short_url, title,
+	(ts_rank(to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')), websearch_to_tsquery('simple', $1::text))
+	+ GREATEST(
+		similarity(original_url, $1::text),
+		similarity(COALESCE(short_name, ''), $1::text),
+		similarity(COALESCE(title, ''), $1::text)
+	))::real AS rank,
+	COUNT(*) OVER ()::bigint AS total
+FROM links
+WHERE deleted_at IS NULL
+AND (to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', $1::text)
+	OR original_url ILIKE '%' || $2::text || '%'
+	OR short_name ILIKE '%' || $2::text || '%'
+	OR title ILIKE '%' || $2::text || '%')
+ORDER BY rank DESC, id
+LIMIT $3 OFFSET $4
+`
+
+type SearchLinksParams struct {
+	Search        string `json:"search"`
+	SearchPattern string `json:"search_pattern"`
+	Limit         int32  `json:"limit"`
+	Offset        int32  `json:"offset"`
+}
+
+type SearchLinksRow struct {
+	ID          int64       `json:"id"`
+	OriginalUrl string      `json:"original_url"`
+	ShortName   pgtype.Text `json:"short_name"`
+	ShortUrl    pgtype.Text `json:"short_url"`
+	Title       pgtype.Text `json:"title"`
+	Rank        float32     `json:"rank"`
+	Total       int64       `json:"total"`
+}
+
+func (q *Queries) SearchLinks(ctx context.Context, arg SearchLinksParams) ([]SearchLinksRow, error) {
+	rows, err := q.db.Query(ctx, searchLinks,
+		arg.Search,
+		arg.SearchPattern,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchLinksRow
+	for rows.Next() {
+		var i SearchLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalUrl,
+			&i.ShortName,
+			&i.ShortUrl,
+			&i.Title,
+			&i.Rank,
+			&i.Total,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteLink = `-- name: SoftDeleteLink :execrows
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteLink(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, softDeleteLink, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateLink = `-- name: UpdateLink :exec
 UPDATE links
-SET original_url = $2, short_name = $3
+SET original_url = $2, short_name = $3, title = $4, folder_id = $5
 WHERE id = $1
 `
 
@@ -272,10 +884,18 @@ type UpdateLinkParams struct {
 	ID          int64       `json:"id"`
 	OriginalUrl string      `json:"original_url"`
 	ShortName   pgtype.Text `json:"short_name"`
+	Title       pgtype.Text `json:"title"`
+	FolderID    pgtype.Int8 `json:"folder_id"`
 }
 
 func (q *Queries) UpdateLink(ctx context.Context, arg UpdateLinkParams) error {
-	_, err := q.db.Exec(ctx, updateLink, arg.ID, arg.OriginalUrl, arg.ShortName)
+	_, err := q.db.Exec(ctx, updateLink,
+		arg.ID,
+		arg.OriginalUrl,
+		arg.ShortName,
+		arg.Title,
+		arg.FolderID,
+	)
 	return err
 }
 
@@ -8,20 +8,112 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type BotSignature struct {
+	ID        int64              `json:"id"`
+	Pattern   string             `json:"pattern"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Folder struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Link struct {
+	ID                 int64              `json:"id"`
+	OriginalUrl        string             `json:"original_url"`
+	ShortName          pgtype.Text        `json:"short_name"`
+	ShortUrl           pgtype.Text        `json:"short_url"`
+	CreatedAt          pgtype.Timestamptz `json:"created_at"`
+	Title              pgtype.Text        `json:"title"`
+	DeletedAt          pgtype.Timestamptz `json:"deleted_at"`
+	DestinationVersion int32              `json:"destination_version"`
+	FolderID           pgtype.Int8        `json:"folder_id"`
+}
+
+type LinkAudit struct {
+	ID        int64              `json:"id"`
+	LinkID    int64              `json:"link_id"`
+	Action    string             `json:"action"`
+	Actor     pgtype.Text        `json:"actor"`
+	Ip        pgtype.Text        `json:"ip"`
+	Changes   []byte             `json:"changes"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type LinkDestination struct {
 	ID          int64              `json:"id"`
+	LinkID      int64              `json:"link_id"`
+	Version     int32              `json:"version"`
 	OriginalUrl string             `json:"original_url"`
-	ShortName   pgtype.Text        `json:"short_name"`
-	ShortUrl    pgtype.Text        `json:"short_url"`
 	CreatedAt   pgtype.Timestamptz `json:"created_at"`
 }
 
+type LinkTag struct {
+	LinkID int64 `json:"link_id"`
+	TagID  int64 `json:"tag_id"`
+}
+
 type LinkVisit struct {
+	ID                 int64              `json:"id"`
+	LinkID             int64              `json:"link_id"`
+	Ip                 pgtype.Text        `json:"ip"`
+	UserAgent          pgtype.Text        `json:"user_agent"`
+	Referer            pgtype.Text        `json:"referer"`
+	Status             pgtype.Int4        `json:"status"`
+	CreatedAt          pgtype.Timestamptz `json:"created_at"`
+	Browser            pgtype.Text        `json:"browser"`
+	BrowserVersion     pgtype.Text        `json:"browser_version"`
+	Os                 pgtype.Text        `json:"os"`
+	DeviceType         pgtype.Text        `json:"device_type"`
+	IsBot              bool               `json:"is_bot"`
+	VisitorHash        pgtype.Text        `json:"visitor_hash"`
+	DestinationVersion pgtype.Int4        `json:"destination_version"`
+}
+
+type LinkVisitDaily struct {
+	LinkID         int64       `json:"link_id"`
+	Day            pgtype.Date `json:"day"`
+	Browser        string      `json:"browser"`
+	BrowserVersion string      `json:"browser_version"`
+	Os             string      `json:"os"`
+	DeviceType     string      `json:"device_type"`
+	IsBot          bool        `json:"is_bot"`
+	Clicks         int64       `json:"clicks"`
+	UniqueClicks   int64       `json:"unique_clicks"`
+}
+
+type Tag struct {
 	ID        int64              `json:"id"`
-	LinkID    int64              `json:"link_id"`
-	Ip        pgtype.Text        `json:"ip"`
-	UserAgent pgtype.Text        `json:"user_agent"`
-	Referer   pgtype.Text        `json:"referer"`
-	Status    pgtype.Int4        `json:"status"`
+	Name      string             `json:"name"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type VisitorSalt struct {
+	Day       pgtype.Date        `json:"day"`
+	Salt      []byte             `json:"salt"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Webhook struct {
+	ID        int64              `json:"id"`
+	Url       string             `json:"url"`
+	Secret    string             `json:"secret"`
+	Events    []string           `json:"events"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
+
+type WebhookDelivery struct {
+	ID             int64              `json:"id"`
+	WebhookID      int64              `json:"webhook_id"`
+	Event          string             `json:"event"`
+	Payload        []byte             `json:"payload"`
+	Status         string             `json:"status"`
+	Attempts       int32              `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	ResponseStatus pgtype.Int4        `json:"response_status"`
+	LastError      pgtype.Text        `json:"last_error"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	DeliveredAt    pgtype.Timestamptz `json:"delivered_at"`
+}
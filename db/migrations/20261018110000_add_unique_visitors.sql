@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE link_visits ADD COLUMN visitor_hash VARCHAR(64);
+
+CREATE TABLE IF NOT EXISTS visitor_salts (
+	day DATE PRIMARY KEY,
+	salt BYTEA NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS visitor_salts;
+ALTER TABLE link_visits DROP COLUMN IF EXISTS visitor_hash;
+-- +goose StatementEnd
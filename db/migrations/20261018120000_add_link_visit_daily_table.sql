@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS link_visit_daily (
+	link_id BIGINT NOT NULL,
+	day DATE NOT NULL,
+	browser VARCHAR(64) NOT NULL DEFAULT '',
+	browser_version VARCHAR(32) NOT NULL DEFAULT '',
+	os VARCHAR(64) NOT NULL DEFAULT '',
+	device_type VARCHAR(16) NOT NULL DEFAULT '',
+	is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+	clicks BIGINT NOT NULL DEFAULT 0,
+	unique_clicks BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (link_id, day, browser, browser_version, os, device_type, is_bot),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS link_visits_created_at_idx ON link_visits (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS link_visits_created_at_idx;
+DROP TABLE IF EXISTS link_visit_daily;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS title VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS links_search_idx ON links USING GIN (
+	to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g'))
+);
+CREATE INDEX IF NOT EXISTS links_original_url_trgm_idx ON links USING GIN (original_url gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS links_short_name_trgm_idx ON links USING GIN (short_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS links_title_trgm_idx ON links USING GIN (title gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS links_title_trgm_idx;
+DROP INDEX IF EXISTS links_short_name_trgm_idx;
+DROP INDEX IF EXISTS links_original_url_trgm_idx;
+DROP INDEX IF EXISTS links_search_idx;
+ALTER TABLE links DROP COLUMN IF EXISTS title;
+-- +goose StatementEnd
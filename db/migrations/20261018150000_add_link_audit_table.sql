@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS link_audit (
+	id BIGSERIAL PRIMARY KEY,
+	link_id BIGINT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	actor VARCHAR(128),
+	ip VARCHAR(45),
+	changes JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS link_audit_link_id_idx ON link_audit (link_id);
+CREATE INDEX IF NOT EXISTS link_audit_actor_idx ON link_audit (actor);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS link_audit;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS link_destinations (
+	id BIGSERIAL PRIMARY KEY,
+	link_id BIGINT NOT NULL,
+	version INT NOT NULL,
+	original_url TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (link_id, version),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS destination_version INT NOT NULL DEFAULT 1;
+ALTER TABLE link_visits ADD COLUMN IF NOT EXISTS destination_version INT;
+
+-- текущие адреса становятся первой версией
+INSERT INTO link_destinations (link_id, version, original_url, created_at)
+SELECT id, 1, original_url, created_at FROM links
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE link_visits DROP COLUMN IF EXISTS destination_version;
+ALTER TABLE links DROP COLUMN IF EXISTS destination_version;
+DROP TABLE IF EXISTS link_destinations;
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS folders (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS link_tags (
+	link_id BIGINT NOT NULL,
+	tag_id BIGINT NOT NULL,
+	PRIMARY KEY (link_id, tag_id),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS link_tags_tag_id_idx ON link_tags (tag_id);
+
+-- при удалении папки ссылки остаются без папки
+ALTER TABLE links ADD COLUMN IF NOT EXISTS folder_id BIGINT REFERENCES folders(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS links_folder_id_idx ON links (folder_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS links_folder_id_idx;
+ALTER TABLE links DROP COLUMN IF EXISTS folder_id;
+DROP TABLE IF EXISTS link_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS folders;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- переходы одной ссылки по страницам и по курсору в порядке id
+CREATE INDEX IF NOT EXISTS link_visits_link_id_idx ON link_visits (link_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS link_visits_link_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO link_audit (
+link_id, action, actor, ip, changes
+) VALUES (
+$1, $2, $3, $4, $5
+);
+
+-- name: ListAuditEntries :many
+SELECT id, link_id, action, actor, ip, changes, created_at
+FROM link_audit
+WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+AND (sqlc.narg(actor)::text IS NULL OR actor = sqlc.narg(actor)::text)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CounterAuditEntries :one
+SELECT COUNT(*) FROM link_audit
+WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+AND (sqlc.narg(actor)::text IS NULL OR actor = sqlc.narg(actor)::text);
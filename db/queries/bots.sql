@@ -0,0 +1,16 @@
+-- name: ListBotSignatures :many
+SELECT id, pattern, created_at
+FROM bot_signatures
+ORDER BY id;
+
+-- name: CreateBotSignature :one
+INSERT INTO bot_signatures (
+pattern
+) VALUES (
+$1
+)
+RETURNING id, pattern, created_at;
+
+-- name: DeleteBotSignature :execrows
+DELETE FROM bot_signatures
+WHERE id = $1;
@@ -0,0 +1,34 @@
+-- name: AddLinkDestination :one
+WITH next_version AS (
+	SELECT COALESCE(MAX(version), 0) + 1 AS version
+	FROM link_destinations
+	WHERE link_id = sqlc.arg(link_id)::bigint
+), inserted AS (
+	INSERT INTO link_destinations (link_id, version, original_url)
+	SELECT sqlc.arg(link_id)::bigint, version, sqlc.arg(original_url)::text
+	FROM next_version
+	RETURNING version
+)
+UPDATE links
+SET destination_version = inserted.version
+FROM inserted
+WHERE links.id = sqlc.arg(link_id)::bigint
+RETURNING inserted.version;
+
+-- name: ListLinkDestinations :many
+SELECT d.version, d.original_url, d.created_at,
+	(d.version = l.destination_version)::bool AS active,
+	(SELECT COUNT(*) FROM link_visits v WHERE v.link_id = d.link_id AND v.destination_version = d.version)::bigint AS clicks
+FROM link_destinations d
+JOIN links l ON l.id = d.link_id
+WHERE d.link_id = $1
+ORDER BY d.version DESC;
+
+-- name: RollbackLinkDestination :execrows
+UPDATE links
+SET original_url = d.original_url, destination_version = d.version
+FROM link_destinations d
+WHERE links.id = sqlc.arg(link_id)::bigint
+AND links.deleted_at IS NULL
+AND d.link_id = links.id
+AND d.version = sqlc.arg(version)::int;
@@ -0,0 +1,18 @@
+-- name: ListFolders :many
+SELECT f.id, f.name, f.created_at,
+	(SELECT COUNT(*) FROM links l WHERE l.folder_id = f.id AND l.deleted_at IS NULL)::bigint AS links
+FROM folders f
+ORDER BY f.name;
+
+-- name: CreateFolder :one
+INSERT INTO folders (name) VALUES ($1)
+RETURNING id, name, created_at;
+
+-- name: UpdateFolder :execrows
+UPDATE folders
+SET name = $2
+WHERE id = $1;
+
+-- name: DeleteFolder :execrows
+DELETE FROM folders
+WHERE id = $1;
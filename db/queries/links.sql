@@ -1,25 +1,57 @@
 -- name: GetLink :one
-SELECT id, original_url, short_name, short_url
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
 FROM links
-WHERE id = $1 LIMIT 1;
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetLinkForUpdate :one
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
+FROM links
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+FOR UPDATE;
 
 -- name: ListLinks :many
-SELECT id, original_url, short_name, short_url 
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
 FROM links
-ORDER BY id
-LIMIT $1 OFFSET $2;
+WHERE deleted_at IS NULL
+AND (sqlc.narg(short_name_prefix)::text IS NULL OR short_name LIKE sqlc.narg(short_name_prefix)::text || '%')
+AND (sqlc.narg(domain)::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || sqlc.narg(domain)::text || '%')
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(ids)::bigint[] IS NULL OR id = ANY(sqlc.narg(ids)::bigint[]))
+AND (sqlc.narg(search)::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', sqlc.narg(search)::text)
+	OR original_url ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR short_name ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR title ILIKE '%' || sqlc.narg(search_pattern)::text || '%')
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = sqlc.narg(tag)::text))
+AND (sqlc.narg(folder_id)::bigint IS NULL OR folder_id = sqlc.narg(folder_id)::bigint)
+ORDER BY
+	CASE WHEN sqlc.arg(sort_field)::text = 'original_url' AND NOT sqlc.arg(sort_desc)::bool THEN original_url END ASC,
+	CASE WHEN sqlc.arg(sort_field)::text = 'original_url' AND sqlc.arg(sort_desc)::bool THEN original_url END DESC,
+	CASE WHEN sqlc.arg(sort_field)::text = 'short_name' AND NOT sqlc.arg(sort_desc)::bool THEN short_name END ASC,
+	CASE WHEN sqlc.arg(sort_field)::text = 'short_name' AND sqlc.arg(sort_desc)::bool THEN short_name END DESC,
+	CASE WHEN sqlc.arg(sort_field)::text = 'created_at' AND NOT sqlc.arg(sort_desc)::bool THEN created_at END ASC,
+	CASE WHEN sqlc.arg(sort_field)::text = 'created_at' AND sqlc.arg(sort_desc)::bool THEN created_at END DESC,
+	CASE WHEN sqlc.arg(sort_desc)::bool THEN id END DESC,
+	id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- name: CreateLink :one
 INSERT INTO links (
-original_url, short_name
+original_url, short_name, title, folder_id
 ) VALUES (
-$1, $2 
+$1, $2, $3, $4
 )
-RETURNING id, original_url, short_name, short_url, created_at;
+RETURNING id, original_url, short_name, short_url, created_at, title, deleted_at, destination_version, folder_id;
 
 -- name: UpdateLink :exec
 UPDATE links
-SET original_url = $2, short_name = $3
+SET original_url = $2, short_name = $3, title = $4, folder_id = $5
 WHERE id = $1;
 
 -- name: UpdateShortName :exec
@@ -27,12 +59,28 @@ UPDATE links
 SET short_url = $2
 WHERE id = $1;
 
--- name: DeleteLink :exec
-DELETE FROM links
-WHERE id = $1;
+-- name: SoftDeleteLink :execrows
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: CounterLinks :one
-SELECT COUNT(*) FROM links;
+SELECT COUNT(*) FROM links
+WHERE deleted_at IS NULL
+AND (sqlc.narg(short_name_prefix)::text IS NULL OR short_name LIKE sqlc.narg(short_name_prefix)::text || '%')
+AND (sqlc.narg(domain)::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || sqlc.narg(domain)::text || '%')
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(ids)::bigint[] IS NULL OR id = ANY(sqlc.narg(ids)::bigint[]))
+AND (sqlc.narg(search)::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', sqlc.narg(search)::text)
+	OR original_url ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR short_name ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR title ILIKE '%' || sqlc.narg(search_pattern)::text || '%')
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = sqlc.narg(tag)::text))
+AND (sqlc.narg(folder_id)::bigint IS NULL OR folder_id = sqlc.narg(folder_id)::bigint);
 
 -- name: LastLink :one
 SELECT * FROM links
@@ -41,22 +89,128 @@ LIMIT 1;
 
 -- name: CreateLinkVisits :one
 INSERT INTO link_visits (
-link_id, ip, user_agent, referer, status
+link_id, ip, user_agent, referer, status, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
 ) VALUES (
-$1, $2, $3, $4, $5
+$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
 )
-RETURNING id, link_id, ip, user_agent, referer, status, created_at;
+RETURNING id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version;
 
 -- name: ListLinkVisits :many
-SELECT id, link_id, created_at, ip, user_agent, status
+SELECT id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
 FROM link_visits
-ORDER BY id
-LIMIT $1 OFFSET $2;
+WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(status)::int IS NULL OR status = sqlc.narg(status)::int)
+AND (sqlc.narg(referer)::text IS NULL OR referer ILIKE '%' || sqlc.narg(referer)::text || '%')
+AND (sqlc.narg(user_agent)::text IS NULL OR user_agent ILIKE '%' || sqlc.narg(user_agent)::text || '%')
+ORDER BY
+	CASE WHEN sqlc.arg(sort_field)::text = 'created_at' AND NOT sqlc.arg(sort_desc)::bool THEN created_at END ASC,
+	CASE WHEN sqlc.arg(sort_field)::text = 'created_at' AND sqlc.arg(sort_desc)::bool THEN created_at END DESC,
+	CASE WHEN sqlc.arg(sort_desc)::bool THEN id END DESC,
+	id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- name: GetLinkFromCode :one
-SELECT id, original_url
+SELECT id, original_url, deleted_at, destination_version
 FROM links 
 WHERE short_name = $1;
 
 -- name: CounterVisits :one
-SELECT COUNT(*) FROM link_visits;
\ No newline at end of file
+SELECT COUNT(*) FROM link_visits
+WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(status)::int IS NULL OR status = sqlc.narg(status)::int)
+AND (sqlc.narg(referer)::text IS NULL OR referer ILIKE '%' || sqlc.narg(referer)::text || '%')
+AND (sqlc.narg(user_agent)::text IS NULL OR user_agent ILIKE '%' || sqlc.narg(user_agent)::text || '%');
+
+-- name: SearchLinks :many
+SELECT id, original_url, short_name, short_url, title,
+	(ts_rank(to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')), websearch_to_tsquery('simple', sqlc.arg(search)::text))
+	+ GREATEST(
+		similarity(original_url, sqlc.arg(search)::text),
+		similarity(COALESCE(short_name, ''), sqlc.arg(search)::text),
+		similarity(COALESCE(title, ''), sqlc.arg(search)::text)
+	))::real AS rank,
+	COUNT(*) OVER ()::bigint AS total
+FROM links
+WHERE deleted_at IS NULL
+AND (to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', sqlc.arg(search)::text)
+	OR original_url ILIKE '%' || sqlc.arg(search_pattern)::text || '%'
+	OR short_name ILIKE '%' || sqlc.arg(search_pattern)::text || '%'
+	OR title ILIKE '%' || sqlc.arg(search_pattern)::text || '%')
+ORDER BY rank DESC, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ListLinksByCursorAsc :many
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
+FROM links
+WHERE deleted_at IS NULL
+AND (sqlc.narg(short_name_prefix)::text IS NULL OR short_name LIKE sqlc.narg(short_name_prefix)::text || '%')
+AND (sqlc.narg(domain)::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || sqlc.narg(domain)::text || '%')
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(ids)::bigint[] IS NULL OR id = ANY(sqlc.narg(ids)::bigint[]))
+AND (sqlc.narg(search)::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', sqlc.narg(search)::text)
+	OR original_url ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR short_name ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR title ILIKE '%' || sqlc.narg(search_pattern)::text || '%')
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = sqlc.narg(tag)::text))
+AND (sqlc.narg(folder_id)::bigint IS NULL OR folder_id = sqlc.narg(folder_id)::bigint)
+AND id > sqlc.arg(cursor_id)::bigint
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: ListLinksByCursorDesc :many
+SELECT id, original_url, short_name, short_url, title, folder_id,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id), '{}')::text[] AS tags
+FROM links
+WHERE deleted_at IS NULL
+AND (sqlc.narg(short_name_prefix)::text IS NULL OR short_name LIKE sqlc.narg(short_name_prefix)::text || '%')
+AND (sqlc.narg(domain)::text IS NULL OR split_part(split_part(original_url, '://', 2), '/', 1) ILIKE '%' || sqlc.narg(domain)::text || '%')
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(ids)::bigint[] IS NULL OR id = ANY(sqlc.narg(ids)::bigint[]))
+AND (sqlc.narg(search)::text IS NULL
+	OR to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')) @@ websearch_to_tsquery('simple', sqlc.narg(search)::text)
+	OR original_url ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR short_name ILIKE '%' || sqlc.narg(search_pattern)::text || '%'
+	OR title ILIKE '%' || sqlc.narg(search_pattern)::text || '%')
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = sqlc.narg(tag)::text))
+AND (sqlc.narg(folder_id)::bigint IS NULL OR folder_id = sqlc.narg(folder_id)::bigint)
+AND id < sqlc.arg(cursor_id)::bigint
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListLinkVisitsByCursorAsc :many
+SELECT id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
+FROM link_visits
+WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(status)::int IS NULL OR status = sqlc.narg(status)::int)
+AND (sqlc.narg(referer)::text IS NULL OR referer ILIKE '%' || sqlc.narg(referer)::text || '%')
+AND (sqlc.narg(user_agent)::text IS NULL OR user_agent ILIKE '%' || sqlc.narg(user_agent)::text || '%')
+AND id > sqlc.arg(cursor_id)::bigint
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: ListLinkVisitsByCursorDesc :many
+SELECT id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version
+FROM link_visits
+WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from)::timestamptz)
+AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at <= sqlc.narg(created_to)::timestamptz)
+AND (sqlc.narg(status)::int IS NULL OR status = sqlc.narg(status)::int)
+AND (sqlc.narg(referer)::text IS NULL OR referer ILIKE '%' || sqlc.narg(referer)::text || '%')
+AND (sqlc.narg(user_agent)::text IS NULL OR user_agent ILIKE '%' || sqlc.narg(user_agent)::text || '%')
+AND id < sqlc.arg(cursor_id)::bigint
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
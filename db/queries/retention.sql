@@ -0,0 +1,26 @@
+-- name: RollupVisits :one
+WITH moved AS (
+	DELETE FROM link_visits
+	WHERE created_at < sqlc.arg(cutoff)::timestamptz
+	RETURNING link_id, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash
+), rolled AS (
+	INSERT INTO link_visit_daily (
+	link_id, day, browser, browser_version, os, device_type, is_bot, clicks, unique_clicks
+	)
+	SELECT link_id,
+		(created_at AT TIME ZONE 'UTC')::date,
+		COALESCE(browser, ''),
+		COALESCE(browser_version, ''),
+		COALESCE(os, ''),
+		COALESCE(device_type, ''),
+		is_bot,
+		COUNT(*),
+		COUNT(DISTINCT visitor_hash)
+	FROM moved
+	GROUP BY 1, 2, 3, 4, 5, 6, 7
+	ON CONFLICT (link_id, day, browser, browser_version, os, device_type, is_bot) DO UPDATE
+	SET clicks = link_visit_daily.clicks + EXCLUDED.clicks,
+		unique_clicks = link_visit_daily.unique_clicks + EXCLUDED.unique_clicks
+)
+SELECT COUNT(*) AS moved_visits
+FROM moved;
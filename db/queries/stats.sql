@@ -0,0 +1,62 @@
+-- name: CountVisits :one
+SELECT COALESCE(SUM(clicks), 0)::bigint AS clicks,
+	COALESCE(SUM(unique_clicks), 0)::bigint AS unique_clicks
+FROM (
+	SELECT COUNT(*) AS clicks,
+		COUNT(DISTINCT link_id::text || ':' || visitor_hash) AS unique_clicks
+	FROM link_visits
+	WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+	AND (sqlc.arg(include_bots)::bool OR NOT is_bot)
+	AND (sqlc.narg(period_start)::timestamptz IS NULL OR created_at >= sqlc.narg(period_start)::timestamptz)
+	AND (sqlc.narg(period_end)::timestamptz IS NULL OR created_at < sqlc.narg(period_end)::timestamptz)
+	UNION ALL
+	SELECT SUM(clicks) AS clicks,
+		SUM(unique_clicks) AS unique_clicks
+	FROM link_visit_daily
+	WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+	AND (sqlc.arg(include_bots)::bool OR NOT is_bot)
+	AND (sqlc.narg(period_start)::timestamptz IS NULL OR (day::timestamp AT TIME ZONE 'UTC') >= sqlc.narg(period_start)::timestamptz)
+	AND (sqlc.narg(period_end)::timestamptz IS NULL OR (day::timestamp AT TIME ZONE 'UTC') < sqlc.narg(period_end)::timestamptz)
+) AS totals;
+
+-- name: GroupVisits :many
+SELECT value::text AS value,
+	SUM(clicks)::bigint AS clicks,
+	SUM(unique_clicks)::bigint AS unique_clicks
+FROM (
+	SELECT (CASE sqlc.arg(dimension)::text
+			WHEN 'browser' THEN COALESCE(browser, '')
+			WHEN 'browser_version' THEN COALESCE(browser_version, '')
+			WHEN 'os' THEN COALESCE(os, '')
+			WHEN 'device_type' THEN COALESCE(device_type, '')
+			WHEN 'is_bot' THEN is_bot::text
+			ELSE ''
+		END) AS value,
+		COUNT(*) AS clicks,
+		COUNT(DISTINCT link_id::text || ':' || visitor_hash) AS unique_clicks
+	FROM link_visits
+	WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+	AND (sqlc.arg(include_bots)::bool OR NOT is_bot)
+	AND (sqlc.narg(period_start)::timestamptz IS NULL OR created_at >= sqlc.narg(period_start)::timestamptz)
+	AND (sqlc.narg(period_end)::timestamptz IS NULL OR created_at < sqlc.narg(period_end)::timestamptz)
+	GROUP BY 1
+	UNION ALL
+	SELECT (CASE sqlc.arg(dimension)::text
+			WHEN 'browser' THEN browser
+			WHEN 'browser_version' THEN browser_version
+			WHEN 'os' THEN os
+			WHEN 'device_type' THEN device_type
+			WHEN 'is_bot' THEN is_bot::text
+			ELSE ''
+		END) AS value,
+		SUM(clicks) AS clicks,
+		SUM(unique_clicks) AS unique_clicks
+	FROM link_visit_daily
+	WHERE (sqlc.narg(link_id)::bigint IS NULL OR link_id = sqlc.narg(link_id)::bigint)
+	AND (sqlc.arg(include_bots)::bool OR NOT is_bot)
+	AND (sqlc.narg(period_start)::timestamptz IS NULL OR (day::timestamp AT TIME ZONE 'UTC') >= sqlc.narg(period_start)::timestamptz)
+	AND (sqlc.narg(period_end)::timestamptz IS NULL OR (day::timestamp AT TIME ZONE 'UTC') < sqlc.narg(period_end)::timestamptz)
+	GROUP BY 1
+) AS grouped
+GROUP BY 1
+ORDER BY clicks DESC, value;
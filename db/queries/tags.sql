@@ -0,0 +1,66 @@
+-- name: ListTags :many
+SELECT t.id, t.name, t.created_at,
+	(SELECT COUNT(*) FROM link_tags lt JOIN links l ON l.id = lt.link_id WHERE lt.tag_id = t.id AND l.deleted_at IS NULL)::bigint AS links
+FROM tags t
+ORDER BY t.name;
+
+-- name: CreateTag :one
+INSERT INTO tags (name) VALUES ($1)
+RETURNING id, name, created_at;
+
+-- name: UpdateTag :execrows
+UPDATE tags
+SET name = $2
+WHERE id = $1;
+
+-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE id = $1;
+
+-- name: SetLinkTags :exec
+WITH created AS (
+	INSERT INTO tags (name)
+	SELECT DISTINCT unnest(sqlc.arg(names)::text[])
+	ON CONFLICT (name) DO NOTHING
+	RETURNING id
+), wanted AS (
+	SELECT id FROM tags WHERE name = ANY(sqlc.arg(names)::text[])
+	UNION
+	SELECT id FROM created
+), removed AS (
+	DELETE FROM link_tags
+	WHERE link_id = sqlc.arg(link_id)::bigint
+	AND tag_id NOT IN (SELECT id FROM wanted)
+)
+INSERT INTO link_tags (link_id, tag_id)
+SELECT sqlc.arg(link_id)::bigint, id FROM wanted
+ON CONFLICT DO NOTHING;
+
+-- name: TagStats :many
+SELECT t.id, t.name,
+	COUNT(DISTINCT lt.link_id)::bigint AS links,
+	COALESCE(SUM(v.clicks), 0)::bigint AS clicks,
+	COALESCE(SUM(v.unique_clicks), 0)::bigint AS unique_clicks
+FROM tags t
+LEFT JOIN link_tags lt ON lt.tag_id = t.id
+LEFT JOIN (
+	SELECT link_id,
+		COUNT(*) AS clicks,
+		COUNT(DISTINCT visitor_hash) AS unique_clicks
+	FROM link_visits
+	WHERE (sqlc.arg(include_bots)::bool OR NOT is_bot)
+	AND (sqlc.narg(period_start)::timestamptz IS NULL OR created_at >= sqlc.narg(period_start)::timestamptz)
+	AND (sqlc.narg(period_end)::timestamptz IS NULL OR created_at < sqlc.narg(period_end)::timestamptz)
+	GROUP BY link_id
+	UNION ALL
+	SELECT link_id,
+		SUM(clicks) AS clicks,
+		SUM(unique_clicks) AS unique_clicks
+	FROM link_visit_daily
+	WHERE (sqlc.arg(include_bots)::bool OR NOT is_bot)
+	AND (sqlc.narg(period_start)::timestamptz IS NULL OR (day::timestamp AT TIME ZONE 'UTC') >= sqlc.narg(period_start)::timestamptz)
+	AND (sqlc.narg(period_end)::timestamptz IS NULL OR (day::timestamp AT TIME ZONE 'UTC') < sqlc.narg(period_end)::timestamptz)
+	GROUP BY link_id
+) AS v ON v.link_id = lt.link_id
+GROUP BY t.id, t.name
+ORDER BY clicks DESC, t.name;
@@ -0,0 +1,19 @@
+-- name: ListDeletedLinks :many
+SELECT id, original_url, short_name, short_url, title, deleted_at
+FROM links
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: CounterDeletedLinks :one
+SELECT COUNT(*) FROM links
+WHERE deleted_at IS NOT NULL;
+
+-- name: RestoreLink :execrows
+UPDATE links
+SET deleted_at = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: PurgeDeletedLinks :execrows
+DELETE FROM links
+WHERE deleted_at IS NOT NULL AND deleted_at < $1;
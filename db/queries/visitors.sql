@@ -0,0 +1,16 @@
+-- name: CreateVisitorSalt :exec
+INSERT INTO visitor_salts (
+day, salt
+) VALUES (
+$1, $2
+)
+ON CONFLICT (day) DO NOTHING;
+
+-- name: GetVisitorSalt :one
+SELECT salt
+FROM visitor_salts
+WHERE day = $1;
+
+-- name: DeleteVisitorSaltsBefore :exec
+DELETE FROM visitor_salts
+WHERE day < $1;
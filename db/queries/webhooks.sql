@@ -0,0 +1,62 @@
+-- name: ListWebhooks :many
+SELECT id, url, secret, events, created_at
+FROM webhooks
+ORDER BY id;
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (
+url, secret, events
+) VALUES (
+$1, $2, $3
+)
+RETURNING id, url, secret, events, created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1;
+
+-- name: EnqueueWebhookEvent :execrows
+INSERT INTO webhook_deliveries (webhook_id, event, payload)
+SELECT id, sqlc.arg(event)::text, sqlc.arg(payload)::jsonb
+FROM webhooks
+WHERE sqlc.arg(event)::text = ANY(events);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = sqlc.arg(lease_until)::timestamptz
+FROM webhooks w
+WHERE w.id = d.webhook_id
+AND d.id IN (
+	SELECT id FROM webhook_deliveries
+	WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)::timestamptz
+	ORDER BY next_attempt_at, id
+	LIMIT sqlc.arg('limit')
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret;
+
+-- name: CompleteWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+	status = sqlc.arg(status)::text,
+	response_status = sqlc.narg(response_status)::int,
+	last_error = sqlc.narg(last_error)::text,
+	next_attempt_at = sqlc.arg(next_attempt_at)::timestamptz,
+	delivered_at = CASE WHEN sqlc.arg(status)::text = 'delivered' THEN CURRENT_TIMESTAMP END
+WHERE id = sqlc.arg(id)::bigint;
+
+-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CounterWebhookDeliveries :one
+SELECT COUNT(*) FROM webhook_deliveries
+WHERE webhook_id = $1;
+
+-- name: RedeliverWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND webhook_id = $2;
@@ -1,9 +1,21 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS folders (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS links (
 	id BIGSERIAL PRIMARY KEY,
 	original_url TEXT NOT NULL,
 	short_name VARCHAR(32) CHECK (CHAR_LENGTH(short_name) >= 3) UNIQUE,
 	short_url TEXT,
-	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	title VARCHAR(255),
+	deleted_at TIMESTAMP WITH TIME ZONE,
+	destination_version INT NOT NULL DEFAULT 1,
+	folder_id BIGINT REFERENCES folders(id) ON DELETE SET NULL
 );
 
 CREATE TABLE IF NOT EXISTS link_visits (
@@ -14,7 +26,128 @@ CREATE TABLE IF NOT EXISTS link_visits (
 	referer VARCHAR(500),
 	status INT,
 	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	browser VARCHAR(64),
+	browser_version VARCHAR(32),
+	os VARCHAR(64),
+	device_type VARCHAR(16),
+	is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+	visitor_hash VARCHAR(64),
+	destination_version INT,
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bot_signatures (
+	id BIGSERIAL PRIMARY KEY,
+	pattern VARCHAR(128) NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS visitor_salts (
+	day DATE PRIMARY KEY,
+	salt BYTEA NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS link_visits_created_at_idx ON link_visits (created_at);
+CREATE INDEX IF NOT EXISTS link_visits_link_id_idx ON link_visits (link_id, id);
+
+CREATE TABLE IF NOT EXISTS link_visit_daily (
+	link_id BIGINT NOT NULL,
+	day DATE NOT NULL,
+	browser VARCHAR(64) NOT NULL DEFAULT '',
+	browser_version VARCHAR(32) NOT NULL DEFAULT '',
+	os VARCHAR(64) NOT NULL DEFAULT '',
+	device_type VARCHAR(16) NOT NULL DEFAULT '',
+	is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+	clicks BIGINT NOT NULL DEFAULT 0,
+	unique_clicks BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (link_id, day, browser, browser_version, os, device_type, is_bot),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS links_search_idx ON links USING GIN (
+	to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(short_name, '') || ' ' || regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g'))
+);
+CREATE INDEX IF NOT EXISTS links_original_url_trgm_idx ON links USING GIN (original_url gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS links_short_name_trgm_idx ON links USING GIN (short_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS links_title_trgm_idx ON links USING GIN (title gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS links_deleted_at_idx ON links (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS link_audit (
+	id BIGSERIAL PRIMARY KEY,
+	link_id BIGINT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	actor VARCHAR(128),
+	ip VARCHAR(45),
+	changes JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS link_audit_link_id_idx ON link_audit (link_id);
+CREATE INDEX IF NOT EXISTS link_audit_actor_idx ON link_audit (actor);
+
+CREATE TABLE IF NOT EXISTS link_destinations (
+	id BIGSERIAL PRIMARY KEY,
+	link_id BIGINT NOT NULL,
+	version INT NOT NULL,
+	original_url TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (link_id, version),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id BIGSERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS link_tags (
+	link_id BIGINT NOT NULL,
+	tag_id BIGINT NOT NULL,
+	PRIMARY KEY (link_id, tag_id),
 	FOREIGN KEY (link_id) REFERENCES links(id)
 	ON DELETE CASCADE
+	ON UPDATE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS link_tags_tag_id_idx ON link_tags (tag_id);
+CREATE INDEX IF NOT EXISTS links_folder_id_idx ON links (folder_id);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret VARCHAR(128) NOT NULL,
+	events TEXT[] NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	webhook_id BIGINT NOT NULL,
+	event VARCHAR(32) NOT NULL,
+	payload JSONB NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	response_status INT,
+	last_error TEXT,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	delivered_at TIMESTAMP WITH TIME ZONE,
+	FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+	ON DELETE CASCADE
 	ON UPDATE CASCADE
-);
\ No newline at end of file
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id);
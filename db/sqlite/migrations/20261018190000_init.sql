@@ -0,0 +1,151 @@
+-- +goose Up
+-- +goose StatementBegin
+-- время хранится текстом в UTC (2006-01-02T15:04:05.000000Z),
+-- такие строки сравниваются как время
+CREATE TABLE IF NOT EXISTS folders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	created_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS links (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	original_url TEXT NOT NULL,
+	short_name TEXT CHECK (length(short_name) BETWEEN 3 AND 32) UNIQUE,
+	short_url TEXT,
+	created_at TEXT NOT NULL,
+	title TEXT,
+	deleted_at TEXT,
+	destination_version INTEGER NOT NULL DEFAULT 1,
+	folder_id INTEGER REFERENCES folders(id) ON DELETE SET NULL,
+	-- слова названия, короткого имени и адреса в нижнем регистре через пробел,
+	-- с пробелами по краям; заменяет полнотекстовый индекс Postgres
+	search_words TEXT NOT NULL DEFAULT '',
+	-- хост исходного адреса, как split_part(split_part(original_url, '://', 2), '/', 1)
+	host TEXT GENERATED ALWAYS AS (
+		CASE WHEN instr(original_url, '://') = 0 THEN ''
+		WHEN instr(substr(original_url, instr(original_url, '://') + 3), '/') = 0
+			THEN substr(original_url, instr(original_url, '://') + 3)
+		ELSE substr(original_url, instr(original_url, '://') + 3,
+			instr(substr(original_url, instr(original_url, '://') + 3), '/') - 1)
+		END
+	) VIRTUAL
+);
+
+CREATE INDEX IF NOT EXISTS links_folder_id_idx ON links (folder_id);
+
+CREATE TABLE IF NOT EXISTS link_visits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	link_id INTEGER NOT NULL,
+	ip TEXT,
+	user_agent TEXT,
+	referer TEXT,
+	status INTEGER,
+	created_at TEXT NOT NULL,
+	browser TEXT,
+	browser_version TEXT,
+	os TEXT,
+	device_type TEXT,
+	is_bot INTEGER NOT NULL DEFAULT 0,
+	visitor_hash TEXT,
+	destination_version INTEGER,
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS link_visits_link_id_idx ON link_visits (link_id);
+CREATE INDEX IF NOT EXISTS link_visits_created_at_idx ON link_visits (created_at);
+
+CREATE TABLE IF NOT EXISTS visitor_salts (
+	day TEXT PRIMARY KEY,
+	salt BLOB NOT NULL,
+	created_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS link_audit (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	link_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	actor TEXT,
+	ip TEXT,
+	changes TEXT NOT NULL DEFAULT '{}',
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS link_audit_link_id_idx ON link_audit (link_id);
+
+CREATE TABLE IF NOT EXISTS link_destinations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	link_id INTEGER NOT NULL,
+	version INTEGER NOT NULL,
+	original_url TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	UNIQUE (link_id, version),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	created_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS link_tags (
+	link_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (link_id, tag_id),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS link_tags_tag_id_idx ON link_tags (tag_id);
+
+-- события ставятся в очередь для подписанных вебхуков; events — JSON-массив
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL,
+	created_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id INTEGER NOT NULL,
+	event TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	response_status INTEGER,
+	last_error TEXT,
+	created_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	delivered_at TEXT,
+	FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS link_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS link_destinations;
+DROP TABLE IF EXISTS link_audit;
+DROP TABLE IF EXISTS visitor_salts;
+DROP TABLE IF EXISTS link_visits;
+DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS folders;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS bot_signatures (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	pattern TEXT NOT NULL UNIQUE,
+	created_at TEXT NOT NULL
+);
+
+-- дневные агрегаты свёрнутых переходов; day — дата UTC в формате 2006-01-02
+CREATE TABLE IF NOT EXISTS link_visit_daily (
+	link_id INTEGER NOT NULL,
+	day TEXT NOT NULL,
+	browser TEXT NOT NULL DEFAULT '',
+	browser_version TEXT NOT NULL DEFAULT '',
+	os TEXT NOT NULL DEFAULT '',
+	device_type TEXT NOT NULL DEFAULT '',
+	is_bot INTEGER NOT NULL DEFAULT 0,
+	clicks INTEGER NOT NULL DEFAULT 0,
+	unique_clicks INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (link_id, day, browser, browser_version, os, device_type, is_bot),
+	FOREIGN KEY (link_id) REFERENCES links(id)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+);
+
+-- очередь доставки: только ожидающие отправки записи
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+-- корзина и её очистка
+CREATE INDEX IF NOT EXISTS links_deleted_at_idx ON links (deleted_at) WHERE deleted_at IS NOT NULL;
+-- фильтр журнала по автору
+CREATE INDEX IF NOT EXISTS link_audit_actor_idx ON link_audit (actor);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS link_audit_actor_idx;
+DROP INDEX IF EXISTS links_deleted_at_idx;
+DROP INDEX IF EXISTS webhook_deliveries_pending_idx;
+DROP TABLE IF EXISTS link_visit_daily;
+DROP TABLE IF EXISTS bot_signatures;
+-- +goose StatementEnd
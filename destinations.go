@@ -0,0 +1,128 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// версия адреса ссылки для ответа API
+type destinationVersion struct {
+	Version     int32              `json:"version"`
+	OriginalUrl string             `json:"original_url"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	// true для версии, на которую сейчас ведёт ссылка
+	Active bool `json:"active"`
+	// переходы, пришедшиеся на версию. Переходы, свёрнутые
+	// в дневную статистику, версию не хранят и здесь не учитываются
+	Clicks int64 `json:"clicks"`
+}
+
+// сохранение адреса следующей версией и переключение ссылки на неё.
+// Номер версии считается по истории, поэтому у существующей ссылки
+// строка должна быть заблокирована через GetLinkForUpdate
+func addDestination(c *gin.Context, db Store, linkID int64, originalUrl string) (int32, error) {
+	var destParams generated.AddLinkDestinationParams
+	destParams.LinkID = linkID
+	destParams.OriginalUrl = originalUrl
+	return db.AddLinkDestination(c.Request.Context(), destParams)
+}
+
+// история адресов ссылки, последние версии первыми
+func linkHistory(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		if _, err := db.GetLink(c.Request.Context(), id); err != nil {
+			linkLookupError(c, err)
+			return
+		}
+		rows, err := db.ListLinkDestinations(c.Request.Context(), id)
+		if err != nil {
+			dbError(c, "unable to get destination history", err)
+			return
+		}
+		history := make([]destinationVersion, 0, len(rows))
+		for _, row := range rows {
+			history = append(history, destinationVersion{
+				Version:     row.Version,
+				OriginalUrl: row.OriginalUrl,
+				CreatedAt:   row.CreatedAt,
+				Active:      row.Active,
+				Clicks:      row.Clicks,
+			})
+		}
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+// возврат ссылки к одной из прежних версий адреса. Новая версия
+// не создаётся: ссылка снова ведёт на выбранную, и переходы
+// засчитываются ей
+func rollbackLink(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		version, err := strconv.ParseInt(c.Param("version"), 10, 32)
+		if err != nil || version < 1 {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "the version must be a positive number")
+			return
+		}
+		if _, err := db.GetLink(c.Request.Context(), id); err != nil {
+			linkLookupError(c, err)
+			return
+		}
+		var rollbackParams generated.RollbackLinkDestinationParams
+		rollbackParams.LinkID = id
+		rollbackParams.Version = int32(version)
+		var updated int64
+		var restored generated.GetLinkRow
+		err = db.InTx(c.Request.Context(), func(tx Store) error {
+			// состояние до возврата для журнала читается под блокировкой
+			locked, err := tx.GetLinkForUpdate(c.Request.Context(), id)
+			if err != nil {
+				return err
+			}
+			link := generated.GetLinkRow(locked)
+			updated, err = tx.RollbackLinkDestination(c.Request.Context(), rollbackParams)
+			if err != nil || updated == 0 {
+				return err
+			}
+			restored, err = tx.GetLink(c.Request.Context(), id)
+			if err != nil {
+				return failedStep("unable to get updated link", err)
+			}
+			if err := recordAudit(c, tx, id, auditRollback, snapshotFromRow(link), snapshotFromRow(restored)); err != nil {
+				return err
+			}
+			return enqueueWebhookEvent(c.Request.Context(), tx, eventLinkUpdated, restored)
+		})
+		// ссылку удалили, пока запрос ждал блокировки
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to roll back link", err)
+			return
+		}
+		if updated == 0 {
+			writeProblem(c, http.StatusNotFound, codeDestinationNotFound, "destination version not found")
+			return
+		}
+		c.JSON(http.StatusOK, restored)
+	}
+}
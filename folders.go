@@ -0,0 +1,132 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// список папок с числом ссылок
+func listFolders(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		folders, err := db.ListFolders(c.Request.Context())
+		if err != nil {
+			dbError(c, "unable to get folders", err)
+			return
+		}
+		if folders == nil {
+			folders = []generated.ListFoldersRow{}
+		}
+		c.JSON(http.StatusOK, folders)
+	}
+}
+
+// создание папки
+func createFolder(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req NameRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				errorsMap := make(map[string]string)
+				for _, e := range ve {
+					errorsMap[e.Field()] = e.Tag()
+				}
+				writeValidationProblem(c, errorsMap)
+				return
+			}
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			writeValidationProblem(c, map[string]string{"Name": "required"})
+			return
+		}
+		res, err := db.CreateFolder(c.Request.Context(), name)
+		if isUniqueViolation(err) {
+			writeProblem(c, http.StatusConflict, codeFolderExists, "folder already exists")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to create folder", err)
+			return
+		}
+		c.JSON(http.StatusCreated, res)
+	}
+}
+
+// переименование папки
+func updateFolder(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		var req NameRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				errorsMap := make(map[string]string)
+				for _, e := range ve {
+					errorsMap[e.Field()] = e.Tag()
+				}
+				writeValidationProblem(c, errorsMap)
+				return
+			}
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			writeValidationProblem(c, map[string]string{"Name": "required"})
+			return
+		}
+		var folderParams generated.UpdateFolderParams
+		folderParams.ID = id
+		folderParams.Name = name
+		updated, err := db.UpdateFolder(c.Request.Context(), folderParams)
+		if isUniqueViolation(err) {
+			writeProblem(c, http.StatusConflict, codeFolderExists, "folder already exists")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to update folder", err)
+			return
+		}
+		if updated == 0 {
+			writeProblem(c, http.StatusNotFound, codeFolderNotFound, "folder not found")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "name": name})
+	}
+}
+
+// удаление папки, её ссылки остаются без папки
+func deleteFolder(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		deleted, err := db.DeleteFolder(c.Request.Context(), id)
+		if err != nil {
+			dbError(c, "unable to delete folder", err)
+			return
+		}
+		if deleted == 0 {
+			writeProblem(c, http.StatusNotFound, codeFolderNotFound, "folder not found")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
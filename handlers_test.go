@@ -0,0 +1,845 @@
+package main
+
+import (
+	"bytes"
+	generated "code/db/generated"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// хранилища, на которых выполняются тесты обработчиков без Docker
+var testStores = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"memory", func(t *testing.T) Store { return newMemoryStore() }},
+}
+
+// запуск теста на каждом хранилище с тестовыми данными
+func forEachStore(t *testing.T, test func(t *testing.T, router *gin.Engine)) {
+	forEachSeededStore(t, func(t *testing.T, _ Store, router *gin.Engine) {
+		test(t, router)
+	})
+}
+
+// то же для тестов, которым нужно само хранилище, например для фоновых задач
+func forEachSeededStore(t *testing.T, test func(t *testing.T, store Store, router *gin.Engine)) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			store := ts.open(t)
+			seedStore(t, store)
+			// события записываются до закрытия хранилища
+			events := newWebhookEvents(store)
+			t.Cleanup(events.flush)
+			test(t, store, newStoreRouterWithEvents(store, events))
+		})
+	}
+}
+
+// ссылки exmpl, exmpl1 ... exmpl7 и по переходу на первые шесть, как в
+// тестовой базе Postgres
+func seedStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	visits := []struct{ userAgent, referer string }{
+		{"chrome", "www.yanex.ru"},
+		{"chrome", "www.mail.ru"},
+		{"mozilla", "www.vk.com"},
+		{"opera", "www.ya.ru"},
+		{"chrome", "www.rambler.ru"},
+		{"opera", "www.ersh.su"},
+	}
+	for i := range 8 {
+		name := "exmpl"
+		originalURL := "https://example.com/long-url"
+		if i > 0 {
+			name = fmt.Sprintf("exmpl%d", i)
+			originalURL = fmt.Sprintf("https://example.com/long-url%d", i)
+		}
+		var linkParams generated.CreateLinkParams
+		linkParams.OriginalUrl = originalURL
+		linkParams.ShortName = pgtype.Text{String: name, Valid: true}
+		link, err := store.CreateLink(ctx, linkParams)
+		require.NoError(t, err)
+		var destParams generated.AddLinkDestinationParams
+		destParams.LinkID = link.ID
+		destParams.OriginalUrl = link.OriginalUrl
+		_, err = store.AddLinkDestination(ctx, destParams)
+		require.NoError(t, err)
+		if i < len(visits) {
+			var visitParams generated.CreateLinkVisitsParams
+			visitParams.LinkID = link.ID
+			visitParams.Ip = pgtype.Text{String: fmt.Sprintf("192.168.10.%d", i+1), Valid: true}
+			visitParams.UserAgent = pgtype.Text{String: visits[i].userAgent, Valid: true}
+			visitParams.Referer = pgtype.Text{String: visits[i].referer, Valid: true}
+			visitParams.Status = pgtype.Int4{Int32: http.StatusFound, Valid: true}
+			_, err = store.CreateLinkVisits(ctx, visitParams)
+			require.NoError(t, err)
+		}
+	}
+}
+
+// маршруты сервиса поверх хранилища; остальные зависимости не нужны
+// обработчикам ссылок и переходов
+func newStoreRouter(store Store) *gin.Engine {
+	return newStoreRouterWithEvents(store, newWebhookEvents(store))
+}
+
+// маршруты сервиса с очередью событий, которую тест дожидается через flush
+func newStoreRouterWithEvents(store Store, events *webhookEvents) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := setupRouter()
+	registerRoutes(router, routeDeps{
+		store:   store,
+		bots:    newBotDetector(),
+		hub:     newVisitHub(),
+		events:  events,
+		metrics: newHTTPMetrics(),
+		workers: newWorkerRegistry(),
+	})
+	return router
+}
+
+func serve(router *gin.Engine, method string, target string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeList(t *testing.T, w *httptest.ResponseRecorder) []map[string]any {
+	t.Helper()
+	var items []map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+	return items
+}
+
+func TestStoreListLinks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		w := serve(router, http.MethodGet, "/api/links", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "links 0-7/8", w.Header().Get("Content-Range"))
+		assert.Len(t, decodeList(t, w), 8)
+
+		// страница, сортировка и фильтр
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"range": {"[5,15]"}}.Encode(), "")
+		assert.Equal(t, "links 5-7/8", w.Header().Get("Content-Range"))
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"sort": {`["short_name","DESC"]`}, "range": {"[0,1]"}}.Encode(), "")
+		links := decodeList(t, w)
+		if assert.Len(t, links, 2) {
+			assert.Equal(t, "exmpl7", links[0]["short_name"])
+			assert.Equal(t, "exmpl6", links[1]["short_name"])
+		}
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"filter": {`{"short_name":"exmpl1"}`}}.Encode(), "")
+		assert.Equal(t, "links 0-0/1", w.Header().Get("Content-Range"))
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"filter": {`{"q":"long-url3"}`}}.Encode(), "")
+		links = decodeList(t, w)
+		if assert.Len(t, links, 1) {
+			assert.Equal(t, "exmpl3", links[0]["short_name"])
+		}
+
+		// выборка по курсору
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"after": {""}, "limit": {"3"}}.Encode(), "")
+		assert.Len(t, decodeList(t, w), 3)
+		next := w.Header().Get(nextCursorHeader)
+		assert.NotEmpty(t, next)
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"after": {next}, "limit": {"3"}}.Encode(), "")
+		links = decodeList(t, w)
+		if assert.Len(t, links, 3) {
+			assert.Equal(t, float64(4), links[0]["id"])
+		}
+	})
+}
+
+func TestStoreCursorPages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		ids := func(w *httptest.ResponseRecorder) []float64 {
+			require.Equal(t, http.StatusOK, w.Code)
+			var result []float64
+			for _, item := range decodeList(t, w) {
+				result = append(result, item["id"].(float64))
+			}
+			return result
+		}
+		// вперёд по возрастанию и обратно по курсору предыдущей страницы
+		w := serve(router, http.MethodGet, "/api/links?after=&limit=3", "")
+		assert.Equal(t, []float64{1, 2, 3}, ids(w))
+		assert.Empty(t, w.Header().Get(prevCursorHeader))
+		w = serve(router, http.MethodGet, "/api/links?limit=3&after="+w.Header().Get(nextCursorHeader), "")
+		assert.Equal(t, []float64{4, 5, 6}, ids(w))
+		w = serve(router, http.MethodGet, "/api/links?limit=3&before="+w.Header().Get(prevCursorHeader), "")
+		assert.Equal(t, []float64{1, 2, 3}, ids(w))
+
+		// по убыванию: первая и последняя страницы
+		desc := url.Values{"sort": {`["id","DESC"]`}, "limit": {"3"}}
+		w = serve(router, http.MethodGet, "/api/links?after=&"+desc.Encode(), "")
+		assert.Equal(t, []float64{8, 7, 6}, ids(w))
+		w = serve(router, http.MethodGet, "/api/links?before=&"+desc.Encode(), "")
+		assert.Equal(t, []float64{3, 2, 1}, ids(w))
+		assert.Equal(t, encodeCursor(3), w.Header().Get(prevCursorHeader))
+
+		w = serve(router, http.MethodGet, "/api/link_visits?limit=4&after="+encodeCursor(2), "")
+		assert.Equal(t, []float64{3, 4, 5, 6}, ids(w))
+		assert.Empty(t, w.Header().Get(nextCursorHeader))
+		w = serve(router, http.MethodGet, "/api/link_visits?limit=4&before="+encodeCursor(2)+"&"+url.Values{"sort": {`["id","DESC"]`}}.Encode(), "")
+		assert.Equal(t, []float64{6, 5, 4, 3}, ids(w))
+	})
+}
+
+func TestStoreLinkLifecycle(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// создание с сгенерированным именем и метками
+		w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","tags":["Go","go","news"]}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var created map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, float64(9), created["id"])
+		assert.NotEmpty(t, created["short_name"])
+		assert.Equal(t, []any{"go", "news"}, created["tags"])
+
+		// изменение адреса и имени
+		w = serve(router, http.MethodPut, "/api/links/9", `{"original_url":"https://example.com/changed","short_name":"changed"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/9", "")
+		var link map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		assert.Equal(t, "https://example.com/changed", link["original_url"])
+		assert.Equal(t, "changed", link["short_name"])
+		assert.Equal(t, "https://go-project-278-yoao.onrender.com/r/changed", link["short_url"])
+		assert.Equal(t, []any{}, link["tags"])
+
+		// занятое имя и несуществующая папка
+		w = serve(router, http.MethodPut, "/api/links/9", `{"original_url":"https://example.com/changed","short_name":"exmpl"}`)
+		assertProblem(t, w, http.StatusConflict, codeShortNameTaken, `short name "exmpl" is already taken`)
+		w = serve(router, http.MethodPut, "/api/links/9", `{"original_url":"https://example.com/changed","short_name":"changed","folder_id":999}`)
+		assertProblem(t, w, http.StatusUnprocessableEntity, codeUnknownFolder, "folder does not exist")
+
+		// удаление в корзину
+		w = serve(router, http.MethodDelete, "/api/links/9", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/9", "")
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+		w = serve(router, http.MethodDelete, "/api/links/9", "")
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+		w = serve(router, http.MethodGet, "/r/changed", "")
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+	})
+}
+
+func TestStoreRestoreAndRollback(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// восстановление из корзины
+		w := serve(router, http.MethodPost, "/api/links/2/restore", "")
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found in trash")
+		w = serve(router, http.MethodDelete, "/api/links/2", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = serve(router, http.MethodPost, "/api/links/2/restore", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodGet, "/r/exmpl1", "")
+		assert.Equal(t, http.StatusFound, w.Code)
+
+		// возврат к первой версии адреса
+		w = serve(router, http.MethodPut, "/api/links/3", `{"original_url":"https://example.com/moved","short_name":"exmpl2"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodPost, "/api/links/3/rollback/3", "")
+		assertProblem(t, w, http.StatusNotFound, codeDestinationNotFound, "destination version not found")
+		w = serve(router, http.MethodPost, "/api/links/3/rollback/1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var link map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		assert.Equal(t, "https://example.com/long-url2", link["original_url"])
+		w = serve(router, http.MethodGet, "/r/exmpl2", "")
+		assert.Equal(t, "https://example.com/long-url2", w.Header().Get("Location"))
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"filter": {`{"q":"moved"}`}}.Encode(), "")
+		assert.Empty(t, decodeList(t, w))
+	})
+}
+
+func TestStoreInTx(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := ts.open(t)
+			seedStore(t, store)
+			errAbort := errors.New("abort")
+			err := store.InTx(ctx, func(tx Store) error {
+				var linkParams generated.CreateLinkParams
+				linkParams.OriginalUrl = "https://example.com/tx"
+				linkParams.ShortName = pgtype.Text{String: "intx", Valid: true}
+				_, err := tx.CreateLink(ctx, linkParams)
+				require.NoError(t, err)
+				// ошибка в точке сохранения отменяет только её изменения
+				err = tx.InTx(ctx, func(nested Store) error {
+					_, err := nested.SoftDeleteLink(ctx, 1)
+					require.NoError(t, err)
+					_, err = nested.CreateLink(ctx, linkParams)
+					return err
+				})
+				assert.True(t, isUniqueViolation(err))
+				_, err = tx.GetLink(ctx, 1)
+				require.NoError(t, err)
+				_, err = tx.SoftDeleteLink(ctx, 2)
+				require.NoError(t, err)
+				return errAbort
+			})
+			assert.ErrorIs(t, err, errAbort)
+			// ошибка транзакции отменяет все изменения
+			_, err = store.GetLinkFromCode(ctx, pgtype.Text{String: "intx", Valid: true})
+			assert.ErrorIs(t, err, pgx.ErrNoRows)
+			_, err = store.GetLink(ctx, 2)
+			assert.NoError(t, err)
+
+			err = store.InTx(ctx, func(tx Store) error {
+				_, err := tx.SoftDeleteLink(ctx, 2)
+				return err
+			})
+			assert.NoError(t, err)
+			_, err = store.GetLink(ctx, 2)
+			assert.ErrorIs(t, err, pgx.ErrNoRows)
+		})
+	}
+}
+
+func TestStoreFolders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// создание папки, повторное имя занято
+		w := serve(router, http.MethodPost, "/api/folders", `{"name":"Marketing"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var folder map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &folder))
+		assert.Equal(t, float64(1), folder["id"])
+		w = serve(router, http.MethodPost, "/api/folders", `{"name":"Marketing"}`)
+		assertProblem(t, w, http.StatusConflict, codeFolderExists, "folder already exists")
+
+		// ссылка в папке и фильтр списка по папке
+		w = serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/promo","short_name":"promo","folder_id":1}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = serve(router, http.MethodGet, "/api/links?"+url.Values{"filter": {`{"folder_id":1}`}}.Encode(), "")
+		links := decodeList(t, w)
+		if assert.Len(t, links, 1) {
+			assert.Equal(t, "promo", links[0]["short_name"])
+			assert.Equal(t, float64(1), links[0]["folder_id"])
+		}
+
+		// переименование и список с числом ссылок
+		w = serve(router, http.MethodPost, "/api/folders", `{"name":"Archive"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = serve(router, http.MethodPut, "/api/folders/1", `{"name":"Archive"}`)
+		assertProblem(t, w, http.StatusConflict, codeFolderExists, "folder already exists")
+		w = serve(router, http.MethodPut, "/api/folders/1", `{"name":"Promo"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodGet, "/api/folders", "")
+		folders := decodeList(t, w)
+		if assert.Len(t, folders, 2) {
+			assert.Equal(t, "Archive", folders[0]["name"])
+			assert.Equal(t, float64(0), folders[0]["links"])
+			assert.Equal(t, "Promo", folders[1]["name"])
+			assert.Equal(t, float64(1), folders[1]["links"])
+		}
+
+		// удаление папки оставляет ссылку без папки
+		w = serve(router, http.MethodDelete, "/api/folders/1", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = serve(router, http.MethodDelete, "/api/folders/1", "")
+		assertProblem(t, w, http.StatusNotFound, codeFolderNotFound, "folder not found")
+		w = serve(router, http.MethodGet, "/api/links/9", "")
+		var link map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		assert.Nil(t, link["folder_id"])
+		w = serve(router, http.MethodPut, "/api/folders/1", `{"name":"Promo"}`)
+		assertProblem(t, w, http.StatusNotFound, codeFolderNotFound, "folder not found")
+	})
+}
+
+func TestStoreRedirectAndVisits(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		req := httptest.NewRequest(http.MethodGet, "/r/exmpl3", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile/15E148 Safari/604.1")
+		req.Header.Set("Referer", "https://news.example.org/")
+		req.Header.Set("Accept-Language", "ru-RU")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/long-url3", w.Header().Get("Location"))
+
+		// неизвестное имя
+		w = serve(router, http.MethodGet, "/r/noname", "")
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+
+		// переход записан с версией адреса и разбором User-Agent
+		w = serve(router, http.MethodGet, "/api/link_visits", "")
+		assert.Equal(t, "link_visits 0-6/7", w.Header().Get("Content-Range"))
+		w = serve(router, http.MethodGet, "/api/links/4/visits?"+url.Values{"sort": {`["id","DESC"]`}}.Encode(), "")
+		visits := decodeList(t, w)
+		if assert.Len(t, visits, 2) {
+			assert.Equal(t, "mobile", visits[0]["device_type"])
+			assert.Equal(t, "https://news.example.org/", visits[0]["referer"])
+			assert.Equal(t, float64(1), visits[0]["destination_version"])
+		}
+		w = serve(router, http.MethodGet, "/api/link_visits?"+url.Values{"filter": {`{"referer":"NEWS.example"}`}}.Encode(), "")
+		assert.Len(t, decodeList(t, w), 1)
+		w = serve(router, http.MethodGet, "/api/links/99/visits", "")
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+	})
+}
+
+func TestStoreSearchLinks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://docs.example.org/reports/q3","short_name":"rprt","title":"Quarterly report"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = serve(router, http.MethodGet, "/api/links/search?"+url.Values{"q": {"report"}}.Encode(), "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "links 0-0/1", w.Header().Get("Content-Range"))
+		var results []linkSearchResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, int64(9), results[0].ID)
+			assert.Greater(t, results[0].Rank, float32(0))
+			assert.Equal(t, "Quarterly <mark>report</mark>", results[0].Highlights.Title)
+			assert.Equal(t, "https://docs.example.org/<mark>report</mark>s/q3", results[0].Highlights.OriginalUrl)
+		}
+
+		// подстрока короткого имени и пустой запрос
+		w = serve(router, http.MethodGet, "/api/links/search?"+url.Values{"q": {"xmpl"}}.Encode(), "")
+		assert.Equal(t, "links 0-7/8", w.Header().Get("Content-Range"))
+		w = serve(router, http.MethodGet, "/api/links/search?q=%20", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestStoreStats(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		w := serve(router, http.MethodGet, "/api/stats", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"clicks":6,"unique_clicks":0}`, w.Body.String())
+		w = serve(router, http.MethodGet, "/api/stats?group_by=ip", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// пользовательская сигнатура бота
+		w = serve(router, http.MethodPost, "/api/bot_signatures", `{"pattern":"LinkChecker"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = serve(router, http.MethodPost, "/api/bot_signatures", `{"pattern":"LinkChecker"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = serve(router, http.MethodGet, "/api/bot_signatures", "")
+		var signatures map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &signatures))
+		assert.Len(t, signatures["custom"], 1)
+		req := httptest.NewRequest(http.MethodGet, "/r/exmpl6", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; LinkChecker/1.0)")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/7/stats", "")
+		assert.JSONEq(t, `{"clicks":0,"unique_clicks":0}`, w.Body.String())
+		w = serve(router, http.MethodGet, "/api/links/7/stats?include_bots=true&group_by=is_bot", "")
+		var stats map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		assert.Equal(t, []any{map[string]any{"value": "true", "clicks": float64(1), "unique_clicks": float64(1)}}, stats["groups"])
+		w = serve(router, http.MethodGet, "/api/links/7/stats?to=2020-01-01", "")
+		assert.JSONEq(t, `{"clicks":0,"unique_clicks":0}`, w.Body.String())
+	})
+}
+
+func TestStoreVisitRollup(t *testing.T) {
+	forEachSeededStore(t, func(t *testing.T, store Store, router *gin.Engine) {
+		ctx := context.Background()
+		before := serve(router, http.MethodGet, "/api/links/3/stats?group_by=browser", "").Body.String()
+		moved, err := rollupVisits(ctx, store, 30, time.Now().AddDate(0, 0, 31))
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), moved)
+		// агрегаты дают ту же статистику и учитывают период
+		w := serve(router, http.MethodGet, "/api/links/3/stats?group_by=browser", "")
+		assert.JSONEq(t, before, w.Body.String())
+		today := time.Now().UTC().Format(time.DateOnly)
+		w = serve(router, http.MethodGet, "/api/links/3/stats?from="+today+"&to="+today, "")
+		assert.JSONEq(t, `{"clicks":1,"unique_clicks":0}`, w.Body.String())
+		w = serve(router, http.MethodGet, "/api/links/3/stats?to=2020-01-01", "")
+		assert.JSONEq(t, `{"clicks":0,"unique_clicks":0}`, w.Body.String())
+		moved, err = rollupVisits(ctx, store, 30, time.Now().AddDate(0, 0, 31))
+		require.NoError(t, err)
+		assert.Zero(t, moved)
+	})
+}
+
+func TestStoreTrashAndAudit(t *testing.T) {
+	forEachSeededStore(t, func(t *testing.T, store Store, router *gin.Engine) {
+		req := httptest.NewRequest(http.MethodDelete, "/api/links/2", nil)
+		req.Header.Set("X-Actor", "alice")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/trash", "")
+		assert.Equal(t, "links 0-0/1", w.Header().Get("Content-Range"))
+		trash := decodeList(t, w)
+		if assert.Len(t, trash, 1) {
+			assert.Equal(t, float64(2), trash[0]["id"])
+			assert.NotNil(t, trash[0]["deleted_at"])
+		}
+
+		// журнал по ссылке и автору
+		w = serve(router, http.MethodGet, "/api/audit?"+url.Values{"filter": {`{"link_id":2,"claimed_actor":"alice"}`}}.Encode(), "")
+		assert.Equal(t, "audit 0-0/1", w.Header().Get("Content-Range"))
+		entries := decodeList(t, w)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "delete", entries[0]["action"])
+			assert.Equal(t, "alice", entries[0]["claimed_actor"])
+		}
+		w = serve(router, http.MethodGet, "/api/audit?"+url.Values{"filter": {`{"claimed_actor":"bob"}`}}.Encode(), "")
+		assert.Empty(t, decodeList(t, w))
+
+		// очистка корзины
+		purged, err := purgeDeletedLinks(context.Background(), store, 30, time.Now())
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+		purged, err = purgeDeletedLinks(context.Background(), store, 30, time.Now().AddDate(0, 0, 31))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		w = serve(router, http.MethodPost, "/api/links/2/restore", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/trash", "")
+		assert.Empty(t, decodeList(t, w))
+	})
+}
+
+func TestStoreDestinationHistory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		w := serve(router, http.MethodPut, "/api/links/3", `{"original_url":"https://example.com/long-url2-v2","short_name":"exmpl2"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodGet, "/r/exmpl2", "")
+		assert.Equal(t, "https://example.com/long-url2-v2", w.Header().Get("Location"))
+		w = serve(router, http.MethodGet, "/api/links/3/history", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		history := decodeList(t, w)
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, float64(2), history[0]["version"])
+			assert.Equal(t, true, history[0]["active"])
+			assert.Equal(t, float64(1), history[0]["clicks"])
+			assert.Equal(t, "https://example.com/long-url2", history[1]["original_url"])
+			assert.Equal(t, false, history[1]["active"])
+		}
+		w = serve(router, http.MethodGet, "/api/links/99/history", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestStoreConcurrentDestinationEdits(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// параллельные изменения адреса получают разные версии, а не 409
+		const edits = 5
+		codes := make([]int, edits)
+		var wg sync.WaitGroup
+		for i := range edits {
+			wg.Go(func() {
+				body := fmt.Sprintf(`{"original_url":"https://example.com/edit%d","short_name":"exmpl2"}`, i)
+				codes[i] = serve(router, http.MethodPut, "/api/links/3", body).Code
+			})
+		}
+		wg.Wait()
+		for _, code := range codes {
+			assert.Equal(t, http.StatusOK, code)
+		}
+		w := serve(router, http.MethodGet, "/api/links/3/history", "")
+		history := decodeList(t, w)
+		if assert.Len(t, history, edits+1) {
+			assert.Equal(t, float64(edits+1), history[0]["version"])
+		}
+	})
+}
+
+func TestStoreConcurrentAuditDiffs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// каждое изменение сравнивается с состоянием, которое оно заменило
+		const edits = 5
+		var wg sync.WaitGroup
+		for i := range edits {
+			wg.Go(func() {
+				body := fmt.Sprintf(`{"original_url":"https://example.com/long-url","short_name":"exmpl","title":"title %d"}`, i)
+				assert.Equal(t, http.StatusOK, serve(router, http.MethodPut, "/api/links/1", body).Code)
+			})
+		}
+		wg.Wait()
+		w := serve(router, http.MethodGet, "/api/audit?"+url.Values{"filter": {`{"link_id":1}`}}.Encode(), "")
+		entries := decodeList(t, w)
+		require.Len(t, entries, edits)
+		// журнал отдаёт новые записи первыми
+		var after any
+		for i := len(entries) - 1; i >= 0; i-- {
+			title := entries[i]["changes"].(map[string]any)["title"].(map[string]any)
+			assert.Equal(t, after, title["before"])
+			after = title["after"]
+		}
+	})
+}
+
+func TestStoreTags(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/promo","short_name":"promo","tags":["Promo"," q3 ","promo"]}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		req := httptest.NewRequest(http.MethodGet, "/r/promo", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+		req.Header.Set("Accept-Language", "en-US")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		w = serve(router, http.MethodGet, "/api/stats/tags", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		stats := decodeList(t, w)
+		if assert.Len(t, stats, 2) {
+			assert.Equal(t, "promo", stats[0]["name"])
+			assert.Equal(t, float64(1), stats[0]["links"])
+			assert.Equal(t, float64(1), stats[0]["clicks"])
+		}
+
+		// создание, переименование и удаление метки
+		w = serve(router, http.MethodPost, "/api/tags", `{"name":"Sale"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var tag map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tag))
+		assert.Equal(t, "sale", tag["name"])
+		w = serve(router, http.MethodPost, "/api/tags", `{"name":"sale"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = serve(router, http.MethodPut, "/api/tags/1", `{"name":"campaign"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodPut, "/api/tags/1", `{"name":"q3"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/9", "")
+		var link map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		assert.Equal(t, []any{"campaign", "q3"}, link["tags"])
+		w = serve(router, http.MethodGet, "/api/tags", "")
+		tags := decodeList(t, w)
+		if assert.Len(t, tags, 3) {
+			assert.Equal(t, "campaign", tags[0]["name"])
+			assert.Equal(t, float64(1), tags[0]["links"])
+			assert.Equal(t, float64(0), tags[2]["links"])
+		}
+		w = serve(router, http.MethodDelete, "/api/tags/1", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = serve(router, http.MethodDelete, "/api/tags/1", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = serve(router, http.MethodGet, "/api/links/9", "")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		assert.Equal(t, []any{"q3"}, link["tags"])
+	})
+}
+
+func TestStoreWebhooks(t *testing.T) {
+	for _, ts := range testStores {
+		t.Run(ts.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := ts.open(t)
+			seedStore(t, store)
+			events := newWebhookEvents(store)
+			t.Cleanup(events.flush)
+			router := newStoreRouterWithEvents(store, events)
+			// получатель отвечает ошибкой на первый запрос
+			var received int
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received++
+				if received == 1 {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer receiver.Close()
+
+			w := serve(router, http.MethodPost, "/api/webhooks", `{"url":"https://crm.example.com/hooks","events":["link.created"]}`)
+			assert.Equal(t, http.StatusCreated, w.Code)
+			// локальный получатель не принимается API, поэтому он создаётся в хранилище
+			var webhookParams generated.CreateWebhookParams
+			webhookParams.Url = receiver.URL
+			webhookParams.Secret = "0123456789abcdef"
+			webhookParams.Events = []string{eventLinkVisited}
+			webhook, err := store.CreateWebhook(ctx, webhookParams)
+			require.NoError(t, err)
+			w = serve(router, http.MethodGet, "/api/webhooks", "")
+			webhooks := decodeList(t, w)
+			require.Len(t, webhooks, 2)
+
+			// переход ставит событие в очередь только подписанного вебхука
+			w = serve(router, http.MethodGet, "/r/exmpl5", "")
+			assert.Equal(t, http.StatusFound, w.Code)
+			events.flush()
+			dispatcher := newWebhookDispatcher(store, webhookConfig{AllowHTTP: true})
+			dispatcher.client = receiver.Client()
+			now := time.Now()
+			processed, err := dispatcher.dispatch(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, 1, processed)
+			// неудачная попытка откладывается
+			processed, err = dispatcher.dispatch(ctx, now)
+			require.NoError(t, err)
+			assert.Zero(t, processed)
+			deliveriesURL := fmt.Sprintf("/api/webhooks/%d/deliveries", webhook.ID)
+			w = serve(router, http.MethodGet, deliveriesURL, "")
+			deliveries := decodeList(t, w)
+			if assert.Len(t, deliveries, 1) {
+				assert.Equal(t, "pending", deliveries[0]["status"])
+				assert.Equal(t, float64(1), deliveries[0]["attempts"])
+				assert.Equal(t, float64(500), deliveries[0]["response_status"])
+			}
+			processed, err = dispatcher.dispatch(ctx, now.Add(time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, 1, processed)
+			w = serve(router, http.MethodGet, deliveriesURL, "")
+			deliveries = decodeList(t, w)
+			require.Len(t, deliveries, 1)
+			assert.Equal(t, "delivered", deliveries[0]["status"])
+			assert.NotNil(t, deliveries[0]["delivered_at"])
+
+			// ручная повторная отправка
+			w = serve(router, http.MethodPost, fmt.Sprintf("%s/%v/redeliver", deliveriesURL, deliveries[0]["id"]), "")
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			processed, err = dispatcher.dispatch(ctx, time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, 1, processed)
+			assert.Equal(t, 3, received)
+			w = serve(router, http.MethodPost, deliveriesURL+"/999/redeliver", "")
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			// события изменения ссылок пишутся в очередь в транзакции, без ожидания
+			w = serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"created"}`)
+			assert.Equal(t, http.StatusCreated, w.Code)
+			w = serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"created"}`)
+			assert.Equal(t, http.StatusConflict, w.Code)
+			w = serve(router, http.MethodGet, fmt.Sprintf("/api/webhooks/%v/deliveries", webhooks[0]["id"]), "")
+			created := decodeList(t, w)
+			if assert.Len(t, created, 1) {
+				assert.Equal(t, eventLinkCreated, created[0]["event"])
+			}
+
+			// удаление вебхука удаляет его очередь
+			w = serve(router, http.MethodDelete, fmt.Sprintf("/api/webhooks/%d", webhook.ID), "")
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			w = serve(router, http.MethodGet, deliveriesURL, "")
+			assert.Empty(t, decodeList(t, w))
+		})
+	}
+}
+
+func TestStoreShortNameConflicts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// введённое имя сохраняется
+		w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"custom"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var created map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, "custom", created["short_name"])
+		assert.Equal(t, "https://go-project-278-yoao.onrender.com/r/custom", created["short_url"])
+
+		// занятое имя при создании и переименовании: exmpl1 ... exmpl7 тоже заняты
+		w = serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"exmpl"}`)
+		body := assertProblem(t, w, http.StatusConflict, codeShortNameTaken, `short name "exmpl" is already taken`)
+		assert.Equal(t, "exmpl", body.ShortName)
+		assert.Equal(t, []string{"exmpl8", "exmpl9", "exmpl10"}, body.Suggestions)
+		w = serve(router, http.MethodPut, "/api/links/9", `{"original_url":"https://example.com/new","short_name":"exmpl3"}`)
+		body = assertProblem(t, w, http.StatusConflict, codeShortNameTaken, `short name "exmpl3" is already taken`)
+		assert.Equal(t, "exmpl3", body.ShortName)
+		assert.Equal(t, []string{"exmpl31", "exmpl32", "exmpl33"}, body.Suggestions)
+
+		// имя ссылки в корзине остаётся занятым
+		w = serve(router, http.MethodDelete, "/api/links/9", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"custom"}`)
+		assertProblem(t, w, http.StatusConflict, codeShortNameTaken, `short name "custom" is already taken`)
+	})
+}
+
+func TestStoreGeneratedShortNameConflicts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		rename := func(id int, name string) {
+			w := serve(router, http.MethodPut, fmt.Sprintf("/api/links/%d", id), `{"original_url":"https://example.com/renamed","short_name":"`+name+`"}`)
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+		// имена для следующих двух ID заняты вручную: сервер берёт следующее
+		rename(1, generateShortName(9))
+		rename(2, generateShortName(10))
+		w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created generated.Link
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, generateShortName(11), created.ShortName.String)
+
+		// все попытки заняты: ошибка сервера, а не 409 за имя, которого клиент не вводил.
+		// Часть имён уже занята, ID новой ссылки зависит от хранилища
+		id := 3
+		for i := range generatedShortNameAttempts {
+			name := generateShortName(created.ID + 1 + int64(i))
+			w := serve(router, http.MethodGet, "/api/short_names/"+name+"/availability", "")
+			if strings.Contains(w.Body.String(), `"available":true`) {
+				rename(id, name)
+				id++
+			}
+		}
+		w = serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new"}`)
+		assertProblem(t, w, http.StatusInternalServerError, codeInternal, "unable to create link")
+	})
+}
+
+func TestStoreShortNameValidation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		long := strings.Repeat("a", 33)
+		tests := []struct {
+			method string
+			target string
+			name   string
+			rule   string
+		}{
+			{http.MethodPost, "/api/links", "ab", "min"},
+			{http.MethodPost, "/api/links", long, "max"},
+			{http.MethodPut, "/api/links/1", "", "required"},
+			{http.MethodPut, "/api/links/1", "ab", "min"},
+			{http.MethodPut, "/api/links/1", long, "max"},
+		}
+		for _, tt := range tests {
+			w := serve(router, tt.method, tt.target, `{"original_url":"https://example.com/new","short_name":"`+tt.name+`"}`)
+			body := assertProblem(t, w, http.StatusUnprocessableEntity, codeValidationFailed, "request validation failed")
+			assert.Equal(t, map[string]string{"ShortName": tt.rule}, body.Errors, tt.method+" "+tt.name)
+		}
+		// имя в 3 и 32 символа проходит проверку
+		for _, name := range []string{"abc", strings.Repeat("b", 32)} {
+			w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"`+name+`"}`)
+			assert.Equal(t, http.StatusCreated, w.Code, name)
+		}
+	})
+}
+
+func TestStoreShortNameAvailability(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		availability := func(name string) shortNameAvailability {
+			w := serve(router, http.MethodGet, "/api/short_names/"+url.PathEscape(name)+"/availability", "")
+			require.Equal(t, http.StatusOK, w.Code)
+			var result shortNameAvailability
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			return result
+		}
+		assert.Equal(t, shortNameAvailability{ShortName: "free-name", Available: true, Suggestions: []string{}}, availability("free-name"))
+		assert.Equal(t, shortNameAvailability{ShortName: "exmpl", Available: false, Suggestions: []string{"exmpl8", "exmpl9", "exmpl10"}}, availability("exmpl"))
+		// варианты укорачиваются до 32 символов
+		long := strings.Repeat("a", 32)
+		w := serve(router, http.MethodPost, "/api/links", `{"original_url":"https://example.com/new","short_name":"`+long+`"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, []string{long[:31] + "1", long[:31] + "2", long[:31] + "3"}, availability(long).Suggestions)
+
+		for _, name := range []string{"ab", strings.Repeat("a", 33)} {
+			w := serve(router, http.MethodGet, "/api/short_names/"+name+"/availability", "")
+			assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "short name must be 3 to 32 characters long")
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// миграции, с которыми собран сервис; по ним определяется ожидаемая версия схемы
+//
+//go:embed db/migrations/*.sql
+var migrationFiles embed.FS
+
+// каталог миграций Postgres в migrationFiles
+const migrationsDir = "db/migrations"
+
+const (
+	// время на проверку зависимостей в /readyz
+	readinessTimeout = 2 * time.Second
+	// состояния фоновых задач
+	workerDisabled = "disabled"
+	workerStarting = "starting"
+	workerOK       = "ok"
+	workerFailing  = "failing"
+)
+
+// состояние фоновой задачи
+type workerState struct {
+	Status    string     `json:"status"`
+	Interval  string     `json:"interval,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// состояние фоновых задач процесса
+type workerRegistry struct {
+	mu      sync.Mutex
+	workers map[string]*workerState
+}
+
+func newWorkerRegistry() *workerRegistry {
+	return &workerRegistry{workers: make(map[string]*workerState)}
+}
+
+// задача отключена настройками
+func (r *workerRegistry) disabled(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workers[name] = &workerState{Status: workerDisabled}
+}
+
+// задача запущена, но ещё не отработала
+func (r *workerRegistry) started(name string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workers[name] = &workerState{Status: workerStarting, Interval: interval.String()}
+}
+
+// итог очередного запуска задачи
+func (r *workerRegistry) finished(name string, now time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.workers[name]
+	if !ok {
+		state = &workerState{}
+		r.workers[name] = state
+	}
+	state.LastRun = &now
+	state.Status = workerOK
+	state.LastError = ""
+	if err != nil {
+		state.Status = workerFailing
+		state.LastError = err.Error()
+	}
+}
+
+// копия состояний для ответа
+func (r *workerRegistry) snapshot() map[string]workerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := make(map[string]workerState, len(r.workers))
+	for name, state := range r.workers {
+		states[name] = *state
+	}
+	return states
+}
+
+// версия последней миграции каталога dir по префиксу имени файла, как в goose
+func expectedMigrationVersion(fsys fs.FS, dir string) (int64, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// версия миграции из префикса имени файла: 20261018090000_name.sql
+func migrationVersion(name string) (int64, error) {
+	prefix, _, _ := strings.Cut(path.Base(name), "_")
+	return strconv.ParseInt(prefix, 10, 64)
+}
+
+// запись таблицы goose_db_version
+type migrationRecord struct {
+	VersionID int64
+	IsApplied bool
+}
+
+// текущая версия схемы по записям goose от новых к старым: откат
+// миграции добавляет запись с is_applied = false, и такая версия
+// не считается применённой, даже если раньше применялась
+func appliedMigrationVersion(records []migrationRecord) int64 {
+	rolledBack := make(map[int64]bool)
+	for _, record := range records {
+		if rolledBack[record.VersionID] {
+			continue
+		}
+		if record.IsApplied {
+			return record.VersionID
+		}
+		rolledBack[record.VersionID] = true
+	}
+	return 0
+}
+
+// текущая версия схемы в БД. Таблица goose не входит в схему sqlc,
+// поэтому запрос выполняется напрямую
+func currentMigrationVersion(ctx context.Context, pool *pgxpool.Pool) (int64, error) {
+	rows, err := pool.Query(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var records []migrationRecord
+	for rows.Next() {
+		var record migrationRecord
+		if err := rows.Scan(&record.VersionID, &record.IsApplied); err != nil {
+			return 0, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return appliedMigrationVersion(records), nil
+}
+
+// БД, которую проверяет /readyz
+type readinessDB interface {
+	Ping(ctx context.Context) error
+	// текущая версия схемы
+	MigrationVersion(ctx context.Context) (int64, error)
+}
+
+// проверка готовности Postgres
+type postgresReadiness struct {
+	pool *pgxpool.Pool
+}
+
+func (p postgresReadiness) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+func (p postgresReadiness) MigrationVersion(ctx context.Context) (int64, error) {
+	return currentMigrationVersion(ctx, p.pool)
+}
+
+// результат проверки зависимости
+type healthCheck struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Current  *int64 `json:"current,omitempty"`
+	Expected *int64 `json:"expected,omitempty"`
+}
+
+// процесс жив и обрабатывает запросы
+func healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// готовность принимать трафик: БД доступна и схема не старше ожидаемой.
+// Состояние фоновых задач выводится для сведения и на готовность не влияет
+func readyz(db readinessDB, workers *workerRegistry, expectedVersion int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		ready := true
+
+		database := healthCheck{Status: "ok"}
+		if err := db.Ping(ctx); err != nil {
+			slog.ErrorContext(c.Request.Context(), "readiness: database ping failed", "error", err)
+			database = healthCheck{Status: "error", Error: "database is unreachable"}
+			ready = false
+		}
+
+		migrations := healthCheck{Status: "ok", Expected: &expectedVersion}
+		if database.Status == "ok" {
+			current, err := db.MigrationVersion(ctx)
+			switch {
+			case err != nil:
+				slog.ErrorContext(c.Request.Context(), "readiness: unable to read migration version", "error", err)
+				migrations.Status = "error"
+				migrations.Error = "unable to read migration version"
+				ready = false
+			case current < expectedVersion:
+				migrations.Status = "error"
+				migrations.Current = &current
+				migrations.Error = "database schema is behind the service"
+				ready = false
+			default:
+				migrations.Current = &current
+			}
+		} else {
+			migrations.Status = "unknown"
+		}
+
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{
+			"status": status,
+			"checks": gin.H{
+				"database":   database,
+				"migrations": migrations,
+			},
+			"workers": workers.snapshot(),
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedMigrationVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/migrations/20260524104410_add_link_and_visits_table.sql": {},
+		"db/migrations/20261018180000_add_webhooks_tables.sql":       {},
+		"db/migrations/20261018090000_add_visit_user_agent.sql":      {},
+	}
+	version, err := expectedMigrationVersion(fsys, migrationsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20261018180000), version)
+
+	// встроенные миграции сервиса
+	version, err = expectedMigrationVersion(migrationFiles, migrationsDir)
+	assert.NoError(t, err)
+	assert.Greater(t, version, int64(0))
+
+	_, err = expectedMigrationVersion(fstest.MapFS{"db/migrations/initial.sql": {}}, migrationsDir)
+	assert.Error(t, err)
+}
+
+func TestAppliedMigrationVersion(t *testing.T) {
+	// записи от новых к старым
+	assert.Equal(t, int64(3), appliedMigrationVersion([]migrationRecord{{3, true}, {2, true}, {1, true}, {0, true}}))
+	// миграция 3 откатана
+	assert.Equal(t, int64(2), appliedMigrationVersion([]migrationRecord{{3, false}, {3, true}, {2, true}, {0, true}}))
+	assert.Equal(t, int64(0), appliedMigrationVersion(nil))
+}
+
+func TestWorkerRegistry(t *testing.T) {
+	workers := newWorkerRegistry()
+	workers.disabled("visit_retention")
+	workers.started("webhook_dispatcher", 5*time.Second)
+	assert.Equal(t, workerState{Status: workerStarting, Interval: "5s"}, workers.snapshot()["webhook_dispatcher"])
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	workers.finished("webhook_dispatcher", now, errors.New("connection refused"))
+	state := workers.snapshot()["webhook_dispatcher"]
+	assert.Equal(t, workerFailing, state.Status)
+	assert.Equal(t, "connection refused", state.LastError)
+	assert.Equal(t, now, *state.LastRun)
+
+	workers.finished("webhook_dispatcher", now.Add(5*time.Second), nil)
+	state = workers.snapshot()["webhook_dispatcher"]
+	assert.Equal(t, workerOK, state.Status)
+	assert.Empty(t, state.LastError)
+	assert.Equal(t, workerDisabled, workers.snapshot()["visit_retention"].Status)
+}
@@ -0,0 +1,949 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var db *pgxpool.Pool
+var router *gin.Engine
+
+// запись событий вебхуков для маршрутов router
+var events *webhookEvents
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	// запуск контейнера PostgreSQL
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres:16-alpine",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_DB":       "testdb",
+			"POSTGRES_USER":     "test",
+			"POSTGRES_PASSWORD": "secret",
+		},
+		WaitingFor: wait.ForExposedPort().WithStartupTimeout(60 * time.Second),
+	}
+	pgCont, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		log.Fatalf("failed to start container: %v", err)
+	}
+	defer func() {
+		if err := pgCont.Terminate(ctx); err != nil {
+			log.Fatalf("context termination error")
+		}
+	}()
+	// получаем адрес и порт
+	mappedPort, _ := pgCont.MappedPort(ctx, "5432")
+	host, _ := pgCont.Host(ctx)
+	// подключение к БД
+	dsn := fmt.Sprintf("postgres://test:secret@%s:%s/testdb?sslmode=disable", host, mappedPort.Port())
+	db, err = pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		log.Fatalf("failed to connect to db: %v", err)
+	}
+	defer db.Close()
+	// применение миграций
+	_, err = db.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS pg_trgm;`)
+	if err != nil {
+		log.Fatalf("failed to create extension pg_trgm: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS folders (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, name VARCHAR(64) NOT NULL UNIQUE, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		log.Fatalf("failed to create table folders: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS links (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, original_url TEXT, short_name TEXT UNIQUE, short_url TEXT, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP, title VARCHAR(255), deleted_at TIMESTAMP WITH TIME ZONE, destination_version INT NOT NULL DEFAULT 1, folder_id BIGINT REFERENCES folders(id) ON DELETE SET NULL);`)
+	if err != nil {
+		log.Fatalf("failed to create table links: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS link_visits (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, link_id BIGINT NOT NULL, ip VARCHAR(45), user_agent VARCHAR(255), referer VARCHAR(500), status INT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, browser VARCHAR(64), browser_version VARCHAR(32), os VARCHAR(64), device_type VARCHAR(16), is_bot BOOLEAN NOT NULL DEFAULT FALSE, visitor_hash VARCHAR(64), destination_version INT);`)
+	if err != nil {
+		log.Fatalf("failed to create table link_visits: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS bot_signatures (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, pattern VARCHAR(128) NOT NULL UNIQUE, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		log.Fatalf("failed to create table bot_signatures: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS visitor_salts (day DATE PRIMARY KEY, salt BYTEA NOT NULL, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		log.Fatalf("failed to create table visitor_salts: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS link_visit_daily (link_id BIGINT NOT NULL, day DATE NOT NULL, browser VARCHAR(64) NOT NULL DEFAULT '', browser_version VARCHAR(32) NOT NULL DEFAULT '', os VARCHAR(64) NOT NULL DEFAULT '', device_type VARCHAR(16) NOT NULL DEFAULT '', is_bot BOOLEAN NOT NULL DEFAULT FALSE, clicks BIGINT NOT NULL DEFAULT 0, unique_clicks BIGINT NOT NULL DEFAULT 0, PRIMARY KEY (link_id, day, browser, browser_version, os, device_type, is_bot));`)
+	if err != nil {
+		log.Fatalf("failed to create table link_visit_daily: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS link_audit (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, link_id BIGINT NOT NULL, action VARCHAR(16) NOT NULL, actor VARCHAR(128), ip VARCHAR(45), changes JSONB NOT NULL DEFAULT '{}', created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		log.Fatalf("failed to create table link_audit: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS link_destinations (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, link_id BIGINT NOT NULL, version INT NOT NULL, original_url TEXT NOT NULL, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP, UNIQUE (link_id, version));`)
+	if err != nil {
+		log.Fatalf("failed to create table link_destinations: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS tags (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, name VARCHAR(64) NOT NULL UNIQUE, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		log.Fatalf("failed to create table tags: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS link_tags (link_id BIGINT NOT NULL, tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE, PRIMARY KEY (link_id, tag_id));`)
+	if err != nil {
+		log.Fatalf("failed to create table link_tags: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS webhooks (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, url TEXT NOT NULL, secret VARCHAR(128) NOT NULL, events TEXT[] NOT NULL, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		log.Fatalf("failed to create table webhooks: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS webhook_deliveries (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE, event VARCHAR(32) NOT NULL, payload JSONB NOT NULL, status VARCHAR(16) NOT NULL DEFAULT 'pending', attempts INT NOT NULL DEFAULT 0, next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, response_status INT, last_error TEXT, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP, delivered_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatalf("failed to create table webhook_deliveries: %v", err)
+	}
+	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (id SERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN NOT NULL, tstamp TIMESTAMP DEFAULT now());`)
+	if err != nil {
+		log.Fatalf("failed to create table goose_db_version: %v", err)
+	}
+	// схема тестовой БД соответствует последней миграции
+	migrationVersion, err := expectedMigrationVersion(migrationFiles, migrationsDir)
+	if err != nil {
+		log.Fatalf("unable to read embedded migrations: %v", err)
+	}
+	_, err = db.Exec(ctx, "INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true), ($1, true)", migrationVersion)
+	if err != nil {
+		log.Fatalf("error adding data to table goose_db_version: %v", err)
+	}
+	// добавление тестовых данных в таблицу links
+	_, err = db.Exec(ctx, "INSERT INTO links (original_url, short_name) VALUES ('https://example.com/long-url', 'exmpl'), ('https://example.com/long-url1', 'exmpl1'), ('https://example.com/long-url2', 'exmpl2'), ('https://example.com/long-url3', 'exmpl3'), ('https://example.com/long-url4', 'exmpl4'), ('https://example.com/long-url5', 'exmpl5'), ('https://example.com/long-url6', 'exmpl6'), ('https://example.com/long-url7', 'exmpl7')")
+	if err != nil {
+		log.Fatalf("error adding data to table links: %v", err)
+	}
+	// первые версии адресов тестовых ссылок
+	_, err = db.Exec(ctx, "INSERT INTO link_destinations (link_id, version, original_url) SELECT id, 1, original_url FROM links")
+	if err != nil {
+		log.Fatalf("error adding data to table link_destinations: %v", err)
+	}
+	// фиксируем точное время для теста
+	fixedTime := time.Date(2026, 5, 26, 12, 35, 0, 0, time.UTC)
+
+	// добавление тестовых данных в таблицу link_visits
+	_, err = db.Exec(ctx, "INSERT INTO link_visits (link_id, ip, user_agent, referer, status, created_at) VALUES (1, '192.168.10.1', 'chrome', 'www.yanex.ru', 302, $1), (2, '192.168.10.2', 'chrome', 'www.mail.ru', 302, $1), (3, '192.168.10.3', 'mozilla', 'www.vk.com', 302, $1), (4, '192.168.10.4', 'opera', 'www.ya.ru', 302, $1), (5, '192.168.10.5', 'chrome', 'www.rambler.ru', 302, $1), (6, '192.168.10.6', 'opera', 'www.ersh.su', 302, $1)", fixedTime)
+	if err != nil {
+		log.Fatalf("error adding data to table link_visits: %v", err)
+	}
+
+	// тесты обработчиков выполняются и на Postgres, каждый в своей базе
+	testStores = append(testStores, struct {
+		name string
+		open func(t *testing.T) Store
+	}{"postgres", func(t *testing.T) Store { return openTestPostgresStore(t, dsn) }})
+
+	// инициализация Gin
+	store := newPostgresStore(db)
+	events = newWebhookEvents(store)
+	gin.SetMode(gin.TestMode)
+	bots := newBotDetector()
+	router = setupRouter()
+	metrics := newHTTPMetrics()
+	router.Use(metrics.middleware())
+	// регистрация маршрутов
+	router.GET("/api/links", listLinks(store))
+	router.GET("/api/links/search", searchLinks(store))
+	router.GET("/api/links/:id", getLinkFromId(store))
+	router.POST("/api/links", createLink(store))
+	router.PUT("/api/links/:id", updateLink(store))
+	router.DELETE("/api/links/:id", deleteLink(store))
+	router.GET("/api/short_names/:name/availability", checkShortName(store))
+	router.GET("/api/links/trash", listTrash(store))
+	router.POST("/api/links/:id/restore", restoreLink(store))
+	router.GET("/api/links/:id/history", linkHistory(store))
+	router.POST("/api/links/:id/rollback/:version", rollbackLink(store))
+	router.GET("/api/audit", listAudit(store))
+	router.GET("/api/tags", listTags(store))
+	router.POST("/api/tags", createTag(store))
+	router.PUT("/api/tags/:id", updateTag(store))
+	router.DELETE("/api/tags/:id", deleteTag(store))
+	router.GET("/api/folders", listFolders(store))
+	router.POST("/api/folders", createFolder(store))
+	router.PUT("/api/folders/:id", updateFolder(store))
+	router.DELETE("/api/folders/:id", deleteFolder(store))
+	router.GET("/api/stats/tags", tagStats(store))
+	router.GET("/api/webhooks", listWebhooks(store))
+	router.POST("/api/webhooks", createWebhook(store, webhookConfig{}))
+	router.DELETE("/api/webhooks/:id", deleteWebhook(store))
+	router.GET("/api/webhooks/:id/deliveries", listWebhookDeliveries(store))
+	router.POST("/api/webhooks/:id/deliveries/:delivery_id/redeliver", redeliverWebhookDelivery(store))
+	router.GET("/api/link_visits", listVisits(store))
+	hub := newVisitHub()
+	router.GET("/api/link_visits/stream", streamVisits(hub))
+	router.GET("/r/:code", redirectLink(store, bots, newVisitorHasher(), privacyConfig{}, hub, events))
+	router.GET("/api/bot_signatures", listBotSignatures(store))
+	router.POST("/api/bot_signatures", createBotSignature(store, bots))
+	router.DELETE("/api/bot_signatures/:id", deleteBotSignature(store, bots))
+	router.GET("/api/links/:id/stats", linkStats(store))
+	router.GET("/api/links/:id/visits", linkVisits(store))
+	router.GET("/api/stats", visitStats(store))
+	router.GET("/metrics", metricsHandler(metrics, db, hub, events))
+	workers := newWorkerRegistry()
+	workers.disabled(visitRetentionWorker)
+	router.GET("/healthz", healthz())
+	router.GET("/readyz", readyz(postgresReadiness{db}, workers, migrationVersion))
+	router.GET("/api/openapi.json", openAPIHandler())
+	router.GET("/api/docs", apiDocs())
+	os.Exit(m.Run())
+}
+
+// отдельная база со схемой db/schema/schema.sql на сервере из TestMain
+
+func openTestPostgresStore(t *testing.T, dsn string) Store {
+	ctx := context.Background()
+	name := fmt.Sprintf("store_%d", time.Now().UnixNano())
+	_, err := db.Exec(ctx, "CREATE DATABASE "+name)
+	require.NoError(t, err)
+	config, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	config.ConnConfig.Database = name
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	schema, err := os.ReadFile("db/schema/schema.sql")
+	require.NoError(t, err)
+	// без параметров pgx выполняет несколько команд за один вызов
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	return newPostgresStore(pool)
+}
+
+func TestLinkStatsGroupByDevice(t *testing.T) {
+	// переход по ссылке с мобильного устройства
+	reqVisit, _ := http.NewRequest("GET", "/r/exmpl5", nil)
+	reqVisit.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1")
+	reqVisit.Header.Set("Accept-Language", "ru-RU,ru;q=0.9")
+	wVisit := httptest.NewRecorder()
+	router.ServeHTTP(wVisit, reqVisit)
+	assert.Equal(t, http.StatusFound, wVisit.Code)
+	// выполнение запроса
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/6/stats?group_by=device_type", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), response["clicks"])
+	assert.Equal(t, "device_type", response["group_by"])
+	assert.Contains(t, response["groups"], map[string]any{"value": "mobile", "clicks": float64(1), "unique_clicks": float64(1)})
+}
+
+func TestStatsWrongGroupBy(t *testing.T) {
+	// выполнение запроса
+	req, _ := http.NewRequest(http.MethodGet, "/api/stats?group_by=ip", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStatsExcludeBots(t *testing.T) {
+	// добавление пользовательской сигнатуры бота
+	jsonData, _ := json.Marshal(map[string]string{"pattern": "LinkChecker"})
+	reqSig, _ := http.NewRequest(http.MethodPost, "/api/bot_signatures", bytes.NewBuffer(jsonData))
+	wSig := httptest.NewRecorder()
+	router.ServeHTTP(wSig, reqSig)
+	assert.Equal(t, http.StatusCreated, wSig.Code)
+	// повторное добавление той же сигнатуры
+	reqDup, _ := http.NewRequest(http.MethodPost, "/api/bot_signatures", bytes.NewBuffer(jsonData))
+	wDup := httptest.NewRecorder()
+	router.ServeHTTP(wDup, reqDup)
+	assert.Equal(t, http.StatusConflict, wDup.Code)
+	// переход по ссылке ботом с пользовательской сигнатурой
+	reqVisit, _ := http.NewRequest("GET", "/r/exmpl6", nil)
+	reqVisit.Header.Set("User-Agent", "Mozilla/5.0 (compatible; LinkChecker/1.0)")
+	reqVisit.Header.Set("Accept-Language", "en-US")
+	wVisit := httptest.NewRecorder()
+	router.ServeHTTP(wVisit, reqVisit)
+	assert.Equal(t, http.StatusFound, wVisit.Code)
+	// по умолчанию бот не учитывается
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/7/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"clicks": float64(0), "unique_clicks": float64(0)}, response)
+	// с параметром include_bots бот учитывается
+	reqBots, _ := http.NewRequest(http.MethodGet, "/api/links/7/stats?include_bots=true&group_by=is_bot", nil)
+	wBots := httptest.NewRecorder()
+	router.ServeHTTP(wBots, reqBots)
+	assert.Equal(t, http.StatusOK, wBots.Code)
+	var responseBots map[string]any
+	err = json.Unmarshal(wBots.Body.Bytes(), &responseBots)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), responseBots["clicks"])
+	assert.Equal(t, []any{map[string]any{"value": "true", "clicks": float64(1), "unique_clicks": float64(1)}}, responseBots["groups"])
+}
+
+func TestStatsUniqueClicks(t *testing.T) {
+	userAgents := []string{
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+	}
+	// два перехода одного посетителя и один переход другого
+	for _, ua := range userAgents {
+		reqVisit, _ := http.NewRequest("GET", "/r/exmpl7", nil)
+		reqVisit.RemoteAddr = "203.0.113.7:5555"
+		reqVisit.Header.Set("User-Agent", ua)
+		reqVisit.Header.Set("Accept-Language", "ru-RU")
+		wVisit := httptest.NewRecorder()
+		router.ServeHTTP(wVisit, reqVisit)
+		assert.Equal(t, http.StatusFound, wVisit.Code)
+	}
+	// выполнение запроса за текущий день
+	today := time.Now().UTC().Format(time.DateOnly)
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/8/stats?from="+today+"&to="+today, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"clicks": float64(3), "unique_clicks": float64(2)}, response)
+	// запрос за прошедший период
+	reqPast, _ := http.NewRequest(http.MethodGet, "/api/links/8/stats?to=2020-01-01", nil)
+	wPast := httptest.NewRecorder()
+	router.ServeHTTP(wPast, reqPast)
+	assert.Equal(t, http.StatusOK, wPast.Code)
+	var responsePast map[string]any
+	err = json.Unmarshal(wPast.Body.Bytes(), &responsePast)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"clicks": float64(0), "unique_clicks": float64(0)}, responsePast)
+}
+
+func TestVisitRetentionRollup(t *testing.T) {
+	ctx := context.Background()
+	store := newPostgresStore(db)
+	// статистика до свёртки
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/3/stats?group_by=browser", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	before := w.Body.String()
+	// сворачиваем переходы старше 30 дней на 1 июля: только тестовые данные за май
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	moved, err := rollupVisits(ctx, store, 30, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), moved)
+	// сырые переходы удалены
+	var rawCount int64
+	err = db.QueryRow(ctx, "SELECT COUNT(*) FROM link_visits WHERE link_id = 3").Scan(&rawCount)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rawCount)
+	// статистика объединяет агрегаты и свежие переходы
+	reqAfter, _ := http.NewRequest(http.MethodGet, "/api/links/3/stats?group_by=browser", nil)
+	wAfter := httptest.NewRecorder()
+	router.ServeHTTP(wAfter, reqAfter)
+	assert.Equal(t, http.StatusOK, wAfter.Code)
+	assert.JSONEq(t, before, wAfter.Body.String())
+	// агрегаты учитывают период
+	reqPeriod, _ := http.NewRequest(http.MethodGet, "/api/links/3/stats?from=2026-05-26&to=2026-05-26", nil)
+	wPeriod := httptest.NewRecorder()
+	router.ServeHTTP(wPeriod, reqPeriod)
+	var response map[string]any
+	err = json.Unmarshal(wPeriod.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"clicks": float64(1), "unique_clicks": float64(0)}, response)
+	// повторная свёртка ничего не переносит
+	moved, err = rollupVisits(ctx, store, 30, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), moved)
+}
+
+func TestSearchLinks(t *testing.T) {
+	// ссылка с заголовком для поиска
+	var id int64
+	err := db.QueryRow(context.Background(), "INSERT INTO links (original_url, short_name, title) VALUES ('https://docs.example.org/reports/q3', 'rprt', 'Quarterly report') RETURNING id").Scan(&id)
+	assert.NoError(t, err)
+	// выполнение запроса
+	query := url.Values{"q": {"report"}}
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/search?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "links 0-0/1", w.Header().Get("Content-Range"))
+	var response []linkSearchResult
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(response)) {
+		assert.Equal(t, id, response[0].ID)
+		assert.Greater(t, response[0].Rank, float32(0))
+		assert.Equal(t, "Quarterly <mark>report</mark>", response[0].Highlights.Title)
+		assert.Equal(t, "https://docs.example.org/<mark>report</mark>s/q3", response[0].Highlights.OriginalUrl)
+		assert.Equal(t, "", response[0].Highlights.ShortName)
+	}
+
+	// поиск по подстроке короткого имени
+	query = url.Values{"q": {"xmpl"}}
+	req, _ = http.NewRequest(http.MethodGet, "/api/links/search?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response)
+
+	// пустой запрос
+	req, _ = http.NewRequest(http.MethodGet, "/api/links/search?q=%20", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListLinksSearchFilter(t *testing.T) {
+	// выполнение запроса
+	query := url.Values{"filter": {`{"q":"quarterly"}`}}
+	req, _ := http.NewRequest(http.MethodGet, "/api/links?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Range"), "/1")
+	var response []map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(response)) {
+		assert.Equal(t, "rprt", response[0]["short_name"])
+		assert.Equal(t, "Quarterly report", response[0]["title"])
+	}
+}
+
+func TestLinkVisitsFilter(t *testing.T) {
+	// переходы по ссылке 4 за два дня
+	_, err := db.Exec(context.Background(), "INSERT INTO link_visits (link_id, ip, user_agent, referer, status, created_at) VALUES (4, '10.0.0.1', 'Mozilla Firefox', 'https://news.ycombinator.com/', 302, '2026-09-01 10:00:00'), (4, '10.0.0.2', 'Mozilla Chrome', 'https://t.me/channel', 302, '2026-09-01 11:00:00'), (4, '10.0.0.3', 'Mozilla Firefox', 'https://news.ycombinator.com/item', 302, '2026-09-02 09:00:00')")
+	assert.NoError(t, err)
+	// выполнение запроса
+	query := url.Values{
+		"filter": {`{"link_id":4,"created_at_gte":"2026-09-01","created_at_lte":"2026-09-02","status":302,"user_agent":"firefox"}`},
+		"sort":   {`["created_at","DESC"]`},
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "link_visits 0-1/2", w.Header().Get("Content-Range"))
+	var response []map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	var referers []any
+	for _, visit := range response {
+		referers = append(referers, visit["referer"])
+	}
+	assert.Equal(t, []any{"https://news.ycombinator.com/item", "https://news.ycombinator.com/"}, referers)
+
+	// фильтр по referer за один день
+	query = url.Values{"filter": {`{"created_at_gte":"2026-09-01","created_at_lte":"2026-09-01","referer":"t.me"}`}}
+	req, _ = http.NewRequest(http.MethodGet, "/api/link_visits?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(response)) {
+		assert.Equal(t, "10.0.0.2", response[0]["ip"])
+	}
+}
+
+func TestLinkVisitsNested(t *testing.T) {
+	// link_id из фильтра не влияет на ссылку из пути
+	query := url.Values{"filter": {`{"link_id":5,"created_at_gte":"2026-09-01","created_at_lte":"2026-09-30"}`}}
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/4/visits?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// проверка результатов
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "link_visits 0-2/3", w.Header().Get("Content-Range"))
+	var response []map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	for _, visit := range response {
+		assert.Equal(t, float64(4), visit["link_id"])
+	}
+
+	// несуществующая ссылка
+	req, _ = http.NewRequest(http.MethodGet, "/api/links/999/visits", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// неизвестное поле фильтра и сортировки
+	for _, query := range []url.Values{{"filter": {`{"ip":"10.0.0.1"}`}}, {"sort": {`["referer","ASC"]`}}} {
+		req, _ = http.NewRequest(http.MethodGet, "/api/links/4/visits?"+query.Encode(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestTrashRestore(t *testing.T) {
+	// ссылка 2 удалена в TestDeleteLinkRight и лежит в корзине
+	req, _ := http.NewRequest(http.MethodGet, "/api/links/trash", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "links 0-0/1", w.Header().Get("Content-Range"))
+	var trash []map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &trash)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(trash)) {
+		assert.Equal(t, float64(2), trash[0]["id"])
+		assert.NotNil(t, trash[0]["deleted_at"])
+	}
+	// удалённое короткое имя не перенаправляет
+	req, _ = http.NewRequest(http.MethodGet, "/r/exmpl1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// восстановление
+	req, _ = http.NewRequest(http.MethodPost, "/api/links/2/restore", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	req, _ = http.NewRequest(http.MethodGet, "/r/exmpl1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	// повторное восстановление
+	req, _ = http.NewRequest(http.MethodPost, "/api/links/2/restore", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// снова удаляем и очищаем корзину
+	req, _ = http.NewRequest(http.MethodDelete, "/api/links/2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	store := newPostgresStore(db)
+	purged, err := purgeDeletedLinks(context.Background(), store, 30, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+	purged, err = purgeDeletedLinks(context.Background(), store, 30, time.Now().AddDate(0, 0, 31))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	req, _ = http.NewRequest(http.MethodPost, "/api/links/2/restore", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAuditLog(t *testing.T) {
+	// изменение заголовка ссылки от имени alice
+	body := `{"original_url":"https://example.com/update_test","short_name":"exmpl_update","title":"Main page"}`
+	req, _ := http.NewRequest(http.MethodPut, "/api/links/1", bytes.NewBufferString(body))
+	req.Header.Set("X-Actor", "alice")
+	req.RemoteAddr = "192.0.2.10:40000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, "Main page", updated["title"])
+	// журнал по ссылке и автору
+	query := url.Values{"filter": {`{"link_id":1,"claimed_actor":"alice"}`}}
+	req, _ = http.NewRequest(http.MethodGet, "/api/audit?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "audit 0-0/1", w.Header().Get("Content-Range"))
+	var entries []map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &entries)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "update", entries[0]["action"])
+		assert.Equal(t, "alice", entries[0]["claimed_actor"])
+		assert.Equal(t, "192.0.2.10", entries[0]["ip"])
+		want := map[string]any{"title": map[string]any{"before": nil, "after": "Main page"}}
+		assert.Equal(t, want, entries[0]["changes"])
+	}
+	// удаление и восстановление ссылки 2 в предыдущих тестах
+	query = url.Values{"filter": {`{"link_id":2}`}}
+	req, _ = http.NewRequest(http.MethodGet, "/api/audit?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &entries)
+	assert.NoError(t, err)
+	var actions []any
+	for _, entry := range entries {
+		actions = append(actions, entry["action"])
+	}
+	assert.Equal(t, []any{"delete", "restore", "delete"}, actions)
+}
+
+func TestDestinationHistory(t *testing.T) {
+	// смена адреса ссылки 3 создаёт вторую версию
+	body := `{"original_url":"https://example.com/long-url2-v2","short_name":"exmpl2"}`
+	req, _ := http.NewRequest(http.MethodPut, "/api/links/3", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	// переход засчитывается второй версии
+	req, _ = http.NewRequest(http.MethodGet, "/r/exmpl2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/long-url2-v2", w.Header().Get("Location"))
+	req, _ = http.NewRequest(http.MethodGet, "/api/links/3/history", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var history []map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &history)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(history)) {
+		assert.Equal(t, float64(2), history[0]["version"])
+		assert.Equal(t, true, history[0]["active"])
+		assert.Equal(t, float64(1), history[0]["clicks"])
+		assert.Equal(t, "https://example.com/long-url2", history[1]["original_url"])
+		assert.Equal(t, false, history[1]["active"])
+	}
+	// возврат к первой версии
+	req, _ = http.NewRequest(http.MethodPost, "/api/links/3/rollback/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var link map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &link)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/long-url2", link["original_url"])
+	req, _ = http.NewRequest(http.MethodGet, "/r/exmpl2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "https://example.com/long-url2", w.Header().Get("Location"))
+	req, _ = http.NewRequest(http.MethodGet, "/api/links/3/history", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	err = json.Unmarshal(w.Body.Bytes(), &history)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(history)) {
+		assert.Equal(t, false, history[0]["active"])
+		assert.Equal(t, true, history[1]["active"])
+		assert.Equal(t, float64(1), history[1]["clicks"])
+	}
+	// несуществующая версия и неверный номер
+	req, _ = http.NewRequest(http.MethodPost, "/api/links/3/rollback/9", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	req, _ = http.NewRequest(http.MethodPost, "/api/links/3/rollback/zero", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTagsAndFolders(t *testing.T) {
+	// создание папки, повторное имя занято
+	req, _ := http.NewRequest(http.MethodPost, "/api/folders", bytes.NewBufferString(`{"name":"Marketing"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var folder map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &folder)
+	assert.NoError(t, err)
+	folderID := folder["id"]
+	req, _ = http.NewRequest(http.MethodPost, "/api/folders", bytes.NewBufferString(`{"name":"Marketing"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	// ссылка в папке с метками, имена меток приводятся к одному виду
+	body := fmt.Sprintf(`{"original_url":"https://example.com/promo","folder_id":%v,"tags":["Promo"," q3 ","promo"]}`, folderID)
+	req, _ = http.NewRequest(http.MethodPost, "/api/links", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &created)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"promo", "q3"}, created["tags"])
+	assert.Equal(t, folderID, created["folder_id"])
+	linkID := created["id"]
+	req, _ = http.NewRequest(http.MethodPost, "/api/links", bytes.NewBufferString(`{"original_url":"https://example.com/promo","folder_id":999}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	// переход по ссылке засчитывается её меткам
+	reqVisit, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/r/%v", created["short_name"]), nil)
+	reqVisit.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+	reqVisit.Header.Set("Accept-Language", "en-US")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, reqVisit)
+	assert.Equal(t, http.StatusFound, w.Code)
+	// фильтр списка по метке и по папке
+	for _, filter := range []string{`{"tag":"PROMO"}`, fmt.Sprintf(`{"folder_id":%v}`, folderID)} {
+		query := url.Values{"filter": {filter}}
+		req, _ = http.NewRequest(http.MethodGet, "/api/links?"+query.Encode(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var links []map[string]any
+		err = json.Unmarshal(w.Body.Bytes(), &links)
+		assert.NoError(t, err)
+		if assert.Equal(t, 1, len(links), filter) {
+			assert.Equal(t, linkID, links[0]["id"])
+			assert.Equal(t, []any{"promo", "q3"}, links[0]["tags"])
+		}
+	}
+	// статистика по меткам
+	req, _ = http.NewRequest(http.MethodGet, "/api/stats/tags", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats []map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &stats)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(stats)) {
+		assert.Equal(t, "promo", stats[0]["name"])
+		assert.Equal(t, float64(1), stats[0]["links"])
+		assert.Equal(t, float64(1), stats[0]["clicks"])
+	}
+	// изменение ссылки заменяет метки и папку
+	body = fmt.Sprintf(`{"original_url":"https://example.com/promo","short_name":"%v","tags":["q3"]}`, created["short_name"])
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/api/links/%v", linkID), bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"q3"}, updated["tags"])
+	assert.Nil(t, updated["folder_id"])
+	req, _ = http.NewRequest(http.MethodGet, "/api/tags", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var tags []map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &tags)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(tags)) {
+		assert.Equal(t, "promo", tags[0]["name"])
+		assert.Equal(t, float64(0), tags[0]["links"])
+		assert.Equal(t, float64(1), tags[1]["links"])
+	}
+	// удаление папки и метки
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/api/folders/%v", folderID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/api/tags/%v", tags[0]["id"]), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/api/tags/%v", tags[0]["id"]), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWebhooks(t *testing.T) {
+	// получатель отвечает ошибкой на первый запрос
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		if len(received) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	secret := "0123456789abcdef"
+	body := fmt.Sprintf(`{"url":"https://crm.example.com/hooks","events":["link.visited","link.created","link.visited"],"secret":%q}`, secret)
+	req, _ := http.NewRequest(http.MethodPost, "/api/webhooks", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var webhook map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &webhook)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"link.created", "link.visited"}, webhook["events"])
+	assert.Equal(t, secret, webhook["secret"])
+	webhookID := webhook["id"]
+	req, _ = http.NewRequest(http.MethodPost, "/api/webhooks", bytes.NewBufferString(`{"url":"https://crm.example.com","events":["link.clicked"]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	// локальный получатель не принимается, поэтому адрес подменяется в базе
+	req, _ = http.NewRequest(http.MethodPost, "/api/webhooks", bytes.NewBufferString(fmt.Sprintf(`{"url":%q,"events":["link.visited"]}`, receiver.URL)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	_, err = db.Exec(context.Background(), "UPDATE webhooks SET url = $1 WHERE id = $2", receiver.URL, webhookID)
+	assert.NoError(t, err)
+	// переход ставит событие в очередь
+	req, _ = http.NewRequest(http.MethodGet, "/r/exmpl5", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	events.flush()
+	dispatcher := newWebhookDispatcher(newPostgresStore(db), webhookConfig{AllowHTTP: true})
+	// клиент без проверки адресов для получателя на localhost
+	dispatcher.client = receiver.Client()
+	now := time.Now()
+	processed, err := dispatcher.dispatch(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	// неудачная попытка откладывается
+	processed, err = dispatcher.dispatch(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, processed)
+	deliveriesURL := fmt.Sprintf("/api/webhooks/%v/deliveries", webhookID)
+	req, _ = http.NewRequest(http.MethodGet, deliveriesURL, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var deliveries []map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &deliveries)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(deliveries)) {
+		assert.Equal(t, "pending", deliveries[0]["status"])
+		assert.Equal(t, float64(1), deliveries[0]["attempts"])
+		assert.Equal(t, float64(500), deliveries[0]["response_status"])
+	}
+	// повтор после задержки доставляется
+	processed, err = dispatcher.dispatch(context.Background(), now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	if assert.Equal(t, 2, len(received)) {
+		last := received[1]
+		assert.Equal(t, "link.visited", last.Header.Get("X-Webhook-Event"))
+		timestamp, err := strconv.ParseInt(last.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, signWebhookPayload(secret, timestamp, bodies[1]), last.Header.Get("X-Webhook-Signature"))
+		var payload map[string]any
+		err = json.Unmarshal(bodies[1], &payload)
+		assert.NoError(t, err)
+		assert.Equal(t, "link.visited", payload["event"])
+		assert.Equal(t, float64(6), payload["data"].(map[string]any)["link_id"])
+	}
+	req, _ = http.NewRequest(http.MethodGet, deliveriesURL, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	err = json.Unmarshal(w.Body.Bytes(), &deliveries)
+	assert.NoError(t, err)
+	assert.Equal(t, "delivered", deliveries[0]["status"])
+	// ручная повторная отправка
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%v/redeliver", deliveriesURL, deliveries[0]["id"]), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	processed, err = dispatcher.dispatch(context.Background(), time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, 3, len(received))
+	req, _ = http.NewRequest(http.MethodPost, deliveriesURL+"/999/redeliver", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// секрет не показывается в списке
+	req, _ = http.NewRequest(http.MethodGet, "/api/webhooks", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var webhooks []map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &webhooks)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(webhooks)) {
+		assert.NotContains(t, webhooks[0], "secret")
+	}
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/api/webhooks/%v", webhookID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestMetrics(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/r/exmpl4", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/r/:code",status="302"}`)
+	assert.Contains(t, body, "# TYPE http_request_duration_seconds histogram")
+	assert.Contains(t, body, `shortener_redirects_total{result="found"}`)
+	assert.Contains(t, body, "pgxpool_acquired_conns ")
+	assert.Contains(t, body, "pgxpool_idle_conns ")
+	assert.Contains(t, body, "pgxpool_acquire_wait_seconds_total ")
+	assert.Contains(t, body, "shortener_visit_stream_subscribers 0")
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ready struct {
+		Status  string                 `json:"status"`
+		Checks  map[string]healthCheck `json:"checks"`
+		Workers map[string]workerState `json:"workers"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ready))
+	assert.Equal(t, "ready", ready.Status)
+	assert.Equal(t, "ok", ready.Checks["database"].Status)
+	assert.Equal(t, "ok", ready.Checks["migrations"].Status)
+	assert.Equal(t, *ready.Checks["migrations"].Expected, *ready.Checks["migrations"].Current)
+	assert.Equal(t, workerDisabled, ready.Workers[visitRetentionWorker].Status)
+
+	// схема отстаёт от сервиса: откат последней миграции
+	_, err := db.Exec(context.Background(), "INSERT INTO goose_db_version (version_id, is_applied) SELECT MAX(version_id), false FROM goose_db_version")
+	assert.NoError(t, err)
+	defer func() {
+		_, err := db.Exec(context.Background(), "DELETE FROM goose_db_version WHERE NOT is_applied")
+		assert.NoError(t, err)
+	}()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ready))
+	assert.Equal(t, "not_ready", ready.Status)
+	assert.Equal(t, "error", ready.Checks["migrations"].Status)
+}
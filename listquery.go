@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"code/pagination"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// вывод страницы списка с заголовком Content-Range. Для запроса
+// с заголовком Range отвечаем 206 или 416, если диапазон за концом списка
+func writePage[T any](c *gin.Context, page pagination.Page, unit string, items []T, total int64) {
+	if items == nil {
+		items = []T{}
+	}
+	status, contentRange := page.Result(unit, len(items), total)
+	c.Header("Accept-Ranges", unit)
+	c.Header("Content-Range", contentRange)
+	if status == http.StatusRequestedRangeNotSatisfiable {
+		writeProblem(c, status, codeRangeNotSatisfiable, "requested range not satisfiable")
+		return
+	}
+	c.JSON(status, items)
+}
+
+// поля, по которым допускается сортировка списка ссылок
+var linkSortFields = []string{"id", "original_url", "short_name", "created_at"}
+
+// экранирование спецсимволов шаблона LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// разбор параметра sort в формате react-admin: ["field","ASC|DESC"]
+func parseSort(raw string, allowed []string, defaultField string) (string, bool, error) {
+	if raw == "" {
+		return defaultField, false, nil
+	}
+	var sort []string
+	if err := json.Unmarshal([]byte(raw), &sort); err != nil || len(sort) != 2 {
+		return "", false, errors.New(`the sort must be specified as ["field","ASC|DESC"]`)
+	}
+	allowedField := false
+	for _, f := range allowed {
+		if sort[0] == f {
+			allowedField = true
+			break
+		}
+	}
+	if !allowedField {
+		return "", false, fmt.Errorf("the sort field must be one of: %s", strings.Join(allowed, ", "))
+	}
+	switch strings.ToUpper(sort[1]) {
+	case "ASC":
+		return sort[0], false, nil
+	case "DESC":
+		return sort[0], true, nil
+	}
+	return "", false, errors.New("the sort order must be ASC or DESC")
+}
+
+// фильтр списка ссылок в формате react-admin
+type linkFilter struct {
+	// префикс короткого имени
+	ShortName string `json:"short_name"`
+	// подстрока домена исходного адреса
+	Domain       string  `json:"domain"`
+	CreatedAtGte string  `json:"created_at_gte"`
+	CreatedAtLte string  `json:"created_at_lte"`
+	ID           []int64 `json:"id"`
+	// поисковый запрос по адресу, короткому имени и заголовку
+	Q string `json:"q"`
+	// имя метки и id папки
+	Tag      string `json:"tag"`
+	FolderID *int64 `json:"folder_id"`
+}
+
+// условия отбора ссылок для запросов к БД
+type linkConditions struct {
+	ShortNamePrefix pgtype.Text
+	Domain          pgtype.Text
+	CreatedFrom     pgtype.Timestamptz
+	CreatedTo       pgtype.Timestamptz
+	Ids             []int64
+	Search          pgtype.Text
+	SearchPattern   pgtype.Text
+	Tag             pgtype.Text
+	FolderID        pgtype.Int8
+}
+
+// разбор параметра filter, неизвестные поля считаются ошибкой
+func parseLinkFilter(raw string) (linkConditions, error) {
+	var cond linkConditions
+	if raw == "" {
+		return cond, nil
+	}
+	var filter linkFilter
+	dec := json.NewDecoder(bytes.NewBufferString(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		return cond, errors.New("the filter must be a JSON object with fields: short_name, domain, created_at_gte, created_at_lte, id, q, tag, folder_id")
+	}
+	cond.ShortNamePrefix = textOrNull(likeEscaper.Replace(filter.ShortName))
+	cond.Domain = textOrNull(likeEscaper.Replace(filter.Domain))
+	var err error
+	cond.CreatedFrom, err = parseRangeBound(filter.CreatedAtGte, false)
+	if err != nil {
+		return cond, errors.New("created_at_gte must be a date (2006-01-02) or RFC 3339 time")
+	}
+	cond.CreatedTo, err = parseRangeBound(filter.CreatedAtLte, true)
+	if err != nil {
+		return cond, errors.New("created_at_lte must be a date (2006-01-02) or RFC 3339 time")
+	}
+	cond.Ids = filter.ID
+	search := strings.TrimSpace(filter.Q)
+	cond.Search = textOrNull(search)
+	cond.SearchPattern = textOrNull(likeEscaper.Replace(search))
+	cond.Tag = textOrNull(normalizeTag(filter.Tag))
+	if filter.FolderID != nil {
+		cond.FolderID = pgtype.Int8{Int64: *filter.FolderID, Valid: true}
+	}
+	return cond, nil
+}
+
+// разбор включительной границы диапазона: дата или время в формате RFC 3339.
+// Для верхней границы дата означает последний момент указанного дня
+func parseRangeBound(value string, end bool) (pgtype.Timestamptz, error) {
+	bound, err := parsePeriodBound(value, end)
+	if err != nil || !bound.Valid {
+		return bound, err
+	}
+	if end {
+		if _, err := time.Parse(time.DateOnly, value); err == nil {
+			bound.Time = bound.Time.Add(-time.Microsecond)
+		}
+	}
+	return bound, nil
+}
+
+// поля, по которым допускается сортировка списка переходов
+var visitSortFields = []string{"id", "created_at"}
+
+// фильтр списка переходов в формате react-admin
+type visitFilter struct {
+	LinkID       *int64 `json:"link_id"`
+	CreatedAtGte string `json:"created_at_gte"`
+	CreatedAtLte string `json:"created_at_lte"`
+	Status       *int32 `json:"status"`
+	// подстроки referer и User-Agent
+	Referer   string `json:"referer"`
+	UserAgent string `json:"user_agent"`
+}
+
+// условия отбора переходов для запросов к БД
+type visitConditions struct {
+	LinkID      pgtype.Int8
+	CreatedFrom pgtype.Timestamptz
+	CreatedTo   pgtype.Timestamptz
+	Status      pgtype.Int4
+	Referer     pgtype.Text
+	UserAgent   pgtype.Text
+}
+
+// разбор параметра filter списка переходов, неизвестные поля считаются ошибкой
+func parseVisitFilter(raw string) (visitConditions, error) {
+	var cond visitConditions
+	if raw == "" {
+		return cond, nil
+	}
+	var filter visitFilter
+	dec := json.NewDecoder(bytes.NewBufferString(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		return cond, errors.New("the filter must be a JSON object with fields: link_id, created_at_gte, created_at_lte, status, referer, user_agent")
+	}
+	if filter.LinkID != nil {
+		cond.LinkID = pgtype.Int8{Int64: *filter.LinkID, Valid: true}
+	}
+	if filter.Status != nil {
+		cond.Status = pgtype.Int4{Int32: *filter.Status, Valid: true}
+	}
+	var err error
+	cond.CreatedFrom, err = parseRangeBound(filter.CreatedAtGte, false)
+	if err != nil {
+		return cond, errors.New("created_at_gte must be a date (2006-01-02) or RFC 3339 time")
+	}
+	cond.CreatedTo, err = parseRangeBound(filter.CreatedAtLte, true)
+	if err != nil {
+		return cond, errors.New("created_at_lte must be a date (2006-01-02) or RFC 3339 time")
+	}
+	cond.Referer = textOrNull(likeEscaper.Replace(filter.Referer))
+	cond.UserAgent = textOrNull(likeEscaper.Replace(filter.UserAgent))
+	return cond, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	field, desc, err := parseSort("", linkSortFields, "id")
+	assert.NoError(t, err)
+	assert.Equal(t, "id", field)
+	assert.False(t, desc)
+
+	field, desc, err = parseSort(`["created_at","desc"]`, linkSortFields, "id")
+	assert.NoError(t, err)
+	assert.Equal(t, "created_at", field)
+	assert.True(t, desc)
+
+	for _, raw := range []string{`["id"]`, `id,ASC`, `["password","ASC"]`, `["id","SIDEWAYS"]`} {
+		_, _, err = parseSort(raw, linkSortFields, "id")
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestParseLinkFilter(t *testing.T) {
+	cond, err := parseLinkFilter(`{"short_name":"ab_","domain":"example","created_at_gte":"2026-05-01","created_at_lte":"2026-05-31","id":[1,2]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Text{String: `ab\_`, Valid: true}, cond.ShortNamePrefix)
+	assert.Equal(t, pgtype.Text{String: "example", Valid: true}, cond.Domain)
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), cond.CreatedFrom.Time)
+	assert.Equal(t, time.Date(2026, 5, 31, 23, 59, 59, 999999000, time.UTC), cond.CreatedTo.Time)
+	assert.Equal(t, []int64{1, 2}, cond.Ids)
+	assert.False(t, cond.Search.Valid)
+
+	cond, err = parseLinkFilter(`{"q":" 50% off "}`)
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Text{String: "50% off", Valid: true}, cond.Search)
+	assert.Equal(t, pgtype.Text{String: `50\% off`, Valid: true}, cond.SearchPattern)
+
+	cond, err = parseLinkFilter(`{"tag":" Promo ","folder_id":2}`)
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Text{String: "promo", Valid: true}, cond.Tag)
+	assert.Equal(t, pgtype.Int8{Int64: 2, Valid: true}, cond.FolderID)
+
+	empty, err := parseLinkFilter("")
+	assert.NoError(t, err)
+	assert.Equal(t, linkConditions{}, empty)
+
+	_, err = parseLinkFilter(`{"unknown":1}`)
+	assert.Error(t, err)
+	_, err = parseLinkFilter(`[1,2]`)
+	assert.Error(t, err)
+}
+
+func TestParseVisitFilter(t *testing.T) {
+	cond, err := parseVisitFilter(`{"link_id":3,"status":302,"created_at_gte":"2026-05-01","referer":"t.me","user_agent":"100%"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Int8{Int64: 3, Valid: true}, cond.LinkID)
+	assert.Equal(t, pgtype.Int4{Int32: 302, Valid: true}, cond.Status)
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), cond.CreatedFrom.Time)
+	assert.False(t, cond.CreatedTo.Valid)
+	assert.Equal(t, pgtype.Text{String: "t.me", Valid: true}, cond.Referer)
+	assert.Equal(t, pgtype.Text{String: `100\%`, Valid: true}, cond.UserAgent)
+
+	_, err = parseVisitFilter(`{"ip":"10.0.0.1"}`)
+	assert.Error(t, err)
+	_, err = parseVisitFilter(`{"status":"found"}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, validRequestID("3f2a-req.1"))
+	assert.False(t, validRequestID(""))
+	assert.False(t, validRequestID("with space"))
+	assert.False(t, validRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+	assert.Len(t, newRequestID(), 32)
+}
+
+func TestRequestIDInLogsAndErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(newLogger(&out, slog.LevelInfo))
+	defer slog.SetDefault(previous)
+
+	router := gin.New()
+	router.Use(requestIDMiddleware(), requestLogger())
+	router.GET("/fail", func(c *gin.Context) {
+		dbError(c, "unable to get records", errors.New("connection refused"))
+	})
+
+	// идентификатор от клиента сохраняется
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(requestIDHeader, "client-id-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "client-id-1", w.Header().Get(requestIDHeader))
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "unable to get records", body["detail"])
+	assert.Equal(t, "client-id-1", body["request_id"])
+	assert.NotContains(t, w.Body.String(), "connection refused")
+
+	// в журнале ошибка БД и строка запроса с тем же идентификатором
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	var dbLine, requestLine map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &dbLine))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &requestLine))
+	assert.Equal(t, "database error", dbLine["msg"])
+	assert.Equal(t, "connection refused", dbLine["error"])
+	assert.Equal(t, "client-id-1", dbLine["request_id"])
+	assert.Equal(t, "request", requestLine["msg"])
+	assert.Equal(t, "ERROR", requestLine["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), requestLine["status"])
+	assert.Equal(t, "client-id-1", requestLine["request_id"])
+
+	// без заголовка идентификатор создаётся
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	assert.Len(t, w.Header().Get(requestIDHeader), 32)
+}
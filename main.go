@@ -2,15 +2,17 @@ package main
 
 import (
 	generated "code/db/generated"
+	"code/pagination"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -18,14 +20,18 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jxskiss/base62"
 )
 
 // создание маршрутизатора Gin
 func setupRouter() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
+	// журнал и Sentry получают *gin.Context, поэтому он должен отдавать
+	// контекст запроса с текущим спаном. В хранилище передаётся
+	// c.Request.Context(): gin переиспользует контексты между запросами
+	router.ContextWithFallback = true
 	// включаем поддержку Cloudflare
 	router.TrustedPlatform = gin.PlatformCloudflare
 	router.ForwardedByClientIP = true
@@ -33,21 +39,28 @@ func setupRouter() *gin.Engine {
 	proxies := []string{"127.0.0.1", "::1"}
 	err := router.SetTrustedProxies(proxies)
 	if err != nil {
-		log.Fatalf("error while setting up proxy")
+		slog.Error("error while setting up proxy", "error", err)
+		os.Exit(1)
 	}
 	// настройка политики разрешений
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"https://localhost:5173/"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Referer"}
-	config.ExposeHeaders = []string{"Content-Range"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Referer", "Range", actorHeader, "Last-Event-ID", "traceparent", "tracestate", requestIDHeader}
+	config.ExposeHeaders = []string{"Content-Range", nextCursorHeader, prevCursorHeader, requestIDHeader}
 	router.Use(cors.New(config))
+	// подключаем трассировку запросов
+	router.Use(tracingMiddleware())
 	// подключаем монитор просмотра ошибок
 	router.Use(sentrygin.New(sentrygin.Options{}))
+	// присваиваем запросу идентификатор
+	router.Use(requestIDMiddleware())
+	// подключаем журнал запросов
+	router.Use(requestLogger())
 	// подключаем инструмент восстановления сбоев
-	router.Use(gin.Recovery())
-	// подключаем логгер
-	router.Use(gin.Logger())
+	router.Use(recoveryMiddleware())
+	// неизвестные маршруты отвечают в том же формате, что и остальные ошибки
+	router.NoRoute(routeNotFound())
 	// задаём стандартный маршрут '/ping'
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
@@ -56,80 +69,133 @@ func setupRouter() *gin.Engine {
 }
 
 // получение всех записей
-func listLinks(db *generated.Queries) gin.HandlerFunc {
+func listLinks(db Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var paginParams generated.ListLinksParams
 		// получаем параметры для пагинации
-		rangeLinks := c.DefaultQuery("range", "[0,50]")
-		// задаём параметры по умолчанию
-		limit := 50
-		offset := 0
-		// задаём регулярное выражение для поиска всех чисел
-		re := regexp.MustCompile(`\d+`)
-		// получаем лимит записей на странице и сдвиг для вывода записей
-		numRange := re.FindAllString(rangeLinks, -1)
-		// проверяем корректность ввода данных
-		if len(numRange) != 2 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "the range must be specified by two numbers, example: [1,4]"})
-			return
-		}
-		idx0, _ := strconv.Atoi(numRange[0])
-		idx1, _ := strconv.Atoi(numRange[1])
-		// проверка на положительные значения
-		if idx0 < 0 || idx1 < 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "the range value must be positive"})
-			return
-		}
-		// если первый индекс меньше второго
-		if idx0 < idx1 {
-			limit = idx1 - idx0
-			offset = idx0
-		}
-		// если индексы равны
-		if idx0 == idx1 {
-			limit = 1
-			offset = idx0
-		}
-		if idx0 > idx1 {
-			msg := "range values are specified incorrectly"
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
-			return
-		}
-		// ограничение максимального числа записей на странице
-		if limit > 50 {
-			limit = 50
-		}
-		paginParams.Limit = int32(limit)
-		paginParams.Offset = int32(offset)
-		links, err := db.ListLinks(c, paginParams)
+		page, err := pagination.FromRequest(c.Request, "links")
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		// получаем параметры сортировки и фильтрации
+		sortField, sortDesc, err := parseSort(c.Query("sort"), linkSortFields, "id")
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		cond, err := parseLinkFilter(c.Query("filter"))
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		// при передаче after или before выводим страницу по курсору
+		keyset, useCursor, err := parseKeysetPage(c)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		if useCursor {
+			if sortField != "id" {
+				writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "cursor pagination supports sorting by id only")
+				return
+			}
+			writeLinksByCursor(c, db, cond, sortDesc, keyset)
+			return
+		}
+		paginParams.ShortNamePrefix = cond.ShortNamePrefix
+		paginParams.Domain = cond.Domain
+		paginParams.CreatedFrom = cond.CreatedFrom
+		paginParams.CreatedTo = cond.CreatedTo
+		paginParams.Ids = cond.Ids
+		paginParams.Search = cond.Search
+		paginParams.SearchPattern = cond.SearchPattern
+		paginParams.Tag = cond.Tag
+		paginParams.FolderID = cond.FolderID
+		paginParams.SortField = sortField
+		paginParams.SortDesc = sortDesc
+		paginParams.Limit = int32(page.Limit)
+		paginParams.Offset = int32(page.Offset)
+		links, err := db.ListLinks(c.Request.Context(), paginParams)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "links not found"})
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "links not found")
 			return
 		}
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "database error"})
+			dbError(c, "unable to get links", err)
 			return
 		}
-		count, err := db.CounterLinks(c)
+		// общее число записей с учётом фильтра
+		var countParams generated.CounterLinksParams
+		countParams.ShortNamePrefix = cond.ShortNamePrefix
+		countParams.Domain = cond.Domain
+		countParams.CreatedFrom = cond.CreatedFrom
+		countParams.CreatedTo = cond.CreatedTo
+		countParams.Ids = cond.Ids
+		countParams.Search = cond.Search
+		countParams.SearchPattern = cond.SearchPattern
+		countParams.Tag = cond.Tag
+		countParams.FolderID = cond.FolderID
+		count, err := db.CounterLinks(c.Request.Context(), countParams)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "unable to count the number of records"})
+			dbError(c, "unable to count the number of records", err)
 			return
 		}
-		headerVal := fmt.Sprintf("links: %d-%d/%d", idx0, idx1, count)
-		c.Header("Content-Range", headerVal)
-		c.JSON(http.StatusOK, links)
+		writePage(c, page, "links", links, count)
+
+	}
+}
 
+// вывод страницы ссылок по курсору без подсчёта общего числа записей.
+// Для каждого направления свой запрос, чтобы Postgres читал первичный
+// ключ по порядку, а не сортировал все подходящие записи
+func writeLinksByCursor(c *gin.Context, db Store, cond linkConditions, desc bool, page keysetPage) {
+	var cursorParams generated.ListLinksByCursorAscParams
+	cursorParams.ShortNamePrefix = cond.ShortNamePrefix
+	cursorParams.Domain = cond.Domain
+	cursorParams.CreatedFrom = cond.CreatedFrom
+	cursorParams.CreatedTo = cond.CreatedTo
+	cursorParams.Ids = cond.Ids
+	cursorParams.Search = cond.Search
+	cursorParams.SearchPattern = cond.SearchPattern
+	cursorParams.Tag = cond.Tag
+	cursorParams.FolderID = cond.FolderID
+	cursorParams.CursorID = page.cursorID(page.scanDesc(desc))
+	cursorParams.Limit = int32(page.Limit + 1)
+	var rows []generated.ListLinksByCursorAscRow
+	var err error
+	if page.scanDesc(desc) {
+		var descRows []generated.ListLinksByCursorDescRow
+		descRows, err = db.ListLinksByCursorDesc(c.Request.Context(), generated.ListLinksByCursorDescParams(cursorParams))
+		for _, row := range descRows {
+			rows = append(rows, generated.ListLinksByCursorAscRow(row))
+		}
+	} else {
+		rows, err = db.ListLinksByCursorAsc(c.Request.Context(), cursorParams)
+	}
+	if err != nil {
+		dbError(c, "unable to get links", err)
+		return
 	}
+	links, next, prev := keysetResult(rows, page, func(l generated.ListLinksByCursorAscRow) int64 { return l.ID })
+	if links == nil {
+		links = []generated.ListLinksByCursorAscRow{}
+	}
+	setCursorHeaders(c, next, prev)
+	c.JSON(http.StatusOK, links)
 }
 
-// структура для валидации полей original_url и short_name
+// структура для валидации полей original_url, short_name, title, папки и меток
 type UserRequest struct {
-	OriginalUrl string `json:"original_url" binding:"required,url"`
-	ShortName   string `json:"short_name"`
+	OriginalUrl string   `json:"original_url" binding:"required,url"`
+	ShortName   string   `json:"short_name" binding:"omitempty,min=3,max=32"`
+	Title       string   `json:"title" binding:"max=255"`
+	FolderID    *int64   `json:"folder_id"`
+	Tags        []string `json:"tags" binding:"max=20,dive,max=64"`
 }
 
 // создание новой записи
-func createLink(db *generated.Queries) gin.HandlerFunc {
+func createLink(db Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req UserRequest
 		var link generated.CreateLinkParams
@@ -141,74 +207,115 @@ func createLink(db *generated.Queries) gin.HandlerFunc {
 				for _, e := range ve {
 					errorsMap[e.Field()] = e.Tag()
 				}
-				c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errorsMap})
+				writeValidationProblem(c, errorsMap)
 				return
 			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
 			return
 		}
 		link.OriginalUrl = req.OriginalUrl
+		link.Title = textOrNull(req.Title)
+		link.FolderID = folderOrNull(req.FolderID)
+		tags := normalizeTags(req.Tags)
 		shortName := req.ShortName
-		// если имя не введено, то генерируем имя
-		if shortName == "" {
-			lastRec, err := db.LastLink(c)
+		// если имя не введено, то генерируем имя из следующего ID
+		generatedName := shortName == ""
+		var lastID int64
+		if generatedName {
+			lastRec, err := db.LastLink(c.Request.Context())
 			if err != nil {
-				c.JSON(http.StatusUnprocessableEntity, gin.H{"last link": "unable to get the latest entry"})
+				dbError(c, "unable to get the latest link", err)
 				return
 			}
-			// получаем текущий ID записи
-			lastID := fmt.Sprintf("%d", lastRec.ID+1)
-			// кодируем в Base62
-			shortName = base62.EncodeToString([]byte(lastID))
-			// если длина сгенерированного имени меньше 3
-			if len(shortName) < 3 {
-				shortName = shortName + shortName + shortName
-			}
-			link.ShortName = pgtype.Text{String: shortName, Valid: true}
+			lastID = lastRec.ID
 		}
 		// создаём короткое имя ссылки
-		shortUrl := fmt.Sprintf("https://go-project-278-yoao.onrender.com/r/%s", shortName)
-		shortUrlTxt := pgtype.Text{String: shortUrl, Valid: true}
-		// cоздаём запись
-		res, err := db.CreateLink(c, link)
-		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"create link": "unable to create records"})
+		var shortUrlTxt pgtype.Text
+		var res generated.Link
+		// запись, история, короткая ссылка, метки, журнал и событие сохраняются вместе
+		err := db.InTx(c.Request.Context(), func(tx Store) error {
+			var err error
+			for attempt := range generatedShortNameAttempts {
+				if generatedName {
+					shortName = generateShortName(lastID + 1 + int64(attempt))
+				}
+				link.ShortName = pgtype.Text{String: shortName, Valid: true}
+				// cоздаём запись; ошибка в точке сохранения не прерывает транзакцию
+				err = tx.InTx(c.Request.Context(), func(attemptTx Store) error {
+					res, err = attemptTx.CreateLink(c.Request.Context(), link)
+					return err
+				})
+				// занятое сгенерированное имя заменяется следующим
+				if !generatedName || !isShortNameViolation(err) {
+					break
+				}
+			}
+			if err != nil {
+				return err
+			}
+			shortUrl := fmt.Sprintf("https://go-project-278-yoao.onrender.com/r/%s", shortName)
+			shortUrlTxt = pgtype.Text{String: shortUrl, Valid: true}
+			// первая версия адреса в истории
+			if _, err := addDestination(c, tx, res.ID, res.OriginalUrl); err != nil {
+				return failedStep("unable to save destination history", err)
+			}
+			// добавляем короткую ссылку к записи
+			var shortNameParams generated.UpdateShortNameParams
+			shortNameParams.ID = res.ID
+			shortNameParams.ShortUrl = shortUrlTxt
+			if err := tx.UpdateShortName(c.Request.Context(), shortNameParams); err != nil {
+				return failedStep("unable to save short url", err)
+			}
+			if len(tags) > 0 {
+				if err := setLinkTags(c, tx, res.ID, tags); err != nil {
+					return failedStep("unable to save tags", err)
+				}
+			}
+			if err := recordAudit(c, tx, res.ID, auditCreate, nil, newLinkSnapshot(res.OriginalUrl, res.ShortName, shortUrlTxt, res.Title, res.FolderID, tags, false)); err != nil {
+				return err
+			}
+			res.ShortUrl = shortUrlTxt
+			return enqueueWebhookEvent(c.Request.Context(), tx, eventLinkCreated, linkWithTags{Link: res, Tags: tags})
+		})
+		if isForeignKeyViolation(err) {
+			writeProblem(c, http.StatusUnprocessableEntity, codeUnknownFolder, "folder does not exist")
+			return
+		}
+		if isShortNameViolation(err) && !generatedName {
+			writeShortNameTaken(c, db, shortName)
 			return
 		}
-		// добавляем короткую ссылку к записи
-		var shortNameParams generated.UpdateShortNameParams
-		shortNameParams.ID = res.ID
-		shortNameParams.ShortUrl = shortUrlTxt
-		err = db.UpdateShortName(c, shortNameParams)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"short_name": "unable to add short name to record"})
+			dbError(c, "unable to create link", err)
 			return
 		}
-		c.JSON(http.StatusCreated, res)
+		c.JSON(http.StatusCreated, linkWithTags{Link: res, Tags: tags})
 	}
 }
 
-// структура для валидации полей original_url и short_name
+// структура для валидации полей original_url, short_name, title, папки и меток
 type UserUpdateRequest struct {
-	OriginalUrl string `json:"original_url" binding:"url"`
-	ShortName   string `json:"short_name"`
+	OriginalUrl string   `json:"original_url" binding:"url"`
+	ShortName   string   `json:"short_name" binding:"required,min=3,max=32"`
+	Title       string   `json:"title" binding:"max=255"`
+	FolderID    *int64   `json:"folder_id"`
+	Tags        []string `json:"tags" binding:"max=20,dive,max=64"`
 }
 
 // обновление записи
-func updateLink(db *generated.Queries) gin.HandlerFunc {
+func updateLink(db Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req UserUpdateRequest
 		var updLink generated.UpdateLinkParams
 		idStr := c.Param("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"id": "incorrect id entered"})
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
 			return
 		}
 		// проверка записи в БД
-		link, err := db.GetLink(c, id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"update link": "link does not exist"})
+		if _, err := db.GetLink(c.Request.Context(), id); err != nil {
+			linkLookupError(c, err)
 			return
 		}
 		// парсинг и валидация данных
@@ -219,80 +326,141 @@ func updateLink(db *generated.Queries) gin.HandlerFunc {
 				for _, e := range ve {
 					errorsMap[e.Field()] = e.Tag()
 				}
-				c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errorsMap})
+				writeValidationProblem(c, errorsMap)
 				return
 			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
 			return
 		}
 		updLink.ID = id
 		updLink.OriginalUrl = req.OriginalUrl
 		updLink.ShortName = pgtype.Text{String: req.ShortName, Valid: true}
-		res := db.UpdateLink(c, updLink)
-		if res != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"update link": "unable to update data"})
-			return
-		}
-		// проверка изменения поля short_name
-		if link.ShortName.String != req.ShortName {
-			shortUrl := fmt.Sprintf("https://go-project-278-yoao.onrender.com/r/%s", req.ShortName)
-			// изменяем короткую ссылку записи
-			var shortNameParams generated.UpdateShortNameParams
-			shortNameParams.ID = link.ID
-			shortNameParams.ShortUrl = pgtype.Text{String: shortUrl, Valid: true}
-			err = db.UpdateShortName(c, shortNameParams)
+		updLink.Title = textOrNull(req.Title)
+		updLink.FolderID = folderOrNull(req.FolderID)
+		var updated generated.GetLinkRow
+		// изменение, история, метки, журнал и событие сохраняются вместе
+		err = db.InTx(c.Request.Context(), func(tx Store) error {
+			// блокируем ссылку до конца транзакции: параллельные изменения
+			// ждут её, поэтому следующая версия в истории не повторяется,
+			// а журнал сравнивает с состоянием, которое действительно изменено
+			locked, err := tx.GetLinkForUpdate(c.Request.Context(), id)
 			if err != nil {
-				c.JSON(http.StatusUnprocessableEntity, gin.H{"short_name": "unable to add short name to record"})
-				return
+				return err
+			}
+			link := generated.GetLinkRow(locked)
+			if err := tx.UpdateLink(c.Request.Context(), updLink); err != nil {
+				return err
 			}
+			// метки заменяются целиком, как и остальные поля
+			if err := setLinkTags(c, tx, id, normalizeTags(req.Tags)); err != nil {
+				return failedStep("unable to save tags", err)
+			}
+			// новый адрес становится следующей версией в истории
+			if link.OriginalUrl != req.OriginalUrl {
+				if _, err := addDestination(c, tx, id, req.OriginalUrl); err != nil {
+					return failedStep("unable to save destination history", err)
+				}
+			}
+			// проверка изменения поля short_name
+			if link.ShortName.String != req.ShortName {
+				shortUrl := fmt.Sprintf("https://go-project-278-yoao.onrender.com/r/%s", req.ShortName)
+				// изменяем короткую ссылку записи
+				var shortNameParams generated.UpdateShortNameParams
+				shortNameParams.ID = link.ID
+				shortNameParams.ShortUrl = pgtype.Text{String: shortUrl, Valid: true}
+				if err := tx.UpdateShortName(c.Request.Context(), shortNameParams); err != nil {
+					return failedStep("unable to save short url", err)
+				}
+			}
+			// получаем обновлённую запись для журнала и ответа
+			updated, err = tx.GetLink(c.Request.Context(), id)
+			if err != nil {
+				return failedStep("unable to get updated link", err)
+			}
+			if err := recordAudit(c, tx, id, auditUpdate, snapshotFromRow(link), snapshotFromRow(updated)); err != nil {
+				return err
+			}
+			return enqueueWebhookEvent(c.Request.Context(), tx, eventLinkUpdated, updated)
+		})
+		if isForeignKeyViolation(err) {
+			writeProblem(c, http.StatusUnprocessableEntity, codeUnknownFolder, "folder does not exist")
+			return
 		}
-		c.JSON(http.StatusOK, res)
+		if isShortNameViolation(err) {
+			writeShortNameTaken(c, db, req.ShortName)
+			return
+		}
+		// ссылку удалили, пока запрос ждал блокировки
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to update link", err)
+			return
+		}
+		c.JSON(http.StatusOK, updated)
 	}
 }
 
 // получение одной записи
-func getLinkFromId(db *generated.Queries) gin.HandlerFunc {
+func getLinkFromId(db Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
 			return
 		}
-		link, err := db.GetLink(c, id)
+		link, err := db.GetLink(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "link not found",
-			})
+			linkLookupError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, link)
 	}
 }
 
-// удаление записи
-func deleteLink(db *generated.Queries) gin.HandlerFunc {
+// удаление записи в корзину, короткое имя остаётся занятым до очистки корзины
+func deleteLink(db Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
 			return
 		}
-		// проверяем наличие записи
-		_, err = db.GetLink(c, id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "the link does not exist",
-			})
+		var deleted int64
+		err = db.InTx(c.Request.Context(), func(tx Store) error {
+			// состояние для журнала и события читается под блокировкой
+			locked, err := tx.GetLinkForUpdate(c.Request.Context(), id)
+			if err != nil {
+				return err
+			}
+			link := generated.GetLinkRow(locked)
+			// помечаем ссылку удалённой
+			deleted, err = tx.SoftDeleteLink(c.Request.Context(), id)
+			if err != nil || deleted == 0 {
+				return err
+			}
+			before := snapshotFromRow(link)
+			after := snapshotFromRow(link)
+			after["deleted"] = true
+			if err := recordAudit(c, tx, id, auditDelete, before, after); err != nil {
+				return err
+			}
+			return enqueueWebhookEvent(c.Request.Context(), tx, eventLinkDeleted, link)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found")
 			return
 		}
-		// удаляем ссылку
-		err = db.DeleteLink(c, id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "error deleting links",
-			})
+			dbError(c, "unable to delete link", err)
+			return
+		}
+		if deleted == 0 {
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found")
 			return
 		}
 		c.JSON(http.StatusNoContent, id)
@@ -300,19 +468,24 @@ func deleteLink(db *generated.Queries) gin.HandlerFunc {
 }
 
 // перенаправление по shot_name на original_url
-func redirectLink(db *generated.Queries) gin.HandlerFunc {
+func redirectLink(db Store, bots *botDetector, visitors *visitorHasher, privacy privacyConfig, hub *visitHub, events *webhookEvents) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		codeStr := c.Param("code")
 		// проверка корректности ввода
 		if codeStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "short name cannot be empty"})
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "short name cannot be empty")
 			return
 		}
 		codeTxt := pgtype.Text{String: codeStr, Valid: true}
 		// получаем id, original_url из БД по введёному имени
-		codeParams, err := db.GetLinkFromCode(c, codeTxt)
+		codeParams, err := db.GetLinkFromCode(c.Request.Context(), codeTxt)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"error of receiving the id and original url": err.Error()})
+			linkLookupError(c, err)
+			return
+		}
+		// удалённая ссылка не перенаправляет, но её имя остаётся занятым
+		if codeParams.DeletedAt.Valid {
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found")
 			return
 		}
 		// добавляем запись о посещении в БД
@@ -320,125 +493,370 @@ func redirectLink(db *generated.Queries) gin.HandlerFunc {
 		linkID := codeParams.ID
 		userAgent := c.Request.UserAgent()
 		ip := c.ClientIP()
-		referer := c.Request.Referer()
 		currentStatus := http.StatusFound
+		// разбираем User-Agent на браузер, ОС и тип устройства
+		uaInfo := parseUserAgent(userAgent)
+		// отделяем ботов и сервисы предпросмотра ссылок от людей
+		if bots.isBot(c.Request) {
+			uaInfo.IsBot = true
+			uaInfo.DeviceType = deviceBot
+		}
+		// отбираем сведения о посетителе с учётом режима конфиденциальности
+		visitor := privacy.visitorFields(c.Request, ip)
 		visitParams.LinkID = linkID
-		visitParams.UserAgent = pgtype.Text{String: userAgent, Valid: true}
-		visitParams.Ip = pgtype.Text{String: ip, Valid: true}
-		visitParams.Referer = pgtype.Text{String: referer, Valid: true}
+		visitParams.UserAgent = textOrNull(visitor.UserAgent)
+		visitParams.Ip = textOrNull(visitor.IP)
+		visitParams.Referer = textOrNull(visitor.Referer)
 		visitParams.Status = pgtype.Int4{Int32: int32(currentStatus), Valid: true}
-		_, err = db.CreateLinkVisits(c, visitParams)
+		visitParams.Browser = textOrNull(uaInfo.Browser)
+		visitParams.BrowserVersion = textOrNull(uaInfo.BrowserVersion)
+		visitParams.Os = textOrNull(uaInfo.OS)
+		visitParams.DeviceType = textOrNull(uaInfo.DeviceType)
+		visitParams.IsBot = uaInfo.IsBot
+		// версия адреса, на который ушёл посетитель
+		visitParams.DestinationVersion = pgtype.Int4{Int32: codeParams.DestinationVersion, Valid: true}
+		// анонимный идентификатор для подсчёта уникальных посетителей
+		if visitor.Track {
+			visitorID, err := visitors.hash(c.Request.Context(), db, ip, userAgent, time.Now())
+			if err != nil {
+				slog.WarnContext(c, "unable to hash visitor", "error", err)
+			}
+			visitParams.VisitorHash = textOrNull(visitorID)
+		}
+		visit, err := db.CreateLinkVisits(c.Request.Context(), visitParams)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"create link visits": err.Error()})
+			dbError(c, "unable to save visit", err)
 			return
 		}
+		hub.publish(visit)
+		events.publish(c, eventLinkVisited, visit)
 		// перенапраявляем на оригинальный адрес
 		c.Redirect(http.StatusFound, codeParams.OriginalUrl)
 	}
 }
 
-// получение статистики
-func listVisits(db *generated.Queries) gin.HandlerFunc {
+// получение списка переходов
+func listVisits(db Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var paginParams generated.ListLinkVisitsParams
-		// получаем параметры для пагинации
-		rangeLinks := c.DefaultQuery("range", "[0,50]")
-		// задаём параметры по умолчанию
-		limit := 50
-		offset := 0
-		// задаём регулярное выражение для поиска всех чисел
-		re := regexp.MustCompile(`\d+`)
-		// получаем лимит записей на странице и сдвиг для вывода записей
-		numRange := re.FindAllString(rangeLinks, -1)
-		// проверяем корректность ввода данных
-		if len(numRange) != 2 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "the range must be specified by two numbers, example: [1,4]"})
-			return
-		}
-		idx0, _ := strconv.Atoi(numRange[0])
-		idx1, _ := strconv.Atoi(numRange[1])
-		// проверка на положительные значения
-		if idx0 < 0 || idx1 < 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "the range value must be positive"})
-			return
-		}
-		// если первый индекс меньше второго
-		if idx0 < idx1 {
-			limit = idx1 - idx0
-			offset = idx0
-		}
-		// если индексы равны
-		if idx0 == idx1 {
-			limit = 1
-			offset = idx0
-		}
-		if idx0 > idx1 {
-			msg := "range values are specified incorrectly"
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
-			return
-		}
-		// ограничение максимального числа записей на странице
-		if limit > 50 {
-			limit = 50
-		}
-		paginParams.Limit = int32(limit)
-		paginParams.Offset = int32(offset)
-		// получаем все записи
-		links, err := db.ListLinkVisits(c, paginParams)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "no visitor records found"})
+		writeVisits(c, db, pgtype.Int8{})
+	}
+}
+
+// получение списка переходов по одной ссылке
+func linkVisits(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
 			return
 		}
+		// проверяем наличие записи
+		_, err = db.GetLink(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"get link visits": err.Error()})
+			linkLookupError(c, err)
 			return
 		}
-		count, err := db.CounterVisits(c)
-		if err != nil {
-			c.JSON(http.StatusUnprocessableEntity, gin.H{"error of receiving the counter of visits": err.Error()})
+		writeVisits(c, db, pgtype.Int8{Int64: id, Valid: true})
+	}
+}
+
+// вывод страницы переходов с фильтрацией и сортировкой;
+// linkID из пути имеет приоритет над link_id из фильтра
+func writeVisits(c *gin.Context, db Store, linkID pgtype.Int8) {
+	var paginParams generated.ListLinkVisitsParams
+	// получаем параметры для пагинации
+	page, err := pagination.FromRequest(c.Request, "link_visits")
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	// получаем параметры сортировки и фильтрации
+	sortField, sortDesc, err := parseSort(c.Query("sort"), visitSortFields, "id")
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	cond, err := parseVisitFilter(c.Query("filter"))
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if linkID.Valid {
+		cond.LinkID = linkID
+	}
+	// при передаче after или before выводим страницу по курсору
+	keyset, useCursor, err := parseKeysetPage(c)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if useCursor {
+		if sortField != "id" {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "cursor pagination supports sorting by id only")
 			return
 		}
-		headerVal := fmt.Sprintf("link_visits: %d-%d/%d", idx0, idx1, count)
-		c.Header("Content-Range", headerVal)
-		c.JSON(http.StatusOK, links)
+		writeVisitsByCursor(c, db, cond, sortDesc, keyset)
+		return
+	}
+	paginParams.LinkID = cond.LinkID
+	paginParams.CreatedFrom = cond.CreatedFrom
+	paginParams.CreatedTo = cond.CreatedTo
+	paginParams.Status = cond.Status
+	paginParams.Referer = cond.Referer
+	paginParams.UserAgent = cond.UserAgent
+	paginParams.SortField = sortField
+	paginParams.SortDesc = sortDesc
+	paginParams.Limit = int32(page.Limit)
+	paginParams.Offset = int32(page.Offset)
+	visits, err := db.ListLinkVisits(c.Request.Context(), paginParams)
+	if err != nil {
+		dbError(c, "unable to get visits", err)
+		return
+	}
+	if visits == nil {
+		visits = []generated.LinkVisit{}
 	}
+	// общее число записей с учётом фильтра
+	var countParams generated.CounterVisitsParams
+	countParams.LinkID = cond.LinkID
+	countParams.CreatedFrom = cond.CreatedFrom
+	countParams.CreatedTo = cond.CreatedTo
+	countParams.Status = cond.Status
+	countParams.Referer = cond.Referer
+	countParams.UserAgent = cond.UserAgent
+	count, err := db.CounterVisits(c.Request.Context(), countParams)
+	if err != nil {
+		dbError(c, "unable to count visits", err)
+		return
+	}
+	writePage(c, page, "link_visits", visits, count)
 }
 
-func main() {
-	// подулючаемся к БД
+// вывод страницы переходов по курсору без подсчёта общего числа записей
+func writeVisitsByCursor(c *gin.Context, db Store, cond visitConditions, desc bool, page keysetPage) {
+	var cursorParams generated.ListLinkVisitsByCursorAscParams
+	cursorParams.LinkID = cond.LinkID
+	cursorParams.CreatedFrom = cond.CreatedFrom
+	cursorParams.CreatedTo = cond.CreatedTo
+	cursorParams.Status = cond.Status
+	cursorParams.Referer = cond.Referer
+	cursorParams.UserAgent = cond.UserAgent
+	cursorParams.CursorID = page.cursorID(page.scanDesc(desc))
+	cursorParams.Limit = int32(page.Limit + 1)
+	var rows []generated.LinkVisit
 	var err error
-	// Инициализация пула соединений
-	conn, err := pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+	if page.scanDesc(desc) {
+		rows, err = db.ListLinkVisitsByCursorDesc(c.Request.Context(), generated.ListLinkVisitsByCursorDescParams(cursorParams))
+	} else {
+		rows, err = db.ListLinkVisitsByCursorAsc(c.Request.Context(), cursorParams)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Ошибка подключения к БД: %v\n", err)
+		dbError(c, "unable to get visits", err)
+		return
+	}
+	visits, next, prev := keysetResult(rows, page, func(v generated.LinkVisit) int64 { return v.ID })
+	if visits == nil {
+		visits = []generated.LinkVisit{}
+	}
+	setCursorHeaders(c, next, prev)
+	c.JSON(http.StatusOK, visits)
+}
+
+// зависимости обработчиков
+type routeDeps struct {
+	store            Store
+	pool             *pgxpool.Pool
+	database         readinessDB
+	bots             *botDetector
+	privacy          privacyConfig
+	webhooks         webhookConfig
+	hub              *visitHub
+	events           *webhookEvents
+	metrics          *httpMetrics
+	workers          *workerRegistry
+	migrationVersion int64
+}
+
+// регистрация маршрутов сервиса; все они описаны в openapi.json
+func registerRoutes(r *gin.Engine, deps routeDeps) {
+	r.GET("/metrics", metricsHandler(deps.metrics, deps.pool, deps.hub, deps.events))
+	r.GET("/healthz", healthz())
+	r.GET("/readyz", readyz(deps.database, deps.workers, deps.migrationVersion))
+	r.GET("/api/links", listLinks(deps.store))
+	r.GET("/api/links/search", searchLinks(deps.store))
+	r.GET("/api/links/:id", getLinkFromId(deps.store))
+	r.GET("/api/link_visits", listVisits(deps.store))
+	r.GET("/api/links/:id/stats", linkStats(deps.store))
+	r.GET("/api/links/:id/visits", linkVisits(deps.store))
+	r.GET("/api/stats", visitStats(deps.store))
+	r.GET("/api/link_visits/stream", streamVisits(deps.hub))
+	r.GET("/r/:code", redirectLink(deps.store, deps.bots, newVisitorHasher(), deps.privacy, deps.hub, deps.events))
+	r.GET("/api/bot_signatures", listBotSignatures(deps.store))
+	r.POST("/api/bot_signatures", createBotSignature(deps.store, deps.bots))
+	r.DELETE("/api/bot_signatures/:id", deleteBotSignature(deps.store, deps.bots))
+	r.POST("/api/links", createLink(deps.store))
+	r.PUT("/api/links/:id", updateLink(deps.store))
+	r.DELETE("/api/links/:id", deleteLink(deps.store))
+	r.GET("/api/short_names/:name/availability", checkShortName(deps.store))
+	r.GET("/api/links/trash", listTrash(deps.store))
+	r.POST("/api/links/:id/restore", restoreLink(deps.store))
+	r.GET("/api/links/:id/history", linkHistory(deps.store))
+	r.POST("/api/links/:id/rollback/:version", rollbackLink(deps.store))
+	r.GET("/api/audit", listAudit(deps.store))
+	r.GET("/api/tags", listTags(deps.store))
+	r.POST("/api/tags", createTag(deps.store))
+	r.PUT("/api/tags/:id", updateTag(deps.store))
+	r.DELETE("/api/tags/:id", deleteTag(deps.store))
+	r.GET("/api/folders", listFolders(deps.store))
+	r.POST("/api/folders", createFolder(deps.store))
+	r.PUT("/api/folders/:id", updateFolder(deps.store))
+	r.DELETE("/api/folders/:id", deleteFolder(deps.store))
+	r.GET("/api/stats/tags", tagStats(deps.store))
+	r.GET("/api/webhooks", listWebhooks(deps.store))
+	r.POST("/api/webhooks", createWebhook(deps.store, deps.webhooks))
+	r.DELETE("/api/webhooks/:id", deleteWebhook(deps.store))
+	r.GET("/api/webhooks/:id/deliveries", listWebhookDeliveries(deps.store))
+	r.POST("/api/webhooks/:id/deliveries/:delivery_id/redeliver", redeliverWebhookDelivery(deps.store))
+	r.GET("/api/openapi.json", openAPIHandler())
+	r.GET("/api/docs", apiDocs())
+}
+
+// время на завершение текущих запросов при остановке сервиса
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	// журнал в формате JSON; стандартный log пишет в него же
+	slog.SetDefault(newLogger(os.Stdout, loadLogLevel()))
+
+	// подключаем трассировку до создания пула и маршрутизатора
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		slog.Error("tracing initialization failed", "error", err)
 		os.Exit(1)
 	}
-	defer conn.Close()
-	queries := generated.New(conn)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("unable to flush traces", "error", err)
+		}
+	}()
 
 	// подключаем мониторинг ошибок
 	errSentry := sentry.Init(sentry.ClientOptions{
 		Dsn: "https://0a6c355afb0d24bf54e562bffe603e94@o4511444391886848.ingest.de.sentry.io/4511444398047312",
 	})
 	if errSentry != nil {
-		log.Fatalf("sentry initialization failed: %s", errSentry)
+		slog.Error("sentry initialization failed", "error", errSentry)
+		os.Exit(1)
 	}
 	defer sentry.Flush(2 * time.Second)
 
+	// считаем запросы для Prometheus
+	metrics := newHTTPMetrics()
+
+	deps := routeDeps{
+		bots:    newBotDetector(),
+		privacy: loadPrivacyConfig(),
+		// схемы адресов получателей вебхуков
+		webhooks: loadWebhookConfig(),
+		// рассылка новых переходов в живой поток
+		hub:     newVisitHub(),
+		metrics: metrics,
+		// состояние фоновых задач для /readyz
+		workers: newWorkerRegistry(),
+	}
+
+	// хранилище выбирается по схеме DATABASE_URL: sqlite:// или Postgres
+	dsn := os.Getenv("DATABASE_URL")
+	if path, ok := sqlitePath(dsn); ok {
+		store, err := openSQLiteStore(context.Background(), path)
+		if err != nil {
+			slog.Error("database connection failed", "error", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		deps.store = store
+		deps.database = store
+		// миграции SQLite применены при открытии
+		deps.migrationVersion, err = expectedMigrationVersion(sqliteMigrationFiles, sqliteMigrationsDir)
+		if err != nil {
+			slog.Error("unable to read embedded migrations", "error", err)
+			os.Exit(1)
+		}
+	} else {
+		poolConfig, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			slog.Error("database connection failed", "error", err)
+			os.Exit(1)
+		}
+		// каждый запрос к БД получает свой спан
+		poolConfig.ConnConfig.Tracer = newQueryTracer()
+		// Инициализация пула соединений
+		conn, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			slog.Error("database connection failed", "error", err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+		deps.store = newPostgresStore(conn)
+		deps.pool = conn
+		deps.database = postgresReadiness{conn}
+
+		// версия схемы, с которой собран сервис
+		deps.migrationVersion, err = expectedMigrationVersion(migrationFiles, migrationsDir)
+		if err != nil {
+			slog.Error("unable to read embedded migrations", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// загружаем пользовательские сигнатуры ботов
+	if err := deps.bots.reload(context.Background(), deps.store); err != nil {
+		slog.Error("unable to load bot signatures", "error", err)
+	}
+
+	// запускаем свёртку старых переходов в дневные агрегаты
+	startVisitRetention(context.Background(), deps.store, loadRetentionDays(), deps.workers)
+
+	// запускаем очистку корзины удалённых ссылок
+	startTrashPurge(context.Background(), deps.store, loadTrashRetentionDays(), deps.workers)
+
+	// запускаем доставку событий вебхукам
+	startWebhookDispatcher(context.Background(), deps.store, deps.webhooks, deps.workers)
+
+	// события вебхуков записываются в очередь доставки в фоне
+	deps.events = newWebhookEvents(deps.store)
+
 	// создаём маршрутизатор
 	r := setupRouter()
+	r.Use(metrics.middleware())
 
 	// регистрируем маршруты
-	r.GET("/api/links", listLinks(queries))
-	r.GET("/api/links/:id", getLinkFromId(queries))
-	r.GET("/api/link_visits", listVisits(queries))
-	r.GET("/r/:code", redirectLink(queries))
-	r.POST("/api/links", createLink(queries))
-	r.PUT("/api/links/:id", updateLink(queries))
-	r.DELETE("/api/links/:id", deleteLink(queries))
+	registerRoutes(r, deps)
 
 	// запускаем сервер на порту 8080
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("server startup error")
+	server := &http.Server{Addr: ":8080", Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// по SIGINT или SIGTERM дожидаемся текущих запросов
+	// и записываем накопленные события о переходах
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	select {
+	case err := <-serverErr:
+		slog.Error("server startup error", "error", err)
+		os.Exit(1)
+	case <-stop.Done():
+	}
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("server shutdown error", "error", err)
 	}
+	deps.events.close()
 }
@@ -1,104 +1,35 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	generated "code/db/generated"
 	"context"
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
-	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 )
 
-var db *pgxpool.Pool
-var router *gin.Engine
-
-func TestMain(m *testing.M) {
-	ctx := context.Background()
-	// запуск контейнера PostgreSQL
-	req := testcontainers.ContainerRequest{
-		Image:        "postgres:16-alpine",
-		ExposedPorts: []string{"5432/tcp"},
-		Env: map[string]string{
-			"POSTGRES_DB":       "testdb",
-			"POSTGRES_USER":     "test",
-			"POSTGRES_PASSWORD": "secret",
-		},
-		WaitingFor: wait.ForExposedPort().WithStartupTimeout(60 * time.Second),
+// время перехода задаёт хранилище, поэтому проверяется только его наличие
+func withoutCreatedAt(t *testing.T, items []map[string]any) []map[string]any {
+	t.Helper()
+	for _, item := range items {
+		assert.NotEmpty(t, item["created_at"])
+		delete(item, "created_at")
 	}
-	pgCont, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: req,
-		Started:          true,
-	})
-	if err != nil {
-		log.Fatalf("failed to start container: %v", err)
-	}
-	defer func() {
-		if err := pgCont.Terminate(ctx); err != nil {
-			log.Fatalf("context termination error")
-		}
-	}()
-	// получаем адрес и порт
-	mappedPort, _ := pgCont.MappedPort(ctx, "5432")
-	host, _ := pgCont.Host(ctx)
-	// подключение к БД
-	dsn := fmt.Sprintf("postgres://test:secret@%s:%s/testdb?sslmode=disable", host, mappedPort.Port())
-	db, err = pgxpool.New(context.Background(), dsn)
-	if err != nil {
-		log.Fatalf("failed to connect to db: %v", err)
-	}
-	defer db.Close()
-	// применение миграций
-	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS links (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, original_url TEXT, short_name TEXT UNIQUE, short_url TEXT, created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP);`)
-	if err != nil {
-		log.Fatalf("failed to create table links: %v", err)
-	}
-	_, err = db.Exec(ctx, `CREATE TABLE IF NOT EXISTS link_visits (id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, link_id BIGINT NOT NULL, ip VARCHAR(45), user_agent VARCHAR(255), referer VARCHAR(500), status INT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);`)
-	if err != nil {
-		log.Fatalf("failed to create table link_visits: %v", err)
-	}
-	// добавление тестовых данных в таблицу links
-	_, err = db.Exec(ctx, "INSERT INTO links (original_url, short_name) VALUES ('https://example.com/long-url', 'exmpl'), ('https://example.com/long-url1', 'exmpl1'), ('https://example.com/long-url2', 'exmpl2'), ('https://example.com/long-url3', 'exmpl3'), ('https://example.com/long-url4', 'exmpl4'), ('https://example.com/long-url5', 'exmpl5'), ('https://example.com/long-url6', 'exmpl6'), ('https://example.com/long-url7', 'exmpl7')")
-	if err != nil {
-		log.Fatalf("error adding data to table links: %v", err)
-	}
-	// фиксируем точное время для теста
-	fixedTime := time.Date(2026, 5, 26, 12, 35, 0, 0, time.UTC)
-
-	// добавление тестовых данных в таблицу link_visits
-	_, err = db.Exec(ctx, "INSERT INTO link_visits (link_id, ip, user_agent, referer, status, created_at) VALUES (1, '192.168.10.1', 'chrome', 'www.yanex.ru', 302, $1), (2, '192.168.10.2', 'chrome', 'www.mail.ru', 302, $1), (3, '192.168.10.3', 'mozilla', 'www.vk.com', 302, $1), (4, '192.168.10.4', 'opera', 'www.ya.ru', 302, $1), (5, '192.168.10.5', 'chrome', 'www.rambler.ru', 302, $1), (6, '192.168.10.6', 'opera', 'www.ersh.su', 302, $1)", fixedTime)
-	if err != nil {
-		log.Fatalf("error adding data to table link_visits: %v", err)
-	}
-
-	// инициализация Gin
-	queries := generated.New(db)
-	gin.SetMode(gin.TestMode)
-	router = setupRouter()
-	// регистрация маршрутов
-	router.GET("/api/links", listLinks(queries))
-	router.GET("/api/links/:id", getLinkFromId(queries))
-	router.POST("/api/links", createLink(queries))
-	router.PUT("/api/links/:id", updateLink(queries))
-	router.DELETE("/api/links/:id", deleteLink(queries))
-	router.GET("/api/link_visits", listVisits(queries))
-	router.GET("/r/:code", redirectLink(queries))
-	os.Exit(m.Run())
+	return items
 }
 
 func TestPingRouteTableDriven(t *testing.T) {
+	router := newStoreRouter(newMemoryStore())
 	tests := []struct {
 		name     string
 		method   string
@@ -125,312 +56,617 @@ func TestPingRouteTableDriven(t *testing.T) {
 }
 
 func TestGetLinksRight(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, 8, len(response))
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 8, len(response))
+	})
 }
 
 func TestLinkVisits(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, 6, len(response))
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/link_visits", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, len(response))
+	})
 }
 
 func TestGetLinkFromIDRight(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/links/2", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"id": float64(2), "original_url": "https://example.com/long-url1", "short_name": "exmpl1", "short_url": nil}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/links/2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := map[string]any{"id": float64(2), "original_url": "https://example.com/long-url1", "short_name": "exmpl1", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, response)
+	})
 }
 
 func TestGetLinkFromIDWrong(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/links/33", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusNotFound, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "link not found"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/links/33", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+	})
 }
 
 func TestUpdateLink(t *testing.T) {
-	// подготовка данных
-	origUrl := "https://example.com/update_test"
-	shortUrl := pgtype.Text{String: "exmpl_update", Valid: true}
-	data := generated.UpdateLinkParams{ID: 1, OriginalUrl: origUrl, ShortName: shortUrl}
-	jsonData, _ := json.Marshal(data)
-	// выполнение запроса
-	reqUpd, _ := http.NewRequest(http.MethodPut, "/api/links/1", bytes.NewBuffer(jsonData))
-	wUpd := httptest.NewRecorder()
-	router.ServeHTTP(wUpd, reqUpd)
-	// получение обновлённой записи
-	req, _ := http.NewRequest(http.MethodGet, "/api/links/1", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"id": float64(1), "original_url": "https://example.com/update_test", "short_name": "exmpl_update", "short_url": "https://go-project-278-yoao.onrender.com/r/exmpl_update"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// подготовка данных
+		origUrl := "https://example.com/update_test"
+		shortUrl := pgtype.Text{String: "exmpl_update", Valid: true}
+		data := generated.UpdateLinkParams{ID: 1, OriginalUrl: origUrl, ShortName: shortUrl}
+		jsonData, _ := json.Marshal(data)
+		// выполнение запроса
+		reqUpd, _ := http.NewRequest(http.MethodPut, "/api/links/1", bytes.NewBuffer(jsonData))
+		wUpd := httptest.NewRecorder()
+		router.ServeHTTP(wUpd, reqUpd)
+		// получение обновлённой записи
+		req, _ := http.NewRequest(http.MethodGet, "/api/links/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := map[string]any{"id": float64(1), "original_url": "https://example.com/update_test", "short_name": "exmpl_update", "short_url": "https://go-project-278-yoao.onrender.com/r/exmpl_update", "title": nil, "folder_id": nil, "tags": []any{}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, response)
+	})
 }
 
 func TestDeleteLinkRight(t *testing.T) {
-	// выполнение запроса на удаление записи с id=2
-	reqDel, _ := http.NewRequest(http.MethodDelete, "/api/links/2", nil)
-	wDel := httptest.NewRecorder()
-	router.ServeHTTP(wDel, reqDel)
-	assert.Equal(t, http.StatusNoContent, wDel.Code)
-	// выполнение запроса на получение всех записей
-	req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, 7, len(response))
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса на удаление записи с id=2
+		reqDel, _ := http.NewRequest(http.MethodDelete, "/api/links/2", nil)
+		wDel := httptest.NewRecorder()
+		router.ServeHTTP(wDel, reqDel)
+		assert.Equal(t, http.StatusNoContent, wDel.Code)
+		// выполнение запроса на получение всех записей
+		req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, len(response))
+	})
 }
 
 func TestGetDeletedLink(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/links/2", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка на ошибку NotFound
-	assert.Equal(t, http.StatusNotFound, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "link not found"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// удаление записи с id=2
+		reqDel, _ := http.NewRequest(http.MethodDelete, "/api/links/2", nil)
+		wDel := httptest.NewRecorder()
+		router.ServeHTTP(wDel, reqDel)
+		assert.Equal(t, http.StatusNoContent, wDel.Code)
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/links/2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка на ошибку NotFound
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+	})
 }
 
 func TestDeleteLinkWrong(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodDelete, "/api/links/45", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusNotFound, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "the link does not exist"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodDelete, "/api/links/45", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+	})
 }
 
 func TestPaginationGeLinksRight(t *testing.T) {
-	// выполнение запроса
-	r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[0,2]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := []map[string]any{{"id": float64(1), "original_url": "https://example.com/update_test", "short_name": "exmpl_update", "short_url": "https://go-project-278-yoao.onrender.com/r/exmpl_update"}, {"id": float64(3), "original_url": "https://example.com/long-url2", "short_name": "exmpl2", "short_url": nil}}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[0,1]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := []map[string]any{{"id": float64(1), "original_url": "https://example.com/long-url", "short_name": "exmpl", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}, {"id": float64(2), "original_url": "https://example.com/long-url1", "short_name": "exmpl1", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, response)
+	})
 }
 
 func TestPaginationGetLinksRight2(t *testing.T) {
-	// выполнение запроса
-	r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[5,15]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := []map[string]any{{"id": float64(7), "original_url": "https://example.com/long-url6", "short_name": "exmpl6", "short_url": nil}, {"id": float64(8), "original_url": "https://example.com/long-url7", "short_name": "exmpl7", "short_url": nil}}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[5,15]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := []map[string]any{{"id": float64(6), "original_url": "https://example.com/long-url5", "short_name": "exmpl5", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}, {"id": float64(7), "original_url": "https://example.com/long-url6", "short_name": "exmpl6", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}, {"id": float64(8), "original_url": "https://example.com/long-url7", "short_name": "exmpl7", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, response)
+	})
 }
 
 func TestPaginationGetLinksRight3(t *testing.T) {
-	// выполнение запроса
-	r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[2, 2]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := []map[string]any{{"id": float64(4), "original_url": "https://example.com/long-url3", "short_name": "exmpl3", "short_url": nil}}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[2, 2]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := []map[string]any{{"id": float64(3), "original_url": "https://example.com/long-url2", "short_name": "exmpl2", "short_url": nil, "title": nil, "folder_id": nil, "tags": []any{}}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, response)
+	})
 }
 
 func TestPaginationGetLinksWrong(t *testing.T) {
-	// выполнение запроса
-	r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[15, 2]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
-	// проверка результатов
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "range values are specified incorrectly"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[15, 2]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "range values are specified incorrectly")
+	})
 }
 
 func TestPaginationGetLinksWrong2(t *testing.T) {
-	// выполнение запроса
-	r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[a, 2]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
-	// проверка результатов
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "the range must be specified by two numbers, example: [1,4]"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[a, 2]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "the range must be specified by two numbers, example: [1,4]")
+	})
 }
 
 func TestPaginationGetLinksWrong3(t *testing.T) {
-	// выполнение запроса
-	r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[a, 2, 6, 7]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
-	// проверка результатов
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "the range must be specified by two numbers, example: [1,4]"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links?range=[a, 2, 6, 7]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "the range must be specified by two numbers, example: [1,4]")
+	})
+}
+
+func TestPaginationGetLinksTooLarge(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		r, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+		r.Header.Set("Range", "links=4294967296-4294967300")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		// проверка результатов
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "the range value must not exceed 2147483647")
+	})
 }
 
 func TestLinkVisitsPaginationRight(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[1,2]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := []map[string]any{{"id": float64(2), "link_id": float64(2), "ip": "192.168.10.2", "user_agent": "chrome", "status": float64(302), "created_at": "2026-05-26T12:35:00Z"}}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[1,1]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := []map[string]any{{"id": float64(2), "link_id": float64(2), "ip": "192.168.10.2", "user_agent": "chrome", "referer": "www.mail.ru", "status": float64(302), "browser": nil, "browser_version": nil, "os": nil, "device_type": nil, "is_bot": false, "visitor_hash": nil, "destination_version": nil}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, withoutCreatedAt(t, response))
+	})
 }
 
 func TestLinkVisitsPaninationRight2(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[5,20]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := []map[string]any{{"id": float64(6), "link_id": float64(6), "ip": "192.168.10.6", "user_agent": "opera", "status": float64(302), "created_at": "2026-05-26T12:35:00Z"}}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[5,20]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := []map[string]any{{"id": float64(6), "link_id": float64(6), "ip": "192.168.10.6", "user_agent": "opera", "referer": "www.ersh.su", "status": float64(302), "browser": nil, "browser_version": nil, "os": nil, "device_type": nil, "is_bot": false, "visitor_hash": nil, "destination_version": nil}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, withoutCreatedAt(t, response))
+	})
 }
 
 func TestLinkVisitsPaninationRight3(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[3,3]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response []map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := []map[string]any{{"id": float64(4), "link_id": float64(4), "ip": "192.168.10.4", "user_agent": "opera", "status": float64(302), "created_at": "2026-05-26T12:35:00Z"}}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[3,3]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		want := []map[string]any{{"id": float64(4), "link_id": float64(4), "ip": "192.168.10.4", "user_agent": "opera", "referer": "www.ya.ru", "status": float64(302), "browser": nil, "browser_version": nil, "os": nil, "device_type": nil, "is_bot": false, "visitor_hash": nil, "destination_version": nil}}
+		assert.NoError(t, err)
+		assert.Equal(t, want, withoutCreatedAt(t, response))
+	})
 }
 
 func TestLinkPaginationWrong(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[5,1]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "range values are specified incorrectly"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[5,1]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "range values are specified incorrectly")
+	})
 }
 
 func TestLinkPaginationWrong2(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[]", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверка результатов
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error": "the range must be specified by two numbers, example: [1,4]"}
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest(http.MethodGet, "/api/link_visits?range=[]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "the range must be specified by two numbers, example: [1,4]")
+	})
 }
 
 func TestRedirectRight(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest("GET", "/r/exmpl3", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверяем HTTP-статус
-	assert.Equal(t, http.StatusFound, w.Code)
-	// проверяем заголовок Location, куда перенаправляет сервер
-	assert.Equal(t, "https://example.com/long-url3", w.Header().Get("Location"))
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest("GET", "/r/exmpl3", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверяем HTTP-статус
+		assert.Equal(t, http.StatusFound, w.Code)
+		// проверяем заголовок Location, куда перенаправляет сервер
+		assert.Equal(t, "https://example.com/long-url3", w.Header().Get("Location"))
+	})
+}
+
+func TestRedirectLongReferer(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// referer длиннее поля таблицы не мешает перенаправлению
+		req, _ := http.NewRequest("GET", "/r/exmpl3", nil)
+		req.Header.Set("Referer", "https://example.com/"+strings.Repeat("a", 1000))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/long-url3", w.Header().Get("Location"))
+	})
 }
 
 func TestRedirectWrong(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest("GET", "/r/", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	// проверяем HTTP-статус
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest("GET", "/r/", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// пустое имя: Gin убирает завершающий слеш, а маршрута /r нет
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "/r", w.Header().Get("Location"))
+		req, _ = http.NewRequest("GET", "/r", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверяем HTTP-статус
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
 }
 
 func TestRedirectWrong2(t *testing.T) {
-	// выполнение запроса
-	req, _ := http.NewRequest("GET", "/r/noname", nil)
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		req, _ := http.NewRequest("GET", "/r/noname", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// неизвестное имя: 404 без подробностей ошибки БД
+		assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+		assert.NotContains(t, w.Body.String(), "no rows")
+	})
+}
+
+func TestListLinksSortDesc(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		query := url.Values{"sort": {`["id","DESC"]`}, "range": {"[0,1]"}}
+		req, _ := http.NewRequest(http.MethodGet, "/api/links?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(response))
+		assert.Equal(t, float64(8), response[0]["id"])
+		assert.Equal(t, float64(7), response[1]["id"])
+	})
+}
+
+func TestListLinksFilter(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// выполнение запроса
+		query := url.Values{"filter": {`{"short_name":"exmpl","domain":"example.com","id":[3,4,5,33]}`}, "sort": {`["short_name","DESC"]`}}
+		req, _ := http.NewRequest(http.MethodGet, "/api/links?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// проверка результатов
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Range"), "/3")
+		var response []map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		var names []any
+		for _, link := range response {
+			names = append(names, link["short_name"])
+		}
+		assert.Equal(t, []any{"exmpl4", "exmpl3", "exmpl2"}, names)
+	})
+}
+
+func TestListLinksWrongSortAndFilter(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		tests := []struct {
+			name  string
+			query url.Values
+		}{
+			{"unknown sort field", url.Values{"sort": {`["short_url","ASC"]`}}},
+			{"wrong sort order", url.Values{"sort": {`["id","UP"]`}}},
+			{"unknown filter field", url.Values{"filter": {`{"owner":"admin"}`}}},
+			{"wrong created_at", url.Values{"filter": {`{"created_at_gte":"yesterday"}`}}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "/api/links?"+tt.query.Encode(), nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			})
+		}
+	})
+}
+
+func TestListLinksCursor(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// получение id ссылок страницы и курсоров соседних страниц
+		page := func(query url.Values) ([]any, string, string) {
+			req, _ := http.NewRequest(http.MethodGet, "/api/links?"+query.Encode(), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "", w.Header().Get("Content-Range"))
+			var response []map[string]any
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			var ids []any
+			for _, link := range response {
+				ids = append(ids, link["id"])
+			}
+			return ids, w.Header().Get("X-Next-Cursor"), w.Header().Get("X-Prev-Cursor")
+		}
+		// первая страница
+		ids, next, prev := page(url.Values{"after": {""}, "limit": {"3"}})
+		assert.Equal(t, []any{float64(1), float64(2), float64(3)}, ids)
+		assert.NotEmpty(t, next)
+		assert.Empty(t, prev)
+		// следующая страница
+		ids, _, prev = page(url.Values{"after": {next}, "limit": {"3"}})
+		assert.Equal(t, []any{float64(4), float64(5), float64(6)}, ids)
+		assert.NotEmpty(t, prev)
+		// возврат на предыдущую страницу
+		ids, _, prev = page(url.Values{"before": {prev}, "limit": {"3"}})
+		assert.Equal(t, []any{float64(1), float64(2), float64(3)}, ids)
+		assert.Empty(t, prev)
+		// обратный порядок
+		ids, _, _ = page(url.Values{"after": {""}, "limit": {"2"}, "sort": {`["id","DESC"]`}})
+		assert.Equal(t, []any{float64(8), float64(7)}, ids)
+	})
+}
+
+func TestListCursorWrong(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		tests := []struct {
+			name  string
+			path  string
+			query url.Values
+		}{
+			{"after and before", "/api/links", url.Values{"after": {""}, "before": {""}}},
+			{"invalid cursor", "/api/links", url.Values{"after": {"***"}}},
+			{"wrong limit", "/api/link_visits", url.Values{"after": {""}, "limit": {"0"}}},
+			{"sort by created_at", "/api/link_visits", url.Values{"after": {""}, "sort": {`["created_at","DESC"]`}}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, tt.path+"?"+tt.query.Encode(), nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			})
+		}
+	})
+}
+
+func TestListLinksRangeHeader(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		tests := []struct {
+			name             string
+			rangeHeader      string
+			wantCode         int
+			wantContentRange string
+			wantCount        int
+		}{
+			{"first page", "links=0-2", http.StatusPartialContent, "links 0-2/8", 3},
+			{"open end", "links=6-", http.StatusPartialContent, "links 6-7/8", 2},
+			{"beyond the end", "links=20-29", http.StatusRequestedRangeNotSatisfiable, "links */8", 0},
+			{"other unit", "bytes=0-2", http.StatusOK, "links 0-7/8", 8},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+				req.Header.Set("Range", tt.rangeHeader)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.wantCode, w.Code)
+				assert.Equal(t, tt.wantContentRange, w.Header().Get("Content-Range"))
+				if tt.wantCount > 0 {
+					var response []map[string]any
+					err := json.Unmarshal(w.Body.Bytes(), &response)
+					assert.NoError(t, err)
+					assert.Equal(t, tt.wantCount, len(response))
+				}
+			})
+		}
+	})
+}
+
+func TestListContentRange(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// конец диапазона равен индексу последней выведенной записи
+		req, _ := http.NewRequest(http.MethodGet, "/api/links?range=[5,15]", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "links 5-7/8", w.Header().Get("Content-Range"))
+
+		// строгий разбор параметра range
+		req, _ = http.NewRequest(http.MethodGet, "/api/link_visits?range=abc1x2", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestStreamVisits(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		server := httptest.NewServer(router)
+		defer server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/link_visits/stream?link_id=7", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		// переходы по другой ссылке в поток не попадают
+		for _, code := range []string{"exmpl5", "exmpl6"} {
+			reqVisit, _ := http.NewRequest(http.MethodGet, "/r/"+code, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, reqVisit)
+			assert.Equal(t, http.StatusFound, w.Code)
+		}
+		reader := bufio.NewReader(resp.Body)
+		var event, data string
+		for data == "" {
+			line, err := reader.ReadString('\n')
+			if !assert.NoError(t, err) {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				event = value
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data = value
+			}
+		}
+		assert.Equal(t, "visit", event)
+		var visit map[string]any
+		err = json.Unmarshal([]byte(data), &visit)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(7), visit["link_id"])
+		// неверный фильтр
+		reqWrong, _ := http.NewRequest(http.MethodGet, "/api/link_visits/stream?link_id=abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, reqWrong)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestOpenAPIEndpoints(t *testing.T) {
+	router := newStoreRouter(newMemoryStore())
 	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
 	router.ServeHTTP(w, req)
-	// проверка результатов
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	want := map[string]any{"error of receiving the id and original url": "no rows in result set"}
-	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
-	assert.NoError(t, err)
-	assert.Equal(t, want, response)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var spec map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.1.0", spec["openapi"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/docs", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/api/openapi.json")
+}
+
+func TestProblemResponses(t *testing.T) {
+	forEachStore(t, func(t *testing.T, router *gin.Engine) {
+		// переименование в занятое короткое имя
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/links/1", bytes.NewBufferString(`{"original_url":"https://example.com/update_test","short_name":"exmpl5"}`))
+		router.ServeHTTP(w, req)
+		body := assertProblem(t, w, http.StatusConflict, codeShortNameTaken, `short name "exmpl5" is already taken`)
+		assert.Equal(t, "exmpl5", body.ShortName)
+		assert.NotEmpty(t, body.Suggestions)
+
+		// неверный id
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/links/abc", nil)
+		router.ServeHTTP(w, req)
+		assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+
+		// ошибки проверки полей
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/api/links", bytes.NewBufferString(`{"original_url":"not a url"}`))
+		router.ServeHTTP(w, req)
+		body = assertProblem(t, w, http.StatusUnprocessableEntity, codeValidationFailed, "request validation failed")
+		assert.Equal(t, "url", body.Errors["OriginalUrl"])
+
+		// неизвестный маршрут
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/api/unknown", nil)
+		router.ServeHTTP(w, req)
+		assertProblem(t, w, http.StatusNotFound, codeRouteNotFound, "route not found")
+	})
 }
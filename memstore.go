@@ -0,0 +1,1579 @@
+package main
+
+import (
+	"cmp"
+	generated "code/db/generated"
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// хранилище в памяти процесса, безопасное для одновременного доступа.
+// Повторяет поведение запросов к Postgres, кроме полнотекстового поиска:
+// фильтр q требует вхождения всех слов запроса, а релевантность
+// считает rankSearchResults
+type memoryStore struct {
+	// блокировка данных; внутри InTx её держит транзакция
+	mu  memoryLocker
+	now func() time.Time
+	*memoryData
+}
+
+// данные хранилища в памяти; транзакция работает с ними напрямую
+// и при ошибке возвращает копию, снятую в начале
+type memoryData struct {
+	links          map[int64]*memoryLink
+	lastLinkID     int64
+	visits         []generated.LinkVisit
+	lastVisitID    int64
+	daily          []generated.LinkVisitDaily
+	destinations   map[int64][]generated.LinkDestination
+	salts          map[string][]byte
+	audit          []generated.LinkAudit
+	folders        map[int64]*generated.Folder
+	lastFolderID   int64
+	tags           map[int64]*generated.Tag
+	lastTagID      int64
+	bots           map[int64]*generated.BotSignature
+	lastBotID      int64
+	webhooks       map[int64]*generated.Webhook
+	lastWebhookID  int64
+	deliveries     []generated.WebhookDelivery
+	lastDeliveryID int64
+}
+
+// блокировка данных хранилища: sync.RWMutex или memoryTxLock
+type memoryLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// блокировка хранилища внутри транзакции: данные уже заблокированы InTx
+type memoryTxLock struct{}
+
+func (memoryTxLock) Lock()    {}
+func (memoryTxLock) Unlock()  {}
+func (memoryTxLock) RLock()   {}
+func (memoryTxLock) RUnlock() {}
+
+// ссылка с именами меток
+type memoryLink struct {
+	generated.Link
+	tags []string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		mu:  &sync.RWMutex{},
+		now: time.Now,
+		memoryData: &memoryData{
+			links:        make(map[int64]*memoryLink),
+			destinations: make(map[int64][]generated.LinkDestination),
+			salts:        make(map[string][]byte),
+			folders:      make(map[int64]*generated.Folder),
+			tags:         make(map[int64]*generated.Tag),
+			bots:         make(map[int64]*generated.BotSignature),
+			webhooks:     make(map[int64]*generated.Webhook),
+		},
+	}
+}
+
+// полная копия данных для отката транзакции
+func (d *memoryData) clone() *memoryData {
+	c := *d
+	c.links = make(map[int64]*memoryLink, len(d.links))
+	for id, link := range d.links {
+		copied := *link
+		copied.tags = slices.Clone(link.tags)
+		c.links[id] = &copied
+	}
+	c.visits = slices.Clone(d.visits)
+	c.destinations = make(map[int64][]generated.LinkDestination, len(d.destinations))
+	for id, history := range d.destinations {
+		c.destinations[id] = slices.Clone(history)
+	}
+	c.salts = make(map[string][]byte, len(d.salts))
+	for day, salt := range d.salts {
+		c.salts[day] = salt
+	}
+	c.audit = slices.Clone(d.audit)
+	c.folders = cloneMap(d.folders)
+	c.daily = slices.Clone(d.daily)
+	c.tags = cloneMap(d.tags)
+	c.bots = cloneMap(d.bots)
+	c.webhooks = cloneMap(d.webhooks)
+	c.deliveries = slices.Clone(d.deliveries)
+	return &c
+}
+
+// копия таблицы с копиями записей
+func cloneMap[T any](items map[int64]*T) map[int64]*T {
+	c := make(map[int64]*T, len(items))
+	for id, item := range items {
+		copied := *item
+		c[id] = &copied
+	}
+	return c
+}
+
+var _ Store = (*memoryStore)(nil)
+
+// транзакции выполняются по очереди и не пересекаются с другими запросами
+func (s *memoryStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := s.memoryData.clone()
+	tx := &memoryStore{mu: memoryTxLock{}, now: s.now, memoryData: s.memoryData}
+	if err := fn(tx); err != nil {
+		*s.memoryData = *saved
+		return err
+	}
+	return nil
+}
+
+// ошибки в том виде, в каком их возвращает Postgres
+func memoryUniqueViolation(constraint string) error {
+	return &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: constraint, Message: "duplicate key value violates unique constraint"}
+}
+
+func memoryForeignKeyViolation(constraint string) error {
+	return &pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: constraint, Message: "insert or update violates foreign key constraint"}
+}
+
+// ограничения колонки short_name VARCHAR(32) CHECK (CHAR_LENGTH(short_name) >= 3)
+func memoryShortNameViolation(name string) error {
+	switch n := utf8.RuneCountInString(name); {
+	case n > maxShortNameLength:
+		return &pgconn.PgError{Code: pgStringTooLong, Message: "value too long for type character varying(32)"}
+	case n < minShortNameLength:
+		return &pgconn.PgError{Code: pgCheckViolation, TableName: "links", ConstraintName: "links_short_name_check", Message: "new row violates check constraint"}
+	}
+	return nil
+}
+
+func (s *memoryStore) timestamp() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: s.now(), Valid: true}
+}
+
+// проверка ограничений таблицы links перед записью
+func (s *memoryStore) checkLink(id int64, shortName pgtype.Text, folderID pgtype.Int8) error {
+	if _, ok := s.folders[folderID.Int64]; folderID.Valid && !ok {
+		return memoryForeignKeyViolation("links_folder_id_fkey")
+	}
+	if !shortName.Valid {
+		return nil
+	}
+	if err := memoryShortNameViolation(shortName.String); err != nil {
+		return err
+	}
+	for _, link := range s.links {
+		if link.ID != id && link.ShortName.Valid && link.ShortName.String == shortName.String {
+			return memoryUniqueViolation(shortNameConstraint)
+		}
+	}
+	return nil
+}
+
+// копия меток; у ссылки без меток пустой список, как '{}' в Postgres
+func (l *memoryLink) tagList() []string {
+	if len(l.tags) == 0 {
+		return []string{}
+	}
+	return slices.Clone(l.tags)
+}
+
+// условия фильтра списка ссылок, как в WHERE запроса ListLinks
+func (l *memoryLink) matches(cond linkConditions) bool {
+	if l.DeletedAt.Valid {
+		return false
+	}
+	if cond.ShortNamePrefix.Valid && (!l.ShortName.Valid || !matchLike(l.ShortName.String, cond.ShortNamePrefix.String+"%", false)) {
+		return false
+	}
+	if cond.Domain.Valid && !matchLike(urlHost(l.OriginalUrl), "%"+cond.Domain.String+"%", true) {
+		return false
+	}
+	if cond.CreatedFrom.Valid && l.CreatedAt.Time.Before(cond.CreatedFrom.Time) {
+		return false
+	}
+	if cond.CreatedTo.Valid && l.CreatedAt.Time.After(cond.CreatedTo.Time) {
+		return false
+	}
+	if cond.Ids != nil && !slices.Contains(cond.Ids, l.ID) {
+		return false
+	}
+	if cond.Search.Valid && !l.matchesSearch(cond.Search.String, cond.SearchPattern.String) {
+		return false
+	}
+	if cond.Tag.Valid && !slices.Contains(l.tags, cond.Tag.String) {
+		return false
+	}
+	if cond.FolderID.Valid && (!l.FolderID.Valid || l.FolderID.Int64 != cond.FolderID.Int64) {
+		return false
+	}
+	return true
+}
+
+// поиск по словам в названии, коротком имени и адресе или по подстроке
+func (l *memoryLink) matchesSearch(search string, pattern string) bool {
+	for _, field := range []string{l.OriginalUrl, l.ShortName.String, l.Title.String} {
+		if matchLike(field, "%"+pattern+"%", true) {
+			return true
+		}
+	}
+	// запрос без слов, как пустой websearch_to_tsquery, ничего не находит
+	terms := searchWords(search)
+	if len(terms) == 0 {
+		return false
+	}
+	words := searchWords(l.Title.String + " " + l.ShortName.String + " " + l.OriginalUrl)
+	for _, word := range terms {
+		if !slices.Contains(words, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// слова текста в нижнем регистре, разделители — всё, кроме букв и цифр
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// хост адреса, как split_part(split_part(url, '://', 2), '/', 1)
+func urlHost(url string) string {
+	_, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return ""
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return host
+}
+
+// сравнение с шаблоном LIKE: % — любая строка, _ — один символ,
+// \ экранирует следующий символ. fold сравнивает без учёта регистра, как ILIKE
+func matchLike(value string, pattern string, fold bool) bool {
+	if fold {
+		value, pattern = strings.ToLower(value), strings.ToLower(pattern)
+	}
+	return matchLikeRunes([]rune(value), []rune(pattern))
+}
+
+func matchLikeRunes(value []rune, pattern []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range len(value) + 1 {
+				if matchLikeRunes(value[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(value) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(value) == 0 || value[0] != pattern[0] {
+				return false
+			}
+		}
+		value, pattern = value[1:], pattern[1:]
+	}
+	return len(value) == 0
+}
+
+// порядок ORDER BY запроса ListLinks: NULL после значений по возрастанию
+// и перед ними по убыванию, при равенстве — по id в том же направлении
+func compareLinks(a, b *memoryLink, field string, desc bool) int {
+	var order int
+	switch field {
+	case "original_url":
+		order = strings.Compare(a.OriginalUrl, b.OriginalUrl)
+	case "short_name":
+		order = compareNullable(a.ShortName.String, a.ShortName.Valid, b.ShortName.String, b.ShortName.Valid)
+	case "created_at":
+		order = compareNullable(a.CreatedAt.Time.UnixNano(), a.CreatedAt.Valid, b.CreatedAt.Time.UnixNano(), b.CreatedAt.Valid)
+	}
+	if order == 0 {
+		order = cmp.Compare(a.ID, b.ID)
+	}
+	if desc {
+		return -order
+	}
+	return order
+}
+
+func compareNullable[T cmp.Ordered](a T, aValid bool, b T, bValid bool) int {
+	switch {
+	case aValid && bValid:
+		return cmp.Compare(a, b)
+	case aValid:
+		return -1
+	case bValid:
+		return 1
+	}
+	return 0
+}
+
+// страница LIMIT/OFFSET
+func pageOf[T any](items []T, limit int32, offset int32) []T {
+	start := min(int(offset), len(items))
+	end := min(start+int(limit), len(items))
+	return items[start:end]
+}
+
+// выборка по курсору, как в запросах ...ByCursorAsc и ...ByCursorDesc:
+// id после курсора в направлении просмотра, не больше limit записей
+func cursorPageOf[T any](items []T, id func(T) int64, cursor int64, scanDesc bool, limit int32) []T {
+	var page []T
+	for _, item := range items {
+		if (scanDesc && id(item) < cursor) || (!scanDesc && id(item) > cursor) {
+			page = append(page, item)
+		}
+	}
+	slices.SortFunc(page, func(a, b T) int {
+		if scanDesc {
+			return cmp.Compare(id(b), id(a))
+		}
+		return cmp.Compare(id(a), id(b))
+	})
+	return pageOf(page, limit, 0)
+}
+
+func (s *memoryStore) CounterLinks(ctx context.Context, arg generated.CounterLinksParams) (int64, error) {
+	cond := linkConditions{
+		ShortNamePrefix: arg.ShortNamePrefix,
+		Domain:          arg.Domain,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		Ids:             arg.Ids,
+		Search:          arg.Search,
+		SearchPattern:   arg.SearchPattern,
+		Tag:             arg.Tag,
+		FolderID:        arg.FolderID,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count int64
+	for _, link := range s.links {
+		if link.matches(cond) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) CreateLink(ctx context.Context, arg generated.CreateLinkParams) (generated.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkLink(0, arg.ShortName, arg.FolderID); err != nil {
+		return generated.Link{}, err
+	}
+	s.lastLinkID++
+	link := &memoryLink{Link: generated.Link{
+		ID:                 s.lastLinkID,
+		OriginalUrl:        arg.OriginalUrl,
+		ShortName:          arg.ShortName,
+		CreatedAt:          s.timestamp(),
+		Title:              arg.Title,
+		DestinationVersion: 1,
+		FolderID:           arg.FolderID,
+	}}
+	s.links[link.ID] = link
+	return link.Link, nil
+}
+
+func (s *memoryStore) GetLink(ctx context.Context, id int64) (generated.GetLinkRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	link, ok := s.links[id]
+	if !ok || link.DeletedAt.Valid {
+		return generated.GetLinkRow{}, pgx.ErrNoRows
+	}
+	return generated.GetLinkRow{
+		ID:          link.ID,
+		OriginalUrl: link.OriginalUrl,
+		ShortName:   link.ShortName,
+		ShortUrl:    link.ShortUrl,
+		Title:       link.Title,
+		FolderID:    link.FolderID,
+		Tags:        link.tagList(),
+	}, nil
+}
+
+// транзакция InTx уже держит блокировку всего хранилища
+func (s *memoryStore) GetLinkForUpdate(ctx context.Context, id int64) (generated.GetLinkForUpdateRow, error) {
+	link, err := s.GetLink(ctx, id)
+	return generated.GetLinkForUpdateRow(link), err
+}
+
+func (s *memoryStore) GetLinkFromCode(ctx context.Context, shortName pgtype.Text) (generated.GetLinkFromCodeRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, link := range s.links {
+		if shortName.Valid && link.ShortName.Valid && link.ShortName.String == shortName.String {
+			return generated.GetLinkFromCodeRow{
+				ID:                 link.ID,
+				OriginalUrl:        link.OriginalUrl,
+				DeletedAt:          link.DeletedAt,
+				DestinationVersion: link.DestinationVersion,
+			}, nil
+		}
+	}
+	return generated.GetLinkFromCodeRow{}, pgx.ErrNoRows
+}
+
+func (s *memoryStore) LastLink(ctx context.Context) (generated.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var last *memoryLink
+	for _, link := range s.links {
+		if last == nil || link.ID > last.ID {
+			last = link
+		}
+	}
+	if last == nil {
+		return generated.Link{}, pgx.ErrNoRows
+	}
+	return last.Link, nil
+}
+
+func (s *memoryStore) ListLinks(ctx context.Context, arg generated.ListLinksParams) ([]generated.ListLinksRow, error) {
+	cond := linkConditions{
+		ShortNamePrefix: arg.ShortNamePrefix,
+		Domain:          arg.Domain,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		Ids:             arg.Ids,
+		Search:          arg.Search,
+		SearchPattern:   arg.SearchPattern,
+		Tag:             arg.Tag,
+		FolderID:        arg.FolderID,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var links []*memoryLink
+	for _, link := range s.links {
+		if link.matches(cond) {
+			links = append(links, link)
+		}
+	}
+	slices.SortFunc(links, func(a, b *memoryLink) int { return compareLinks(a, b, arg.SortField, arg.SortDesc) })
+	var rows []generated.ListLinksRow
+	for _, link := range pageOf(links, arg.Limit, arg.Offset) {
+		rows = append(rows, generated.ListLinksRow{
+			ID:          link.ID,
+			OriginalUrl: link.OriginalUrl,
+			ShortName:   link.ShortName,
+			ShortUrl:    link.ShortUrl,
+			Title:       link.Title,
+			FolderID:    link.FolderID,
+			Tags:        link.tagList(),
+		})
+	}
+	return rows, nil
+}
+
+func (s *memoryStore) ListLinksByCursorAsc(ctx context.Context, arg generated.ListLinksByCursorAscParams) ([]generated.ListLinksByCursorAscRow, error) {
+	return s.listLinksByCursor(arg, false), nil
+}
+
+func (s *memoryStore) ListLinksByCursorDesc(ctx context.Context, arg generated.ListLinksByCursorDescParams) ([]generated.ListLinksByCursorDescRow, error) {
+	var rows []generated.ListLinksByCursorDescRow
+	for _, row := range s.listLinksByCursor(generated.ListLinksByCursorAscParams(arg), true) {
+		rows = append(rows, generated.ListLinksByCursorDescRow(row))
+	}
+	return rows, nil
+}
+
+// у запросов по курсору в обе стороны одинаковые параметры и строки
+func (s *memoryStore) listLinksByCursor(arg generated.ListLinksByCursorAscParams, scanDesc bool) []generated.ListLinksByCursorAscRow {
+	cond := linkConditions{
+		ShortNamePrefix: arg.ShortNamePrefix,
+		Domain:          arg.Domain,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		Ids:             arg.Ids,
+		Search:          arg.Search,
+		SearchPattern:   arg.SearchPattern,
+		Tag:             arg.Tag,
+		FolderID:        arg.FolderID,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var links []*memoryLink
+	for _, link := range s.links {
+		if link.matches(cond) {
+			links = append(links, link)
+		}
+	}
+	var rows []generated.ListLinksByCursorAscRow
+	for _, link := range cursorPageOf(links, func(l *memoryLink) int64 { return l.ID }, arg.CursorID, scanDesc, arg.Limit) {
+		rows = append(rows, generated.ListLinksByCursorAscRow{
+			ID:          link.ID,
+			OriginalUrl: link.OriginalUrl,
+			ShortName:   link.ShortName,
+			ShortUrl:    link.ShortUrl,
+			Title:       link.Title,
+			FolderID:    link.FolderID,
+			Tags:        link.tagList(),
+		})
+	}
+	return rows
+}
+
+func (s *memoryStore) RestoreLink(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[id]
+	if !ok || !link.DeletedAt.Valid {
+		return 0, nil
+	}
+	link.DeletedAt = pgtype.Timestamptz{}
+	return 1, nil
+}
+
+func (s *memoryStore) SoftDeleteLink(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[id]
+	if !ok || link.DeletedAt.Valid {
+		return 0, nil
+	}
+	link.DeletedAt = s.timestamp()
+	return 1, nil
+}
+
+func (s *memoryStore) UpdateLink(ctx context.Context, arg generated.UpdateLinkParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[arg.ID]
+	if !ok {
+		return nil
+	}
+	if err := s.checkLink(arg.ID, arg.ShortName, arg.FolderID); err != nil {
+		return err
+	}
+	link.OriginalUrl = arg.OriginalUrl
+	link.ShortName = arg.ShortName
+	link.Title = arg.Title
+	link.FolderID = arg.FolderID
+	return nil
+}
+
+func (s *memoryStore) UpdateShortName(ctx context.Context, arg generated.UpdateShortNameParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if link, ok := s.links[arg.ID]; ok {
+		link.ShortUrl = arg.ShortUrl
+	}
+	return nil
+}
+
+func (s *memoryStore) SearchLinks(ctx context.Context, arg generated.SearchLinksParams) ([]generated.SearchLinksRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows []generated.SearchLinksRow
+	for _, link := range s.links {
+		if link.DeletedAt.Valid || !link.matchesSearch(arg.Search, arg.SearchPattern) {
+			continue
+		}
+		rows = append(rows, generated.SearchLinksRow{
+			ID:          link.ID,
+			OriginalUrl: link.OriginalUrl,
+			ShortName:   link.ShortName,
+			ShortUrl:    link.ShortUrl,
+			Title:       link.Title,
+		})
+	}
+	return rankSearchResults(rows, arg.Search, arg.Limit, arg.Offset), nil
+}
+
+func (s *memoryStore) CounterDeletedLinks(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count int64
+	for _, link := range s.links {
+		if link.DeletedAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) ListDeletedLinks(ctx context.Context, arg generated.ListDeletedLinksParams) ([]generated.ListDeletedLinksRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows []generated.ListDeletedLinksRow
+	for _, link := range s.links {
+		if !link.DeletedAt.Valid {
+			continue
+		}
+		rows = append(rows, generated.ListDeletedLinksRow{
+			ID:          link.ID,
+			OriginalUrl: link.OriginalUrl,
+			ShortName:   link.ShortName,
+			ShortUrl:    link.ShortUrl,
+			Title:       link.Title,
+			DeletedAt:   link.DeletedAt,
+		})
+	}
+	// ORDER BY deleted_at DESC, id DESC
+	slices.SortFunc(rows, func(a, b generated.ListDeletedLinksRow) int {
+		if order := b.DeletedAt.Time.Compare(a.DeletedAt.Time); order != 0 {
+			return order
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return slices.Clone(pageOf(rows, arg.Limit, arg.Offset)), nil
+}
+
+// окончательное удаление ссылок из корзины; переходы, агрегаты и история
+// адресов удаляются вместе с ними, как ON DELETE CASCADE
+func (s *memoryStore) PurgeDeletedLinks(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged int64
+	for id, link := range s.links {
+		if link.DeletedAt.Valid && link.DeletedAt.Time.Before(deletedAt.Time) {
+			delete(s.links, id)
+			delete(s.destinations, id)
+			purged++
+		}
+	}
+	s.visits = slices.DeleteFunc(s.visits, func(v generated.LinkVisit) bool {
+		_, ok := s.links[v.LinkID]
+		return !ok
+	})
+	s.daily = slices.DeleteFunc(s.daily, func(d generated.LinkVisitDaily) bool {
+		_, ok := s.links[d.LinkID]
+		return !ok
+	})
+	return purged, nil
+}
+
+// проверка уникальности имени папки
+func (s *memoryStore) checkFolder(id int64, name string) error {
+	for _, folder := range s.folders {
+		if folder.ID != id && folder.Name == name {
+			return memoryUniqueViolation("folders_name_key")
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) CreateFolder(ctx context.Context, name string) (generated.Folder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkFolder(0, name); err != nil {
+		return generated.Folder{}, err
+	}
+	s.lastFolderID++
+	folder := &generated.Folder{
+		ID:        s.lastFolderID,
+		Name:      name,
+		CreatedAt: s.timestamp(),
+	}
+	s.folders[folder.ID] = folder
+	return *folder, nil
+}
+
+// удаление папки; ссылки остаются без папки, как ON DELETE SET NULL
+func (s *memoryStore) DeleteFolder(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.folders[id]; !ok {
+		return 0, nil
+	}
+	delete(s.folders, id)
+	for _, link := range s.links {
+		if link.FolderID.Valid && link.FolderID.Int64 == id {
+			link.FolderID = pgtype.Int8{}
+		}
+	}
+	return 1, nil
+}
+
+func (s *memoryStore) ListFolders(ctx context.Context) ([]generated.ListFoldersRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows := make([]generated.ListFoldersRow, 0, len(s.folders))
+	for _, folder := range s.folders {
+		row := generated.ListFoldersRow{
+			ID:        folder.ID,
+			Name:      folder.Name,
+			CreatedAt: folder.CreatedAt,
+		}
+		for _, link := range s.links {
+			if link.FolderID.Valid && link.FolderID.Int64 == folder.ID && !link.DeletedAt.Valid {
+				row.Links++
+			}
+		}
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b generated.ListFoldersRow) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return rows, nil
+}
+
+func (s *memoryStore) UpdateFolder(ctx context.Context, arg generated.UpdateFolderParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	folder, ok := s.folders[arg.ID]
+	if !ok {
+		return 0, nil
+	}
+	if err := s.checkFolder(arg.ID, arg.Name); err != nil {
+		return 0, err
+	}
+	folder.Name = arg.Name
+	return 1, nil
+}
+
+func (s *memoryStore) AddLinkDestination(ctx context.Context, arg generated.AddLinkDestinationParams) (int32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[arg.LinkID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	history := s.destinations[arg.LinkID]
+	version := int32(len(history) + 1)
+	s.destinations[arg.LinkID] = append(history, generated.LinkDestination{
+		LinkID:      arg.LinkID,
+		Version:     version,
+		OriginalUrl: arg.OriginalUrl,
+		CreatedAt:   s.timestamp(),
+	})
+	link.DestinationVersion = version
+	return version, nil
+}
+
+// история адресов ссылки, новые версии первыми, с числом переходов по каждой
+func (s *memoryStore) ListLinkDestinations(ctx context.Context, linkID int64) ([]generated.ListLinkDestinationsRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	link, ok := s.links[linkID]
+	if !ok {
+		return nil, nil
+	}
+	var rows []generated.ListLinkDestinationsRow
+	for _, dest := range slices.Backward(s.destinations[linkID]) {
+		row := generated.ListLinkDestinationsRow{
+			Version:     dest.Version,
+			OriginalUrl: dest.OriginalUrl,
+			CreatedAt:   dest.CreatedAt,
+			Active:      dest.Version == link.DestinationVersion,
+		}
+		for _, visit := range s.visits {
+			if visit.LinkID == linkID && visit.DestinationVersion.Valid && visit.DestinationVersion.Int32 == dest.Version {
+				row.Clicks++
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (s *memoryStore) RollbackLinkDestination(ctx context.Context, arg generated.RollbackLinkDestinationParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[arg.LinkID]
+	if !ok || link.DeletedAt.Valid {
+		return 0, nil
+	}
+	for _, dest := range s.destinations[arg.LinkID] {
+		if dest.Version == arg.Version {
+			link.OriginalUrl = dest.OriginalUrl
+			link.DestinationVersion = dest.Version
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) SetLinkTags(ctx context.Context, arg generated.SetLinkTagsParams) error {
+	tags := slices.Clone(arg.Names)
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[arg.LinkID]
+	if !ok {
+		return nil
+	}
+	for _, name := range tags {
+		s.ensureTag(name)
+	}
+	link.tags = tags
+	return nil
+}
+
+// проверка уникальности имени метки
+func (s *memoryStore) checkTag(id int64, name string) error {
+	for _, tag := range s.tags {
+		if tag.ID != id && tag.Name == name {
+			return memoryUniqueViolation("tags_name_key")
+		}
+	}
+	return nil
+}
+
+// метка с именем name; создаётся, если её ещё нет
+func (s *memoryStore) ensureTag(name string) {
+	if s.checkTag(0, name) != nil {
+		return
+	}
+	s.lastTagID++
+	s.tags[s.lastTagID] = &generated.Tag{
+		ID:        s.lastTagID,
+		Name:      name,
+		CreatedAt: s.timestamp(),
+	}
+}
+
+func (s *memoryStore) CreateTag(ctx context.Context, name string) (generated.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkTag(0, name); err != nil {
+		return generated.Tag{}, err
+	}
+	s.ensureTag(name)
+	return *s.tags[s.lastTagID], nil
+}
+
+// удаление метки вместе с её привязками к ссылкам
+func (s *memoryStore) DeleteTag(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tag, ok := s.tags[id]
+	if !ok {
+		return 0, nil
+	}
+	delete(s.tags, id)
+	for _, link := range s.links {
+		link.tags = slices.DeleteFunc(link.tags, func(name string) bool { return name == tag.Name })
+	}
+	return 1, nil
+}
+
+func (s *memoryStore) ListTags(ctx context.Context) ([]generated.ListTagsRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows := make([]generated.ListTagsRow, 0, len(s.tags))
+	for _, tag := range s.tags {
+		row := generated.ListTagsRow{
+			ID:        tag.ID,
+			Name:      tag.Name,
+			CreatedAt: tag.CreatedAt,
+		}
+		for _, link := range s.links {
+			if !link.DeletedAt.Valid && slices.Contains(link.tags, tag.Name) {
+				row.Links++
+			}
+		}
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b generated.ListTagsRow) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return rows, nil
+}
+
+// переименование метки; ссылки хранят имена, поэтому меняются и они
+func (s *memoryStore) UpdateTag(ctx context.Context, arg generated.UpdateTagParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tag, ok := s.tags[arg.ID]
+	if !ok {
+		return 0, nil
+	}
+	if err := s.checkTag(arg.ID, arg.Name); err != nil {
+		return 0, err
+	}
+	for _, link := range s.links {
+		if i := slices.Index(link.tags, tag.Name); i >= 0 {
+			link.tags[i] = arg.Name
+			slices.Sort(link.tags)
+		}
+	}
+	tag.Name = arg.Name
+	return 1, nil
+}
+
+// переходы по меткам за период: ссылки метки, включая удалённые,
+// как LEFT JOIN link_tags в запросе TagStats
+func (s *memoryStore) TagStats(ctx context.Context, arg generated.TagStatsParams) ([]generated.TagStatsRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	byLink := s.groupVisits(arg.IncludeBots, arg.PeriodStart, arg.PeriodEnd,
+		func(v generated.LinkVisit) (string, bool) { return strconv.FormatInt(v.LinkID, 10), true },
+		func(d generated.LinkVisitDaily) (string, bool) { return strconv.FormatInt(d.LinkID, 10), true },
+	)
+	rows := make([]generated.TagStatsRow, 0, len(s.tags))
+	for _, tag := range s.tags {
+		row := generated.TagStatsRow{ID: tag.ID, Name: tag.Name}
+		for _, link := range s.links {
+			if !slices.Contains(link.tags, tag.Name) {
+				continue
+			}
+			row.Links++
+			if group, ok := byLink[strconv.FormatInt(link.ID, 10)]; ok {
+				row.Clicks += group.clicks
+				row.UniqueClicks += group.uniqueClicks()
+			}
+		}
+		rows = append(rows, row)
+	}
+	// ORDER BY clicks DESC, t.name
+	slices.SortFunc(rows, func(a, b generated.TagStatsRow) int {
+		if order := cmp.Compare(b.Clicks, a.Clicks); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return rows, nil
+}
+
+// условия фильтра списка переходов, как в WHERE запроса ListLinkVisits
+func visitMatches(visit generated.LinkVisit, cond visitConditions) bool {
+	if cond.LinkID.Valid && visit.LinkID != cond.LinkID.Int64 {
+		return false
+	}
+	if cond.CreatedFrom.Valid && visit.CreatedAt.Time.Before(cond.CreatedFrom.Time) {
+		return false
+	}
+	if cond.CreatedTo.Valid && visit.CreatedAt.Time.After(cond.CreatedTo.Time) {
+		return false
+	}
+	if cond.Status.Valid && (!visit.Status.Valid || visit.Status.Int32 != cond.Status.Int32) {
+		return false
+	}
+	if cond.Referer.Valid && (!visit.Referer.Valid || !matchLike(visit.Referer.String, "%"+cond.Referer.String+"%", true)) {
+		return false
+	}
+	if cond.UserAgent.Valid && (!visit.UserAgent.Valid || !matchLike(visit.UserAgent.String, "%"+cond.UserAgent.String+"%", true)) {
+		return false
+	}
+	return true
+}
+
+// переходы, подходящие под фильтр, по возрастанию id
+func (s *memoryStore) filterVisits(cond visitConditions) []generated.LinkVisit {
+	var visits []generated.LinkVisit
+	for _, visit := range s.visits {
+		if visitMatches(visit, cond) {
+			visits = append(visits, visit)
+		}
+	}
+	return visits
+}
+
+func (s *memoryStore) CounterVisits(ctx context.Context, arg generated.CounterVisitsParams) (int64, error) {
+	cond := visitConditions{
+		LinkID:      arg.LinkID,
+		CreatedFrom: arg.CreatedFrom,
+		CreatedTo:   arg.CreatedTo,
+		Status:      arg.Status,
+		Referer:     arg.Referer,
+		UserAgent:   arg.UserAgent,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return int64(len(s.filterVisits(cond))), nil
+}
+
+func (s *memoryStore) CreateLinkVisits(ctx context.Context, arg generated.CreateLinkVisitsParams) (generated.LinkVisit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastVisitID++
+	visit := generated.LinkVisit{
+		ID:                 s.lastVisitID,
+		LinkID:             arg.LinkID,
+		Ip:                 arg.Ip,
+		UserAgent:          arg.UserAgent,
+		Referer:            arg.Referer,
+		Status:             arg.Status,
+		CreatedAt:          s.timestamp(),
+		Browser:            arg.Browser,
+		BrowserVersion:     arg.BrowserVersion,
+		Os:                 arg.Os,
+		DeviceType:         arg.DeviceType,
+		IsBot:              arg.IsBot,
+		VisitorHash:        arg.VisitorHash,
+		DestinationVersion: arg.DestinationVersion,
+	}
+	s.visits = append(s.visits, visit)
+	return visit, nil
+}
+
+func (s *memoryStore) ListLinkVisits(ctx context.Context, arg generated.ListLinkVisitsParams) ([]generated.LinkVisit, error) {
+	cond := visitConditions{
+		LinkID:      arg.LinkID,
+		CreatedFrom: arg.CreatedFrom,
+		CreatedTo:   arg.CreatedTo,
+		Status:      arg.Status,
+		Referer:     arg.Referer,
+		UserAgent:   arg.UserAgent,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	visits := s.filterVisits(cond)
+	slices.SortStableFunc(visits, func(a, b generated.LinkVisit) int {
+		order := 0
+		if arg.SortField == "created_at" {
+			order = compareNullable(a.CreatedAt.Time.UnixNano(), a.CreatedAt.Valid, b.CreatedAt.Time.UnixNano(), b.CreatedAt.Valid)
+		}
+		if order == 0 {
+			order = cmp.Compare(a.ID, b.ID)
+		}
+		if arg.SortDesc {
+			return -order
+		}
+		return order
+	})
+	return slices.Clone(pageOf(visits, arg.Limit, arg.Offset)), nil
+}
+
+func (s *memoryStore) ListLinkVisitsByCursorAsc(ctx context.Context, arg generated.ListLinkVisitsByCursorAscParams) ([]generated.LinkVisit, error) {
+	return s.listLinkVisitsByCursor(arg, false), nil
+}
+
+func (s *memoryStore) ListLinkVisitsByCursorDesc(ctx context.Context, arg generated.ListLinkVisitsByCursorDescParams) ([]generated.LinkVisit, error) {
+	return s.listLinkVisitsByCursor(generated.ListLinkVisitsByCursorAscParams(arg), true), nil
+}
+
+func (s *memoryStore) listLinkVisitsByCursor(arg generated.ListLinkVisitsByCursorAscParams, scanDesc bool) []generated.LinkVisit {
+	cond := visitConditions{
+		LinkID:      arg.LinkID,
+		CreatedFrom: arg.CreatedFrom,
+		CreatedTo:   arg.CreatedTo,
+		Status:      arg.Status,
+		Referer:     arg.Referer,
+		UserAgent:   arg.UserAgent,
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	visits := cursorPageOf(s.filterVisits(cond), func(v generated.LinkVisit) int64 { return v.ID }, arg.CursorID, scanDesc, arg.Limit)
+	return slices.Clone(visits)
+}
+
+// переходы одной группы статистики: сырые переходы с хешами посетителей
+// и дневные агрегаты
+type memoryVisitGroup struct {
+	clicks      int64
+	visitors    map[string]bool
+	dailyUnique int64
+}
+
+// уникальные посетители: различные хеши сырых переходов плюс сумма
+// уникальных посетителей дневных агрегатов
+func (g *memoryVisitGroup) uniqueClicks() int64 {
+	return int64(len(g.visitors)) + g.dailyUnique
+}
+
+// начало суток агрегата для сравнения с границами периода
+func dailyStart(day pgtype.Date) time.Time {
+	return time.Date(day.Time.Year(), day.Time.Month(), day.Time.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// в периоде [start, end); пустая граница не ограничивает
+func inPeriod(t time.Time, start pgtype.Timestamptz, end pgtype.Timestamptz) bool {
+	return (!start.Valid || !t.Before(start.Time)) && (!end.Valid || t.Before(end.Time))
+}
+
+// группировка сырых переходов и дневных агрегатов за период, как в запросах
+// статистики. Ключ группы задают visitKey и dailyKey, ok = false
+// исключает запись. Посетители сырых переходов различаются по ссылке и хешу
+func (s *memoryStore) groupVisits(includeBots bool, start pgtype.Timestamptz, end pgtype.Timestamptz, visitKey func(generated.LinkVisit) (string, bool), dailyKey func(generated.LinkVisitDaily) (string, bool)) map[string]*memoryVisitGroup {
+	groups := make(map[string]*memoryVisitGroup)
+	group := func(key string) *memoryVisitGroup {
+		g, ok := groups[key]
+		if !ok {
+			g = &memoryVisitGroup{visitors: make(map[string]bool)}
+			groups[key] = g
+		}
+		return g
+	}
+	for _, visit := range s.visits {
+		if (visit.IsBot && !includeBots) || !inPeriod(visit.CreatedAt.Time, start, end) {
+			continue
+		}
+		key, ok := visitKey(visit)
+		if !ok {
+			continue
+		}
+		g := group(key)
+		g.clicks++
+		if visit.VisitorHash.Valid {
+			g.visitors[strconv.FormatInt(visit.LinkID, 10)+":"+visit.VisitorHash.String] = true
+		}
+	}
+	for _, daily := range s.daily {
+		if (daily.IsBot && !includeBots) || !inPeriod(dailyStart(daily.Day), start, end) {
+			continue
+		}
+		key, ok := dailyKey(daily)
+		if !ok {
+			continue
+		}
+		g := group(key)
+		g.clicks += daily.Clicks
+		g.dailyUnique += daily.UniqueClicks
+	}
+	return groups
+}
+
+func (s *memoryStore) CountVisits(ctx context.Context, arg generated.CountVisitsParams) (generated.CountVisitsRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groups := s.groupVisits(arg.IncludeBots, arg.PeriodStart, arg.PeriodEnd,
+		func(v generated.LinkVisit) (string, bool) {
+			return "", !arg.LinkID.Valid || v.LinkID == arg.LinkID.Int64
+		},
+		func(d generated.LinkVisitDaily) (string, bool) {
+			return "", !arg.LinkID.Valid || d.LinkID == arg.LinkID.Int64
+		},
+	)
+	var row generated.CountVisitsRow
+	if g, ok := groups[""]; ok {
+		row.Clicks = g.clicks
+		row.UniqueClicks = g.uniqueClicks()
+	}
+	return row, nil
+}
+
+// значение измерения статистики; пустая строка вместо NULL
+func visitDimension(dimension string, browser, browserVersion, os, deviceType string, isBot bool) string {
+	switch dimension {
+	case "browser":
+		return browser
+	case "browser_version":
+		return browserVersion
+	case "os":
+		return os
+	case "device_type":
+		return deviceType
+	case "is_bot":
+		return strconv.FormatBool(isBot)
+	}
+	return ""
+}
+
+func (s *memoryStore) GroupVisits(ctx context.Context, arg generated.GroupVisitsParams) ([]generated.GroupVisitsRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groups := s.groupVisits(arg.IncludeBots, arg.PeriodStart, arg.PeriodEnd,
+		func(v generated.LinkVisit) (string, bool) {
+			value := visitDimension(arg.Dimension, v.Browser.String, v.BrowserVersion.String, v.Os.String, v.DeviceType.String, v.IsBot)
+			return value, !arg.LinkID.Valid || v.LinkID == arg.LinkID.Int64
+		},
+		func(d generated.LinkVisitDaily) (string, bool) {
+			value := visitDimension(arg.Dimension, d.Browser, d.BrowserVersion, d.Os, d.DeviceType, d.IsBot)
+			return value, !arg.LinkID.Valid || d.LinkID == arg.LinkID.Int64
+		},
+	)
+	rows := make([]generated.GroupVisitsRow, 0, len(groups))
+	for value, g := range groups {
+		rows = append(rows, generated.GroupVisitsRow{
+			Value:        value,
+			Clicks:       g.clicks,
+			UniqueClicks: g.uniqueClicks(),
+		})
+	}
+	// ORDER BY clicks DESC, value
+	slices.SortFunc(rows, func(a, b generated.GroupVisitsRow) int {
+		if order := cmp.Compare(b.Clicks, a.Clicks); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+	return rows, nil
+}
+
+// перенос переходов до cutoff в дневные агрегаты. Возвращает число
+// перенесённых переходов
+func (s *memoryStore) RollupVisits(ctx context.Context, cutoff pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type dailyKey struct {
+		linkID                                  int64
+		day                                     string
+		browser, browserVersion, os, deviceType string
+		isBot                                   bool
+	}
+	rolled := make(map[dailyKey]*memoryVisitGroup)
+	var keys []dailyKey
+	var moved int64
+	s.visits = slices.DeleteFunc(s.visits, func(v generated.LinkVisit) bool {
+		if !v.CreatedAt.Time.Before(cutoff.Time) {
+			return false
+		}
+		key := dailyKey{
+			linkID:         v.LinkID,
+			day:            v.CreatedAt.Time.UTC().Format(time.DateOnly),
+			browser:        v.Browser.String,
+			browserVersion: v.BrowserVersion.String,
+			os:             v.Os.String,
+			deviceType:     v.DeviceType.String,
+			isBot:          v.IsBot,
+		}
+		g, ok := rolled[key]
+		if !ok {
+			g = &memoryVisitGroup{visitors: make(map[string]bool)}
+			rolled[key] = g
+			keys = append(keys, key)
+		}
+		g.clicks++
+		if v.VisitorHash.Valid {
+			g.visitors[v.VisitorHash.String] = true
+		}
+		moved++
+		return true
+	})
+	// ON CONFLICT DO UPDATE: суммы добавляются к существующему агрегату
+	for _, key := range keys {
+		g := rolled[key]
+		day, _ := time.Parse(time.DateOnly, key.day)
+		row := generated.LinkVisitDaily{
+			LinkID:         key.linkID,
+			Day:            pgtype.Date{Time: day, Valid: true},
+			Browser:        key.browser,
+			BrowserVersion: key.browserVersion,
+			Os:             key.os,
+			DeviceType:     key.deviceType,
+			IsBot:          key.isBot,
+		}
+		i := slices.IndexFunc(s.daily, func(d generated.LinkVisitDaily) bool {
+			return d.LinkID == row.LinkID && d.Day.Time.Equal(row.Day.Time) && d.Browser == row.Browser &&
+				d.BrowserVersion == row.BrowserVersion && d.Os == row.Os && d.DeviceType == row.DeviceType && d.IsBot == row.IsBot
+		})
+		if i < 0 {
+			s.daily = append(s.daily, row)
+			i = len(s.daily) - 1
+		}
+		s.daily[i].Clicks += g.clicks
+		s.daily[i].UniqueClicks += int64(len(g.visitors))
+	}
+	return moved, nil
+}
+
+// проверка уникальности сигнатуры бота
+func (s *memoryStore) checkBotSignature(pattern string) error {
+	for _, bot := range s.bots {
+		if bot.Pattern == pattern {
+			return memoryUniqueViolation("bot_signatures_pattern_key")
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) CreateBotSignature(ctx context.Context, pattern string) (generated.BotSignature, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkBotSignature(pattern); err != nil {
+		return generated.BotSignature{}, err
+	}
+	s.lastBotID++
+	bot := &generated.BotSignature{
+		ID:        s.lastBotID,
+		Pattern:   pattern,
+		CreatedAt: s.timestamp(),
+	}
+	s.bots[bot.ID] = bot
+	return *bot, nil
+}
+
+func (s *memoryStore) DeleteBotSignature(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.bots[id]; !ok {
+		return 0, nil
+	}
+	delete(s.bots, id)
+	return 1, nil
+}
+
+func (s *memoryStore) ListBotSignatures(ctx context.Context) ([]generated.BotSignature, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows []generated.BotSignature
+	for _, bot := range s.bots {
+		rows = append(rows, *bot)
+	}
+	slices.SortFunc(rows, func(a, b generated.BotSignature) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return rows, nil
+}
+
+// ключ соли: дата в формате 2006-01-02, строки сравниваются как даты
+func saltDay(day pgtype.Date) string {
+	return day.Time.Format(time.DateOnly)
+}
+
+func (s *memoryStore) CreateVisitorSalt(ctx context.Context, arg generated.CreateVisitorSaltParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// ON CONFLICT (day) DO NOTHING
+	day := saltDay(arg.Day)
+	if _, ok := s.salts[day]; !ok {
+		s.salts[day] = slices.Clone(arg.Salt)
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteVisitorSaltsBefore(ctx context.Context, day pgtype.Date) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := saltDay(day)
+	for day := range s.salts {
+		if day < before {
+			delete(s.salts, day)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) GetVisitorSalt(ctx context.Context, day pgtype.Date) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	salt, ok := s.salts[saltDay(day)]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return slices.Clone(salt), nil
+}
+
+func (s *memoryStore) CreateAuditEntry(ctx context.Context, arg generated.CreateAuditEntryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = append(s.audit, generated.LinkAudit{
+		ID:        int64(len(s.audit) + 1),
+		LinkID:    arg.LinkID,
+		Action:    arg.Action,
+		Actor:     arg.Actor,
+		Ip:        arg.Ip,
+		Changes:   slices.Clone(arg.Changes),
+		CreatedAt: s.timestamp(),
+	})
+	return nil
+}
+
+// записи журнала, подходящие под фильтр, по возрастанию id
+func (s *memoryStore) filterAudit(linkID pgtype.Int8, actor pgtype.Text) []generated.LinkAudit {
+	var entries []generated.LinkAudit
+	for _, entry := range s.audit {
+		if linkID.Valid && entry.LinkID != linkID.Int64 {
+			continue
+		}
+		if actor.Valid && (!entry.Actor.Valid || entry.Actor.String != actor.String) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *memoryStore) CounterAuditEntries(ctx context.Context, arg generated.CounterAuditEntriesParams) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return int64(len(s.filterAudit(arg.LinkID, arg.Actor))), nil
+}
+
+func (s *memoryStore) ListAuditEntries(ctx context.Context, arg generated.ListAuditEntriesParams) ([]generated.LinkAudit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := s.filterAudit(arg.LinkID, arg.Actor)
+	slices.Reverse(entries)
+	return pageOf(entries, arg.Limit, arg.Offset), nil
+}
+
+func (s *memoryStore) CreateWebhook(ctx context.Context, arg generated.CreateWebhookParams) (generated.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastWebhookID++
+	webhook := &generated.Webhook{
+		ID:        s.lastWebhookID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    slices.Clone(arg.Events),
+		CreatedAt: s.timestamp(),
+	}
+	s.webhooks[webhook.ID] = webhook
+	return *webhook, nil
+}
+
+// удаление вебхука вместе с журналом доставки, как ON DELETE CASCADE
+func (s *memoryStore) DeleteWebhook(ctx context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.webhooks[id]; !ok {
+		return 0, nil
+	}
+	delete(s.webhooks, id)
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d generated.WebhookDelivery) bool { return d.WebhookID == id })
+	return 1, nil
+}
+
+func (s *memoryStore) ListWebhooks(ctx context.Context) ([]generated.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows []generated.Webhook
+	for _, webhook := range s.webhooks {
+		rows = append(rows, *webhook)
+	}
+	slices.SortFunc(rows, func(a, b generated.Webhook) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return rows, nil
+}
+
+// доставка события каждому вебхуку, подписанному на него
+func (s *memoryStore) EnqueueWebhookEvent(ctx context.Context, arg generated.EnqueueWebhookEventParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var enqueued int64
+	for _, id := range slices.Sorted(maps.Keys(s.webhooks)) {
+		webhook := s.webhooks[id]
+		if !slices.Contains(webhook.Events, arg.Event) {
+			continue
+		}
+		s.lastDeliveryID++
+		s.deliveries = append(s.deliveries, generated.WebhookDelivery{
+			ID:            s.lastDeliveryID,
+			WebhookID:     webhook.ID,
+			Event:         arg.Event,
+			Payload:       slices.Clone(arg.Payload),
+			Status:        deliveryPending,
+			NextAttemptAt: s.timestamp(),
+			CreatedAt:     s.timestamp(),
+		})
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// ожидающие доставки, время которых наступило, в порядке очереди;
+// до LeaseUntil их не забирает следующий разбор
+func (s *memoryStore) ClaimWebhookDeliveries(ctx context.Context, arg generated.ClaimWebhookDeliveriesParams) ([]generated.ClaimWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*generated.WebhookDelivery
+	for i := range s.deliveries {
+		delivery := &s.deliveries[i]
+		if delivery.Status == deliveryPending && !delivery.NextAttemptAt.Time.After(arg.Now.Time) {
+			due = append(due, delivery)
+		}
+	}
+	slices.SortFunc(due, func(a, b *generated.WebhookDelivery) int {
+		if order := a.NextAttemptAt.Time.Compare(b.NextAttemptAt.Time); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	var rows []generated.ClaimWebhookDeliveriesRow
+	for _, delivery := range pageOf(due, arg.Limit, 0) {
+		webhook := s.webhooks[delivery.WebhookID]
+		delivery.NextAttemptAt = arg.LeaseUntil
+		rows = append(rows, generated.ClaimWebhookDeliveriesRow{
+			ID:       delivery.ID,
+			Event:    delivery.Event,
+			Payload:  slices.Clone(delivery.Payload),
+			Attempts: delivery.Attempts,
+			Url:      webhook.Url,
+			Secret:   webhook.Secret,
+		})
+	}
+	return rows, nil
+}
+
+// индекс доставки по id или -1
+func (s *memoryStore) deliveryIndex(id int64) int {
+	return slices.IndexFunc(s.deliveries, func(d generated.WebhookDelivery) bool { return d.ID == id })
+}
+
+func (s *memoryStore) CompleteWebhookDelivery(ctx context.Context, arg generated.CompleteWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.deliveryIndex(arg.ID)
+	if i < 0 {
+		return nil
+	}
+	delivery := &s.deliveries[i]
+	delivery.Attempts++
+	delivery.Status = arg.Status
+	delivery.ResponseStatus = arg.ResponseStatus
+	delivery.LastError = arg.LastError
+	delivery.NextAttemptAt = arg.NextAttemptAt
+	delivery.DeliveredAt = pgtype.Timestamptz{}
+	if arg.Status == deliveryDelivered {
+		delivery.DeliveredAt = s.timestamp()
+	}
+	return nil
+}
+
+func (s *memoryStore) CounterWebhookDeliveries(ctx context.Context, webhookID int64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count int64
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) ListWebhookDeliveries(ctx context.Context, arg generated.ListWebhookDeliveriesParams) ([]generated.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows []generated.WebhookDelivery
+	for _, delivery := range slices.Backward(s.deliveries) {
+		if delivery.WebhookID == arg.WebhookID {
+			rows = append(rows, delivery)
+		}
+	}
+	return pageOf(rows, arg.Limit, arg.Offset), nil
+}
+
+// повторная отправка: доставка снова ожидает отправки с полным числом попыток
+func (s *memoryStore) RedeliverWebhookDelivery(ctx context.Context, arg generated.RedeliverWebhookDeliveryParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.deliveryIndex(arg.ID)
+	if i < 0 || s.deliveries[i].WebhookID != arg.WebhookID {
+		return 0, nil
+	}
+	s.deliveries[i].Status = deliveryPending
+	s.deliveries[i].Attempts = 0
+	s.deliveries[i].NextAttemptAt = s.timestamp()
+	return 1, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchLike(t *testing.T) {
+	tests := []struct {
+		value   string
+		pattern string
+		fold    bool
+		want    bool
+	}{
+		{"exmpl", "exmpl", false, true},
+		{"exmpl1", "exmpl", false, false},
+		{"exmpl1", "exmpl%", false, true},
+		{"https://example.com/long-url", "%LONG%", false, false},
+		{"https://example.com/long-url", "%LONG%", true, true},
+		{"exmpl1", "exmpl_", false, true},
+		{"exmpl", "exmpl_", false, false},
+		{"100%", `100\%`, false, true},
+		{"1000", `100\%`, false, false},
+		{"a_b", `a\_b`, false, true},
+		{"axb", `a\_b`, false, false},
+		{"", "%", false, true},
+		{"ссылка", "%ЫЛК%", true, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchLike(tt.value, tt.pattern, tt.fold), "%q LIKE %q", tt.value, tt.pattern)
+	}
+}
+
+func TestMemoryStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+
+	// отсутствующие записи — pgx.ErrNoRows, как у Postgres
+	_, err := store.GetLink(ctx, 1)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = store.LastLink(ctx)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = store.GetVisitorSalt(ctx, pgtype.Date{Time: time.Now(), Valid: true})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// нарушение уникальности имени и внешнего ключа папки
+	var params generated.CreateLinkParams
+	params.OriginalUrl = "https://example.com"
+	params.ShortName = pgtype.Text{String: "exmpl", Valid: true}
+	_, err = store.CreateLink(ctx, params)
+	require.NoError(t, err)
+	_, err = store.CreateLink(ctx, params)
+	assert.True(t, isUniqueViolation(err))
+	params.ShortName = pgtype.Text{String: "other", Valid: true}
+	params.FolderID = pgtype.Int8{Int64: 1, Valid: true}
+	_, err = store.CreateLink(ctx, params)
+	assert.True(t, isForeignKeyViolation(err))
+
+	// ограничения длины имени, как CHECK и VARCHAR(32)
+	params.FolderID = pgtype.Int8{}
+	for _, name := range []string{"ab", strings.Repeat("a", 33)} {
+		params.ShortName = pgtype.Text{String: name, Valid: true}
+		_, err = store.CreateLink(ctx, params)
+		_, ok := constraintViolation(err)
+		assert.True(t, ok, name)
+	}
+
+	// удалённая ссылка не находится и не удаляется повторно
+	deleted, err := store.SoftDeleteLink(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = store.GetLink(ctx, 1)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	deleted, err = store.SoftDeleteLink(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+}
+
+func TestMemoryStoreSortNulls(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	for _, name := range []string{"bbb", "", "aaa"} {
+		var params generated.CreateLinkParams
+		params.OriginalUrl = "https://example.com/" + name
+		params.ShortName = pgtype.Text{String: name, Valid: name != ""}
+		_, err := store.CreateLink(ctx, params)
+		require.NoError(t, err)
+	}
+	names := func(desc bool) []string {
+		var params generated.ListLinksParams
+		params.SortField = "short_name"
+		params.SortDesc = desc
+		params.Limit = 10
+		links, err := store.ListLinks(ctx, params)
+		require.NoError(t, err)
+		var result []string
+		for _, link := range links {
+			result = append(result, link.ShortName.String)
+		}
+		return result
+	}
+	// NULL после значений по возрастанию и перед ними по убыванию
+	assert.Equal(t, []string{"aaa", "bbb", ""}, names(false))
+	assert.Equal(t, []string{"", "bbb", "aaa"}, names(true))
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var params generated.CreateLinkParams
+			params.OriginalUrl = fmt.Sprintf("https://example.com/%d", i)
+			link, err := store.CreateLink(ctx, params)
+			assert.NoError(t, err)
+			var visitParams generated.CreateLinkVisitsParams
+			visitParams.LinkID = link.ID
+			_, err = store.CreateLinkVisits(ctx, visitParams)
+			assert.NoError(t, err)
+			_, err = store.CounterLinks(ctx, generated.CounterLinksParams{})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	links, err := store.CounterLinks(ctx, generated.CounterLinksParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), links)
+	visits, err := store.CounterVisits(ctx, generated.CounterVisitsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), visits)
+}
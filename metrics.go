@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// маршрут перенаправления, переходы по которому считаются отдельно
+const redirectRoute = "/r/:code"
+
+// границы корзин гистограммы длительности запросов в секундах
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// итоги перенаправления, ряды для которых есть с самого запуска
+var redirectResults = []string{"found", "not_found", "error"}
+
+// метрики сервиса в собственном реестре Prometheus
+type httpMetrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	redirects *prometheus.CounterVec
+}
+
+func newHTTPMetrics() *httpMetrics {
+	labels := []string{"method", "route", "status"}
+	m := &httpMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency.",
+			Buckets: latencyBuckets,
+		}, labels),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shortener_redirects_total",
+			Help: "Short link redirects by result.",
+		}, []string{"result"}),
+	}
+	for _, result := range redirectResults {
+		m.redirects.WithLabelValues(result)
+	}
+	m.registry.MustRegister(m.requests, m.latency, m.redirects)
+	return m
+}
+
+// учёт одного запроса
+func (m *httpMetrics) observe(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.latency.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+	if route == redirectRoute {
+		m.redirects.WithLabelValues(redirectResult(status)).Inc()
+	}
+}
+
+// итог перенаправления по коду ответа
+func redirectResult(status int) string {
+	switch {
+	case status == http.StatusFound:
+		return "found"
+	case status == http.StatusNotFound:
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+// промежуточный обработчик, считающий запросы. Метка route содержит
+// шаблон маршрута, а не путь, чтобы число рядов не росло с числом ссылок
+func (m *httpMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.observe(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// состояние пула соединений с БД, снимаемое при каждом опросе
+type poolCollector struct {
+	pool           *pgxpool.Pool
+	acquired       *prometheus.Desc
+	idle           *prometheus.Desc
+	total          *prometheus.Desc
+	max            *prometheus.Desc
+	acquires       *prometheus.Desc
+	emptyAcquires  *prometheus.Desc
+	acquireSeconds *prometheus.Desc
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	return &poolCollector{
+		pool:           pool,
+		acquired:       prometheus.NewDesc("pgxpool_acquired_conns", "Connections currently acquired from the pool.", nil, nil),
+		idle:           prometheus.NewDesc("pgxpool_idle_conns", "Idle connections in the pool.", nil, nil),
+		total:          prometheus.NewDesc("pgxpool_total_conns", "Total connections in the pool.", nil, nil),
+		max:            prometheus.NewDesc("pgxpool_max_conns", "Maximum size of the pool.", nil, nil),
+		acquires:       prometheus.NewDesc("pgxpool_acquire_total", "Successful connection acquires.", nil, nil),
+		emptyAcquires:  prometheus.NewDesc("pgxpool_empty_acquire_total", "Acquires that waited because the pool was empty.", nil, nil),
+		acquireSeconds: prometheus.NewDesc("pgxpool_acquire_wait_seconds_total", "Total time spent acquiring connections.", nil, nil),
+	}
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(p, ch)
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := p.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(p.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(p.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(p.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(p.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(p.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(p.acquireSeconds, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
+
+// отдача метрик в текстовом формате Prometheus. Пул, живой поток и буфер
+// событий вебхуков опрашиваются в момент запроса метрик
+func metricsHandler(m *httpMetrics, pool *pgxpool.Pool, hub *visitHub, events *webhookEvents) gin.HandlerFunc {
+	// у хранилища SQLite нет пула pgx
+	if pool != nil {
+		m.registry.MustRegister(newPoolCollector(pool))
+	}
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "shortener_visit_stream_subscribers",
+			Help: "Clients connected to the live visit stream.",
+		}, func() float64 {
+			return float64(hub.subscriberCount())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "shortener_webhook_event_buffer_depth",
+			Help: "Visit events waiting to be written to the webhook delivery queue.",
+		}, func() float64 {
+			return float64(len(events.events))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "shortener_webhook_event_buffer_capacity",
+			Help: "Capacity of the visit event buffer.",
+		}, func() float64 {
+			return float64(cap(events.events))
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "shortener_webhook_events_dropped_total",
+			Help: "Visit events dropped because the buffer was full.",
+		}, func() float64 {
+			return float64(events.dropped.Load())
+		}),
+	)
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
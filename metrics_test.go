@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newHTTPMetrics()
+	// очередь без обработчика, чтобы события оставались в буфере
+	events := &webhookEvents{events: make(chan webhookEvent, 2)}
+	router := gin.New()
+	router.Use(m.middleware())
+	router.GET(redirectRoute, func(c *gin.Context) {
+		if c.Param("code") == "missing" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Redirect(http.StatusFound, "https://example.com")
+	})
+	router.GET("/metrics", metricsHandler(m, nil, newVisitHub(), events))
+	for _, path := range []string{"/r/one", "/r/two", "/r/missing", "/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for range 3 {
+		events.publish(t.Context(), eventLinkVisited, map[string]any{})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	text := w.Body.String()
+	assert.Contains(t, text, `http_requests_total{method="GET",route="/r/:code",status="302"} 2`)
+	assert.Contains(t, text, `http_requests_total{method="GET",route="/r/:code",status="404"} 1`)
+	assert.Contains(t, text, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, text, `http_request_duration_seconds_bucket{method="GET",route="/r/:code",status="302",le="+Inf"} 2`)
+	assert.Contains(t, text, `http_request_duration_seconds_count{method="GET",route="/r/:code",status="302"} 2`)
+	assert.Contains(t, text, `shortener_redirects_total{result="found"} 2`)
+	assert.Contains(t, text, `shortener_redirects_total{result="not_found"} 1`)
+	assert.Contains(t, text, `shortener_redirects_total{result="error"} 0`)
+	assert.Contains(t, text, "shortener_visit_stream_subscribers 0")
+	assert.Contains(t, text, "shortener_webhook_event_buffer_depth 2")
+	assert.Contains(t, text, "shortener_webhook_event_buffer_capacity 2")
+	assert.Contains(t, text, "shortener_webhook_events_dropped_total 1")
+	assert.NotContains(t, text, "pgxpool_")
+}
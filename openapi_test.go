@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// параметры пути gin (:id) в записи OpenAPI ({id})
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := setupRouter()
+	registerRoutes(router, routeDeps{metrics: newHTTPMetrics()})
+	var registered []string
+	for _, route := range router.Routes() {
+		registered = append(registered, route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+	sort.Strings(registered)
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(openAPISpec, &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+	var documented []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	// маршрут без описания или описание удалённого маршрута
+	assert.Equal(t, registered, documented)
+}
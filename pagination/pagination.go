@@ -0,0 +1,131 @@
+// Package pagination разбирает диапазон записей списка из параметра range
+// в формате react-admin или из заголовка Range и формирует Content-Range ответа.
+package pagination
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxPageSize максимальное число записей на странице списка
+const MaxPageSize = 50
+
+// ошибки разбора диапазона
+var (
+	ErrFormat   = errors.New("the range must be specified by two numbers, example: [1,4]")
+	ErrNegative = errors.New("the range value must be positive")
+	ErrOrder    = errors.New("range values are specified incorrectly")
+	ErrTooLarge = errors.New("the range value must not exceed 2147483647")
+	ErrHeader   = errors.New("the Range header must be specified as unit=start-end, example: links=0-49")
+)
+
+// Page страница списка: сдвиг и число записей
+type Page struct {
+	Offset int
+	Limit  int
+	// диапазон передан заголовком Range, ответ 206 или 416
+	FromHeader bool
+}
+
+// FromRequest получение страницы из параметра range или заголовка Range
+// с единицей unit. Параметр range имеет приоритет, без диапазона
+// возвращается первая страница
+func FromRequest(r *http.Request, unit string) (Page, error) {
+	if raw, ok := r.URL.Query()["range"]; ok {
+		return ParseQuery(raw[0])
+	}
+	if header := r.Header.Get("Range"); header != "" {
+		page, ok, err := ParseHeader(header, unit)
+		if ok || err != nil {
+			return page, err
+		}
+	}
+	return Page{Limit: MaxPageSize}, nil
+}
+
+// ParseQuery разбор параметра range в формате [start,end],
+// обе границы включительно, как их передаёт react-admin
+func ParseQuery(raw string) (Page, error) {
+	var bounds []int
+	if err := json.Unmarshal([]byte(raw), &bounds); err != nil || len(bounds) != 2 {
+		return Page{}, ErrFormat
+	}
+	start, end := bounds[0], bounds[1]
+	// проверка на положительные значения
+	if start < 0 || end < 0 {
+		return Page{}, ErrNegative
+	}
+	// сдвиг и лимит передаются в запрос как int32
+	if start > math.MaxInt32 || end > math.MaxInt32 {
+		return Page{}, ErrTooLarge
+	}
+	if start > end {
+		return Page{}, ErrOrder
+	}
+	return Page{Offset: start, Limit: clampLimit(end - start + 1)}, nil
+}
+
+// ParseHeader разбор заголовка Range вида unit=start-end или unit=start-,
+// обе границы включительно. Второе значение false, если заголовок
+// задан в другой единице и должен быть проигнорирован
+func ParseHeader(header string, unit string) (Page, bool, error) {
+	prefix, spec, found := strings.Cut(strings.TrimSpace(header), "=")
+	if !found || strings.TrimSpace(prefix) != unit {
+		return Page{}, false, nil
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return Page{}, true, ErrHeader
+	}
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return Page{}, true, ErrHeader
+	}
+	if start > math.MaxInt32 {
+		return Page{}, true, ErrTooLarge
+	}
+	page := Page{Offset: start, Limit: MaxPageSize, FromHeader: true}
+	if last == "" {
+		return page, true, nil
+	}
+	end, err := strconv.Atoi(last)
+	if err != nil || end < 0 {
+		return Page{}, true, ErrHeader
+	}
+	if end > math.MaxInt32 {
+		return Page{}, true, ErrTooLarge
+	}
+	if start > end {
+		return Page{}, true, ErrOrder
+	}
+	page.Limit = clampLimit(end - start + 1)
+	return page, true, nil
+}
+
+// ограничение максимального числа записей на странице
+func clampLimit(limit int) int {
+	return min(limit, MaxPageSize)
+}
+
+// Result код ответа и значение Content-Range для count полученных записей
+// из total подходящих. Для запроса с заголовком Range возвращается 206,
+// а если диапазон начинается за последней записью — 416
+func (p Page) Result(unit string, count int, total int64) (int, string) {
+	if count == 0 {
+		status := http.StatusOK
+		if p.FromHeader && int64(p.Offset) >= total && p.Offset > 0 {
+			status = http.StatusRequestedRangeNotSatisfiable
+		}
+		return status, fmt.Sprintf("%s */%d", unit, total)
+	}
+	contentRange := fmt.Sprintf("%s %d-%d/%d", unit, p.Offset, p.Offset+count-1, total)
+	if p.FromHeader {
+		return http.StatusPartialContent, contentRange
+	}
+	return http.StatusOK, contentRange
+}
@@ -0,0 +1,109 @@
+package pagination
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	page, err := ParseQuery("[10,20]")
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Offset: 10, Limit: 11}, page)
+
+	// страница react-admin [(p-1)*n, p*n-1] содержит n записей
+	page, err = ParseQuery("[25,49]")
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Offset: 25, Limit: 25}, page)
+
+	page, err = ParseQuery("[3, 3]")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Limit)
+
+	page, err = ParseQuery("[0,500]")
+	assert.NoError(t, err)
+	assert.Equal(t, MaxPageSize, page.Limit)
+
+	for raw, want := range map[string]error{
+		"abc1x2":                  ErrFormat,
+		"[1]":                     ErrFormat,
+		"[1,2,3]":                 ErrFormat,
+		"[1.5,2]":                 ErrFormat,
+		"[-1,2]":                  ErrNegative,
+		"[5,2]":                   ErrOrder,
+		"[2147483648,2147483650]": ErrTooLarge,
+		"[0,2147483648]":          ErrTooLarge,
+	} {
+		_, err = ParseQuery(raw)
+		assert.Equal(t, want, err, raw)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	page, ok, err := ParseHeader("links=0-49", "links")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Offset: 0, Limit: 50, FromHeader: true}, page)
+
+	page, ok, err = ParseHeader("links=100-", "links")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Offset: 100, Limit: MaxPageSize, FromHeader: true}, page)
+
+	_, ok, err = ParseHeader("bytes=0-49", "links")
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	for _, header := range []string{"links=", "links=a-b", "links=-5", "links=9-1"} {
+		_, ok, err = ParseHeader(header, "links")
+		assert.True(t, ok, header)
+		assert.Error(t, err, header)
+	}
+
+	// сдвиг за пределами int32 не должен доходить до запроса
+	for _, header := range []string{"links=2147483648-", "links=0-2147483648"} {
+		_, ok, err = ParseHeader(header, "links")
+		assert.True(t, ok, header)
+		assert.Equal(t, ErrTooLarge, err, header)
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/links", nil)
+	page, err := FromRequest(r, "links")
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Limit: MaxPageSize}, page)
+
+	// параметр range важнее заголовка
+	r = httptest.NewRequest(http.MethodGet, "/api/links?range=[2,4]", nil)
+	r.Header.Set("Range", "links=10-19")
+	page, err = FromRequest(r, "links")
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Offset: 2, Limit: 3}, page)
+
+	r = httptest.NewRequest(http.MethodGet, "/api/links", nil)
+	r.Header.Set("Range", "links=10-19")
+	page, err = FromRequest(r, "links")
+	assert.NoError(t, err)
+	assert.Equal(t, Page{Offset: 10, Limit: 10, FromHeader: true}, page)
+}
+
+func TestResult(t *testing.T) {
+	status, contentRange := Page{Offset: 0, Limit: 50}.Result("links", 50, 120)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "links 0-49/120", contentRange)
+
+	status, contentRange = Page{Offset: 100, Limit: 50, FromHeader: true}.Result("links", 20, 120)
+	assert.Equal(t, http.StatusPartialContent, status)
+	assert.Equal(t, "links 100-119/120", contentRange)
+
+	status, contentRange = Page{Offset: 200, Limit: 50, FromHeader: true}.Result("links", 0, 120)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, status)
+	assert.Equal(t, "links */120", contentRange)
+
+	status, contentRange = Page{Offset: 0, Limit: 50, FromHeader: true}.Result("links", 0, 0)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "links */0", contentRange)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// длина сохраняемого префикса адреса в режиме конфиденциальности
+const (
+	privacyIPv4PrefixBits = 24
+	privacyIPv6PrefixBits = 48
+)
+
+// размер поля referer в таблице переходов
+const maxRefererLength = 500
+
+// настройки режима конфиденциальности
+type privacyConfig struct {
+	Enabled bool
+}
+
+// чтение режима конфиденциальности из переменной окружения PRIVACY_MODE
+func loadPrivacyConfig() privacyConfig {
+	value := os.Getenv("PRIVACY_MODE")
+	if value == "" {
+		return privacyConfig{}
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid PRIVACY_MODE value, privacy mode is disabled", "value", value)
+		return privacyConfig{}
+	}
+	return privacyConfig{Enabled: enabled}
+}
+
+// сведения о посетителе, которые разрешено сохранить
+type visitorFields struct {
+	IP        string
+	UserAgent string
+	Referer   string
+	// учитывать ли посетителя при подсчёте уникальных переходов
+	Track bool
+}
+
+// отбор сведений о посетителе с учётом режима конфиденциальности.
+// В режиме конфиденциальности IP усекается до подсети, referer до origin,
+// а при DNT или Sec-GPC персональные поля не сохраняются вовсе. User agent
+// и referer обрезаются до размера полей таблицы
+func (p privacyConfig) visitorFields(r *http.Request, ip string) visitorFields {
+	fields := visitorFields{
+		IP:        ip,
+		UserAgent: truncateString(r.UserAgent(), maxUserAgentLength),
+		Referer:   truncateString(r.Referer(), maxRefererLength),
+		Track:     true,
+	}
+	if !p.Enabled {
+		return fields
+	}
+	if optedOut(r) {
+		return visitorFields{}
+	}
+	fields.IP = anonymizeIP(ip)
+	fields.Referer = truncateString(refererOrigin(r.Referer()), maxRefererLength)
+	return fields
+}
+
+// посетитель отказался от отслеживания заголовками DNT или Sec-GPC
+func optedOut(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
+
+// усечение IPv4 до /24 и IPv6 до /48
+func anonymizeIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := privacyIPv6PrefixBits
+	if addr.Is4() {
+		bits = privacyIPv4PrefixBits
+	}
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
+
+// сокращение referer до схемы и хоста без пути и параметров
+func refererOrigin(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
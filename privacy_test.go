@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.168.10.17", "192.168.10.0"},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", "2001:db8:85a3::"},
+		{"::ffff:203.0.113.7", "203.0.113.0"},
+		{"", ""},
+		{"not-an-ip", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, anonymizeIP(tt.ip))
+		})
+	}
+}
+
+func TestRefererOrigin(t *testing.T) {
+	assert.Equal(t, "https://www.example.com", refererOrigin("https://www.example.com/path/page?utm_source=mail#top"))
+	assert.Equal(t, "http://localhost:5173", refererOrigin("http://localhost:5173/links"))
+	assert.Equal(t, "", refererOrigin("www.ya.ru"))
+	assert.Equal(t, "", refererOrigin(""))
+}
+
+func TestPrivacyVisitorFields(t *testing.T) {
+	r := httptest.NewRequest("GET", "/r/exmpl", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 Firefox/125.0")
+	r.Header.Set("Referer", "https://mail.example.com/inbox/42?token=secret")
+
+	// режим выключен: данные сохраняются как есть
+	off := privacyConfig{}.visitorFields(r, "203.0.113.7")
+	assert.Equal(t, visitorFields{IP: "203.0.113.7", UserAgent: "Mozilla/5.0 Firefox/125.0", Referer: "https://mail.example.com/inbox/42?token=secret", Track: true}, off)
+
+	// режим включён: IP и referer усекаются
+	on := privacyConfig{Enabled: true}.visitorFields(r, "203.0.113.7")
+	assert.Equal(t, visitorFields{IP: "203.0.113.0", UserAgent: "Mozilla/5.0 Firefox/125.0", Referer: "https://mail.example.com", Track: true}, on)
+
+	// DNT и Sec-GPC отключают сохранение персональных полей
+	for _, header := range []string{"DNT", "Sec-GPC"} {
+		optOut := httptest.NewRequest("GET", "/r/exmpl", nil)
+		optOut.Header.Set("User-Agent", "Mozilla/5.0 Firefox/125.0")
+		optOut.Header.Set(header, "1")
+		assert.Equal(t, visitorFields{}, privacyConfig{Enabled: true}.visitorFields(optOut, "203.0.113.7"))
+	}
+
+	// длинный referer обрезается до размера поля
+	long := httptest.NewRequest("GET", "/r/exmpl", nil)
+	long.Header.Set("Referer", "https://example.com/"+strings.Repeat("a", 1000))
+	off = privacyConfig{}.visitorFields(long, "203.0.113.7")
+	assert.Equal(t, maxRefererLength, len(off.Referer))
+	assert.True(t, strings.HasPrefix(off.Referer, "https://example.com/aaa"))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// тип ответа с ошибкой по RFC 7807
+	problemContentType = "application/problem+json"
+	// начало URI типа ошибки, к нему добавляется код
+	problemTypeBase = "https://go-project-278-yoao.onrender.com/problems/"
+)
+
+// стабильные коды ошибок. Клиенты различают ошибки по коду,
+// текст detail может меняться
+const (
+	// тело запроса не разобрано
+	codeInvalidRequest = "invalid_request"
+	// неверный параметр пути, строки запроса или заголовка
+	codeInvalidParameter = "invalid_parameter"
+	// поля тела запроса не прошли проверку
+	codeValidationFailed = "validation_failed"
+	// маршрут не существует
+	codeRouteNotFound = "route_not_found"
+	// запись не найдена
+	codeLinkNotFound            = "link_not_found"
+	codeTagNotFound             = "tag_not_found"
+	codeFolderNotFound          = "folder_not_found"
+	codeBotSignatureNotFound    = "bot_signature_not_found"
+	codeDestinationNotFound     = "destination_version_not_found"
+	codeWebhookNotFound         = "webhook_not_found"
+	codeWebhookDeliveryNotFound = "webhook_delivery_not_found"
+	// ссылка указывает на несуществующую папку
+	codeUnknownFolder = "unknown_folder"
+	// значение уже занято
+	codeShortNameTaken      = "short_name_taken"
+	codeTagExists           = "tag_exists"
+	codeFolderExists        = "folder_exists"
+	codeBotSignatureExists  = "bot_signature_exists"
+	codeRangeNotSatisfiable = "range_not_satisfiable"
+	// внутренняя ошибка, подробности только в журнале
+	codeInternal = "internal_error"
+)
+
+// ответ с ошибкой по RFC 7807 с кодом ошибки и идентификатором запроса
+type problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	// занятое короткое имя и свободные варианты для short_name_taken
+	ShortName   string   `json:"short_name,omitempty"`
+	Suggestions []string `json:"suggestions,omitzero"`
+}
+
+func newProblem(c *gin.Context, status int, code, detail string) problem {
+	return problem{
+		Type:      problemTypeBase + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: c.GetString(requestIDKey),
+	}
+}
+
+// запись ответа с ошибкой. Заголовок выставляется до c.JSON,
+// тогда gin не заменяет его на application/json
+func renderProblem(c *gin.Context, p problem) {
+	c.Header("Content-Type", problemContentType)
+	c.JSON(p.Status, p)
+}
+
+// ответ с ошибкой. Идентификатор запроса в ответе позволяет найти
+// подробности в журнале
+func writeProblem(c *gin.Context, status int, code, detail string) {
+	renderProblem(c, newProblem(c, status, code, detail))
+}
+
+// ошибки проверки полей: поле -> нарушенное правило
+func writeValidationProblem(c *gin.Context, fields map[string]string) {
+	p := newProblem(c, http.StatusUnprocessableEntity, codeValidationFailed, "request validation failed")
+	p.Errors = fields
+	renderProblem(c, p)
+}
+
+// коды ошибок Postgres, которые вызваны данными клиента
+const (
+	pgCheckViolation = "23514"
+	pgStringTooLong  = "22001"
+)
+
+// нарушение ограничения CHECK или длины поля: значение от клиента
+// не подходит таблице. Поле берётся из имени ограничения вида
+// links_short_name_check, если оно известно
+func constraintViolation(err error) (map[string]string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || (pgErr.Code != pgCheckViolation && pgErr.Code != pgStringTooLong) {
+		return nil, false
+	}
+	field, ok := strings.CutPrefix(pgErr.ConstraintName, pgErr.TableName+"_")
+	field, found := strings.CutSuffix(field, "_check")
+	if pgErr.TableName == "" || !ok || !found {
+		return nil, true
+	}
+	return map[string]string{field: "check"}, true
+}
+
+// ошибка шага транзакции с описанием для ответа клиенту
+type stepError struct {
+	detail string
+	err    error
+}
+
+func (e *stepError) Error() string {
+	return e.detail + ": " + e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// ошибка шага транзакции, nil без ошибки. dbError отвечает
+// описанием шага вместо общего описания
+func failedStep(detail string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &stepError{detail: detail, err: err}
+}
+
+// ошибка БД: подробности пишутся в журнал и Sentry,
+// клиент получает 500 и только detail. Нарушение ограничений
+// таблицы — ошибка данных клиента, ответ 422 без отправки в Sentry
+func dbError(c *gin.Context, detail string, err error) {
+	var step *stepError
+	if errors.As(err, &step) {
+		detail = step.detail
+	}
+	if fields, ok := constraintViolation(err); ok {
+		p := newProblem(c, http.StatusUnprocessableEntity, codeValidationFailed, "value violates a table constraint")
+		p.Errors = fields
+		renderProblem(c, p)
+		return
+	}
+	slog.ErrorContext(c.Request.Context(), "database error",
+		"error", err,
+		"method", c.Request.Method,
+		"route", c.FullPath(),
+	)
+	_ = c.Error(err)
+	if hub := sentrygin.GetHubFromContext(c); hub != nil {
+		hub.CaptureException(err)
+	}
+	writeProblem(c, http.StatusInternalServerError, codeInternal, detail)
+}
+
+// ошибка поиска ссылки: 404, если её нет, иначе ошибка БД
+func linkLookupError(c *gin.Context, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found")
+		return
+	}
+	dbError(c, "unable to get link", err)
+}
+
+// ответ для несуществующего маршрута
+func routeNotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeProblem(c, http.StatusNotFound, codeRouteNotFound, "route not found")
+	}
+}
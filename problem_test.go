@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// проверка ответа с ошибкой в формате RFC 7807
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code, detail string) problem {
+	t.Helper()
+	assert.Equal(t, status, w.Code)
+	assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+	var body problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, problemTypeBase+code, body.Type)
+	assert.Equal(t, http.StatusText(status), body.Title)
+	assert.Equal(t, status, body.Status)
+	assert.Equal(t, code, body.Code)
+	assert.Equal(t, detail, body.Detail)
+	assert.Equal(t, w.Header().Get(requestIDHeader), body.RequestID)
+	return body
+}
+
+func newProblemRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestIDMiddleware())
+	router.NoRoute(routeNotFound())
+	return router
+}
+
+func TestWriteProblem(t *testing.T) {
+	router := newProblemRouter()
+	router.GET("/api/links/:id", func(c *gin.Context) {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/links/abc", nil))
+	body := assertProblem(t, w, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+	assert.Equal(t, "/api/links/abc", body.Instance)
+	assert.NotEmpty(t, body.RequestID)
+	assert.Nil(t, body.Errors)
+}
+
+func TestWriteValidationProblem(t *testing.T) {
+	router := newProblemRouter()
+	router.POST("/api/links", func(c *gin.Context) {
+		writeValidationProblem(c, map[string]string{"OriginalUrl": "required"})
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/links", nil))
+	body := assertProblem(t, w, http.StatusUnprocessableEntity, codeValidationFailed, "request validation failed")
+	assert.Equal(t, map[string]string{"OriginalUrl": "required"}, body.Errors)
+}
+
+func TestLinkLookupError(t *testing.T) {
+	router := newProblemRouter()
+	router.GET("/missing", func(c *gin.Context) {
+		linkLookupError(c, pgx.ErrNoRows)
+	})
+	router.GET("/broken", func(c *gin.Context) {
+		linkLookupError(c, errors.New(`relation "links" does not exist`))
+	})
+
+	// записи нет
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assertProblem(t, w, http.StatusNotFound, codeLinkNotFound, "link not found")
+
+	// текст ошибки БД не попадает в ответ
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	assertProblem(t, w, http.StatusInternalServerError, codeInternal, "unable to get link")
+	assert.NotContains(t, w.Body.String(), "relation")
+}
+
+func TestDBErrorConstraintViolation(t *testing.T) {
+	router := newProblemRouter()
+	router.POST("/check", func(c *gin.Context) {
+		dbError(c, "unable to create link", &pgconn.PgError{Code: pgCheckViolation, TableName: "links", ConstraintName: "links_short_name_check"})
+	})
+	router.POST("/too-long", func(c *gin.Context) {
+		dbError(c, "unable to create link", &pgconn.PgError{Code: pgStringTooLong})
+	})
+
+	// нарушение CHECK: поле из имени ограничения
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/check", nil))
+	body := assertProblem(t, w, http.StatusUnprocessableEntity, codeValidationFailed, "value violates a table constraint")
+	assert.Equal(t, map[string]string{"short_name": "check"}, body.Errors)
+
+	// слишком длинное значение: поле неизвестно
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/too-long", nil))
+	body = assertProblem(t, w, http.StatusUnprocessableEntity, codeValidationFailed, "value violates a table constraint")
+	assert.Nil(t, body.Errors)
+}
+
+func TestRouteNotFound(t *testing.T) {
+	w := httptest.NewRecorder()
+	newProblemRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/unknown", nil))
+	assertProblem(t, w, http.StatusNotFound, codeRouteNotFound, "route not found")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// периодичность запуска свёртки переходов
+	retentionInterval = 24 * time.Hour
+	// имя задачи в /readyz
+	visitRetentionWorker = "visit_retention"
+)
+
+// срок хранения сырых переходов в днях из переменной VISIT_RETENTION_DAYS,
+// 0 отключает свёртку
+func loadRetentionDays() int {
+	value := os.Getenv("VISIT_RETENTION_DAYS")
+	if value == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		slog.Warn("invalid VISIT_RETENTION_DAYS value, visit retention is disabled", "value", value)
+		return 0
+	}
+	return days
+}
+
+// граница свёртки: начало суток (UTC) days дней назад.
+// Сутки не делятся между сырыми данными и агрегатами, поэтому
+// unique_clicks агрегата — точное число посетителей за его сутки.
+// Статистика складывает их по дням в unique_clicks, как и для
+// сырых переходов: хеши одного посетителя в разные дни не совпадают
+func retentionCutoff(now time.Time, days int) time.Time {
+	return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+}
+
+// свёртка переходов старше срока хранения в дневные агрегаты
+// и удаление сырых записей; хранилище делает это атомарно
+func rollupVisits(ctx context.Context, db Store, days int, now time.Time) (int64, error) {
+	cutoff := pgtype.Timestamptz{Time: retentionCutoff(now, days), Valid: true}
+	return db.RollupVisits(ctx, cutoff)
+}
+
+// запуск ежедневной свёртки переходов в фоне
+func startVisitRetention(ctx context.Context, db Store, days int, workers *workerRegistry) {
+	if days <= 0 {
+		workers.disabled(visitRetentionWorker)
+		return
+	}
+	runPeriodically(ctx, workers, visitRetentionWorker, retentionInterval, func(now time.Time) error {
+		moved, err := rollupVisits(ctx, db, days, now)
+		if err != nil {
+			slog.Error("visit retention failed", "error", err)
+		} else if moved > 0 {
+			slog.Info("visit retention: visits rolled up", "visits", moved)
+		}
+		return err
+	})
+}
+
+// запуск задачи в фоне сразу и далее с периодом interval до отмены контекста.
+// Итог каждого запуска сохраняется в workers под именем name
+func runPeriodically(ctx context.Context, workers *workerRegistry, name string, interval time.Duration, job func(now time.Time) error) {
+	workers.started(name, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			now := time.Now()
+			workers.finished(name, now, job(now))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
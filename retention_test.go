@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 42, 0, 0, time.FixedZone("MSK", 3*60*60))
+	assert.Equal(t, time.Date(2026, 9, 18, 0, 0, 0, 0, time.UTC), retentionCutoff(now, 30))
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), retentionCutoff(now, 0))
+}
+
+func TestLoadRetentionDays(t *testing.T) {
+	t.Setenv("VISIT_RETENTION_DAYS", "90")
+	assert.Equal(t, 90, loadRetentionDays())
+	t.Setenv("VISIT_RETENTION_DAYS", "-1")
+	assert.Equal(t, 0, loadRetentionDays())
+	t.Setenv("VISIT_RETENTION_DAYS", "")
+	assert.Equal(t, 0, loadRetentionDays())
+}
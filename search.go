@@ -0,0 +1,196 @@
+package main
+
+import (
+	"cmp"
+	generated "code/db/generated"
+	"code/pagination"
+	"html"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// подсвеченные совпадения в полях найденной ссылки
+type linkHighlights struct {
+	OriginalUrl string `json:"original_url,omitempty"`
+	ShortName   string `json:"short_name,omitempty"`
+	Title       string `json:"title,omitempty"`
+}
+
+// найденная ссылка с релевантностью и подсветкой
+type linkSearchResult struct {
+	ID          int64          `json:"id"`
+	OriginalUrl string         `json:"original_url"`
+	ShortName   pgtype.Text    `json:"short_name"`
+	ShortUrl    pgtype.Text    `json:"short_url"`
+	Title       pgtype.Text    `json:"title"`
+	Rank        float32        `json:"rank"`
+	Highlights  linkHighlights `json:"highlights"`
+}
+
+// полнотекстовый и нечёткий поиск ссылок с сортировкой по релевантности
+func searchLinks(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "the search query q cannot be empty")
+			return
+		}
+		page, err := pagination.FromRequest(c.Request, "links")
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		var searchParams generated.SearchLinksParams
+		searchParams.Search = query
+		searchParams.SearchPattern = likeEscaper.Replace(query)
+		searchParams.Limit = int32(page.Limit)
+		searchParams.Offset = int32(page.Offset)
+		rows, err := db.SearchLinks(c.Request.Context(), searchParams)
+		if err != nil {
+			dbError(c, "unable to search links", err)
+			return
+		}
+		terms := searchTerms(query)
+		results := make([]linkSearchResult, 0, len(rows))
+		var total int64
+		for _, row := range rows {
+			total = row.Total
+			results = append(results, linkSearchResult{
+				ID:          row.ID,
+				OriginalUrl: row.OriginalUrl,
+				ShortName:   row.ShortName,
+				ShortUrl:    row.ShortUrl,
+				Title:       row.Title,
+				Rank:        row.Rank,
+				Highlights: linkHighlights{
+					OriginalUrl: highlightMatches(row.OriginalUrl, terms),
+					ShortName:   highlightMatches(row.ShortName.String, terms),
+					Title:       highlightMatches(row.Title.String, terms),
+				},
+			})
+		}
+		writePage(c, page, "links", results, total)
+	}
+}
+
+// слова запроса для подсветки без операторов websearch_to_tsquery:
+// исключённые слова (-слово) и OR не подсвечиваются
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") || strings.EqualFold(word, "or") {
+			continue
+		}
+		parts := strings.FieldsFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		terms = append(terms, parts...)
+	}
+	return terms
+}
+
+// выделение вхождений слов без учёта регистра тегом <mark>,
+// остальной текст экранируется. Пустая строка, если совпадений нет
+func highlightMatches(text string, terms []string) string {
+	marked := make([]bool, len(text))
+	found := false
+	for _, term := range terms {
+		for i := 0; i+len(term) <= len(text); i++ {
+			if !utf8.RuneStart(text[i]) || !strings.EqualFold(text[i:i+len(term)], term) {
+				continue
+			}
+			for j := i; j < i+len(term); j++ {
+				marked[j] = true
+			}
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(text[i:j])
+		if marked[i] {
+			segment = "<mark>" + segment + "</mark>"
+		}
+		b.WriteString(segment)
+		i = j
+	}
+	return b.String()
+}
+
+// релевантность и порядок результатов поиска для хранилищ без Postgres:
+// rows — все найденные ссылки. Ранг приближает ts_rank + similarity:
+// доля слов запроса, найденных в ссылке, с весом 0.1 плюс наибольшее
+// сходство триграмм запроса с адресом, коротким именем или названием.
+// Возвращается страница по убыванию ранга, total — число найденных ссылок
+func rankSearchResults(rows []generated.SearchLinksRow, search string, limit int32, offset int32) []generated.SearchLinksRow {
+	terms := searchWords(search)
+	for i := range rows {
+		row := &rows[i]
+		rank := 0.0
+		if len(terms) > 0 {
+			words := searchWords(row.Title.String + " " + row.ShortName.String + " " + row.OriginalUrl)
+			matched := 0
+			for _, term := range terms {
+				if slices.Contains(words, term) {
+					matched++
+				}
+			}
+			rank = 0.1 * float64(matched) / float64(len(terms))
+		}
+		rank += max(
+			trigramSimilarity(row.OriginalUrl, search),
+			trigramSimilarity(row.ShortName.String, search),
+			trigramSimilarity(row.Title.String, search),
+		)
+		row.Rank = float32(rank)
+		row.Total = int64(len(rows))
+	}
+	slices.SortStableFunc(rows, func(a, b generated.SearchLinksRow) int {
+		if order := cmp.Compare(b.Rank, a.Rank); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return pageOf(rows, limit, offset)
+}
+
+// сходство строк, как similarity из pg_trgm: доля общих триграмм слов
+func trigramSimilarity(a string, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for trigram := range ta {
+		if tb[trigram] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// триграммы слов текста; слово дополняется двумя пробелами в начале
+// и одним в конце, как в pg_trgm
+func trigrams(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range searchWords(text) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"example", "com", "report"}, searchTerms("example.com report"))
+	assert.Equal(t, []string{"docs", "draft"}, searchTerms("docs OR draft -old"))
+	assert.Empty(t, searchTerms("  "))
+}
+
+func TestHighlightMatches(t *testing.T) {
+	assert.Equal(t, "Quarterly <mark>Report</mark>", highlightMatches("Quarterly Report", []string{"report"}))
+	assert.Equal(t, "<mark>exmpl</mark>1", highlightMatches("exmpl1", []string{"xmp", "exmpl"}))
+	assert.Equal(t, "a &lt;b&gt; <mark>Отчёт</mark>", highlightMatches("a <b> Отчёт", []string{"отчёт"}))
+	assert.Equal(t, "", highlightMatches("example", []string{"report"}))
+	assert.Equal(t, "", highlightMatches("", []string{"report"}))
+}
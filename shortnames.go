@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxskiss/base62"
+)
+
+const (
+	// допустимая длина короткого имени, как в ограничении таблицы links
+	minShortNameLength = 3
+	maxShortNameLength = 32
+	// сколько свободных имён предлагать вместо занятого
+	shortNameSuggestions = 3
+	// сколько вариантов проверять, прежде чем вернуть найденные
+	shortNameSuggestionAttempts = 20
+	// сколько сгенерированных имён пробовать, если предыдущее занято
+	generatedShortNameAttempts = 5
+)
+
+// имя ссылки из её ожидаемого ID в Base62, не короче minShortNameLength
+func generateShortName(id int64) string {
+	name := base62.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+	// если длина сгенерированного имени меньше 3
+	if len(name) < minShortNameLength {
+		name = name + name + name
+	}
+	return name
+}
+
+// ответ проверки короткого имени
+type shortNameAvailability struct {
+	ShortName string `json:"short_name"`
+	Available bool   `json:"available"`
+	// свободные имена на замену занятому; пустой список, если имя свободно
+	Suggestions []string `json:"suggestions"`
+}
+
+// имя занято, если есть ссылка с ним, в том числе в корзине
+func shortNameTaken(ctx context.Context, db Store, name string) (bool, error) {
+	_, err := db.GetLinkFromCode(ctx, pgtype.Text{String: name, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// свободные имена вида name1, name2 ...; имя укорачивается,
+// чтобы вместе с номером уложиться в maxShortNameLength
+func suggestShortNames(ctx context.Context, db Store, name string) ([]string, error) {
+	suggestions := make([]string, 0, shortNameSuggestions)
+	for i := 1; i <= shortNameSuggestionAttempts && len(suggestions) < shortNameSuggestions; i++ {
+		suffix := strconv.Itoa(i)
+		base := []rune(name)
+		if len(base)+len(suffix) > maxShortNameLength {
+			base = base[:maxShortNameLength-len(suffix)]
+		}
+		candidate := string(base) + suffix
+		taken, err := shortNameTaken(ctx, db, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if !taken {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions, nil
+}
+
+// ответ 409 на занятое имя со свободными вариантами
+func writeShortNameTaken(c *gin.Context, db Store, name string) {
+	suggestions, err := suggestShortNames(c.Request.Context(), db, name)
+	if err != nil {
+		dbError(c, "unable to suggest short names", err)
+		return
+	}
+	p := newProblem(c, http.StatusConflict, codeShortNameTaken, fmt.Sprintf("short name %q is already taken", name))
+	p.ShortName = name
+	p.Suggestions = suggestions
+	renderProblem(c, p)
+}
+
+// проверка, свободно ли короткое имя, для подсказок при вводе
+func checkShortName(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if n := utf8.RuneCountInString(name); n < minShortNameLength || n > maxShortNameLength {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, fmt.Sprintf("short name must be %d to %d characters long", minShortNameLength, maxShortNameLength))
+			return
+		}
+		taken, err := shortNameTaken(c.Request.Context(), db, name)
+		if err != nil {
+			dbError(c, "unable to check short name", err)
+			return
+		}
+		availability := shortNameAvailability{ShortName: name, Available: !taken, Suggestions: []string{}}
+		if taken {
+			availability.Suggestions, err = suggestShortNames(c.Request.Context(), db, name)
+			if err != nil {
+				dbError(c, "unable to suggest short names", err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, availability)
+	}
+}
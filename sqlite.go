@@ -0,0 +1,1502 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	// драйвер SQLite на чистом Go, регистрируется под именем sqliteDriver
+	_ "modernc.org/sqlite"
+)
+
+// миграции хранилища SQLite; применяются при открытии базы
+//
+//go:embed db/sqlite/migrations/*.sql
+var sqliteMigrationFiles embed.FS
+
+const (
+	// каталог миграций SQLite в sqliteMigrationFiles
+	sqliteMigrationsDir = "db/sqlite/migrations"
+	// имя драйвера database/sql
+	sqliteDriver = "sqlite"
+	// формат времени в SQLite: UTC с микросекундами, как в Postgres.
+	// Значения по умолчанию в схеме пишут миллисекунды, а время сравнивается
+	// как строка, поэтому все вставки передают время из Go
+	sqliteTimeLayout = "2006-01-02T15:04:05.000000Z"
+)
+
+// путь к файлу базы из DATABASE_URL вида sqlite:///var/lib/app/links.db
+// или sqlite://links.db; ok = false для остальных схем
+func sqlitePath(dsn string) (string, bool) {
+	rest, ok := strings.CutPrefix(dsn, "sqlite://")
+	if !ok || rest == "" {
+		return "", false
+	}
+	return rest, true
+}
+
+// хранилище в файле SQLite для запуска на одном узле. Запросы повторяют
+// sqlc-запросы к Postgres, кроме полнотекстового поиска: фильтр q требует
+// вхождения всех слов запроса, как в memoryStore, а релевантность считает
+// rankSearchResults. ILIKE заменён на LIKE, который в SQLite не различает
+// регистр только латинских букв
+type sqliteStore struct {
+	db  *sql.DB
+	now func() time.Time
+	// открытая транзакция InTx и глубина вложенных точек сохранения
+	tx    *sql.Tx
+	depth int
+}
+
+// соединение для запросов: транзакция InTx или пул
+func (s *sqliteStore) conn() interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+func (s *sqliteStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	return s.inTx(ctx, func(tx *sqliteStore) error { return fn(tx) })
+}
+
+// транзакция на верхнем уровне и точка сохранения внутри InTx
+func (s *sqliteStore) inTx(ctx context.Context, fn func(tx *sqliteStore) error) error {
+	if s.tx == nil {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := fn(&sqliteStore{db: s.db, now: s.now, tx: tx}); err != nil {
+			return err
+		}
+		return sqliteError(tx.Commit())
+	}
+	savepoint := fmt.Sprintf("sp%d", s.depth+1)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+	if err := fn(&sqliteStore{db: s.db, now: s.now, tx: s.tx, depth: s.depth + 1}); err != nil {
+		// ROLLBACK TO оставляет точку сохранения, RELEASE снимает её
+		for _, statement := range []string{"ROLLBACK TO ", "RELEASE "} {
+			if _, rollbackErr := s.tx.ExecContext(ctx, statement+savepoint); rollbackErr != nil {
+				return errors.Join(err, rollbackErr)
+			}
+		}
+		return err
+	}
+	_, err := s.tx.ExecContext(ctx, "RELEASE "+savepoint)
+	return sqliteError(err)
+}
+
+var (
+	_ Store       = (*sqliteStore)(nil)
+	_ readinessDB = (*sqliteStore)(nil)
+)
+
+// открытие базы с внешними ключами и журналом WAL и применение миграций
+func openSQLiteStore(ctx context.Context, path string) (*sqliteStore, error) {
+	query := url.Values{"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}}
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// SQLite допускает одного писателя; одно соединение исключает
+	// ошибки "database is locked" в транзакциях
+	db.SetMaxOpenConns(1)
+	store := &sqliteStore{db: db, now: time.Now}
+	if err := store.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// текущая версия схемы по таблице goose_db_version
+func (s *sqliteStore) MigrationVersion(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var records []migrationRecord
+	for rows.Next() {
+		var record migrationRecord
+		if err := rows.Scan(&record.VersionID, &record.IsApplied); err != nil {
+			return 0, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return appliedMigrationVersion(records), nil
+}
+
+// применение встроенных миграций. Версии записываются в goose_db_version
+// в формате goose, поэтому базу можно обслуживать и утилитой goose
+func (s *sqliteStore) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	version_id INTEGER NOT NULL,
+	is_applied INTEGER NOT NULL,
+	tstamp TIMESTAMP DEFAULT (datetime('now'))
+)`)
+	if err != nil {
+		return err
+	}
+	// goose начинает таблицу с нулевой версии
+	_, err = s.db.ExecContext(ctx, "INSERT INTO goose_db_version (version_id, is_applied) SELECT 0, 1 WHERE NOT EXISTS (SELECT 1 FROM goose_db_version)")
+	if err != nil {
+		return err
+	}
+	current, err := s.MigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+	names, err := fs.Glob(sqliteMigrationFiles, path.Join(sqliteMigrationsDir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	// имена начинаются с версии одной длины, порядок имён — порядок версий
+	slices.Sort(names)
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+		script, err := sqliteMigrationFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := s.applyMigration(ctx, version, gooseUp(string(script))); err != nil {
+			return fmt.Errorf("migration %s: %w", path.Base(name), err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) applyMigration(ctx context.Context, version int64, script string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, 1)", version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// часть миграции goose между -- +goose Up и -- +goose Down
+func gooseUp(script string) string {
+	_, up, ok := strings.Cut(script, "-- +goose Up")
+	if !ok {
+		return ""
+	}
+	up, _, _ = strings.Cut(up, "-- +goose Down")
+	return up
+}
+
+// ошибки SQLite в том виде, в каком их возвращает Postgres. Драйверы
+// различаются типами ошибок, но не текстом сообщений
+func sqliteError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return pgx.ErrNoRows
+	case strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		return &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: sqliteUniqueConstraint(err.Error()), Message: err.Error()}
+	case strings.Contains(err.Error(), "FOREIGN KEY constraint failed"):
+		return &pgconn.PgError{Code: pgForeignKeyViolation, Message: err.Error()}
+	case strings.Contains(err.Error(), "CHECK constraint failed"):
+		return &pgconn.PgError{Code: pgCheckViolation, Message: err.Error()}
+	}
+	return err
+}
+
+// имя ограничения UNIQUE по правилам Postgres: для "UNIQUE constraint
+// failed: links.short_name" это links_short_name_key
+func sqliteUniqueConstraint(message string) string {
+	_, columns, ok := strings.Cut(message, "UNIQUE constraint failed: ")
+	if !ok {
+		return ""
+	}
+	columns, _, _ = strings.Cut(columns, " (")
+	var table string
+	var names []string
+	for _, column := range strings.Split(columns, ", ") {
+		table, column, _ = strings.Cut(column, ".")
+		names = append(names, column)
+	}
+	return table + "_" + strings.Join(names, "_") + "_key"
+}
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// параметр времени: NULL или текст в формате sqliteTimeLayout
+func sqliteTimestamptz(ts pgtype.Timestamptz) any {
+	if !ts.Valid {
+		return nil
+	}
+	return sqliteTime(ts.Time)
+}
+
+// чтение времени из текстовой колонки
+type sqliteTimestamp struct {
+	dst *pgtype.Timestamptz
+}
+
+func (t sqliteTimestamp) Scan(src any) error {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		*t.dst = pgtype.Timestamptz{}
+		return nil
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	case time.Time:
+		*t.dst = pgtype.Timestamptz{Time: src, Valid: true}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", src)
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return err
+	}
+	*t.dst = pgtype.Timestamptz{Time: parsed, Valid: true}
+	return nil
+}
+
+// чтение JSON-массива строк: меток ссылки или событий вебхука;
+// у ссылки без меток пустой список
+type sqliteStrings struct {
+	dst *[]string
+}
+
+func (t sqliteStrings) Scan(src any) error {
+	var text []byte
+	switch src := src.(type) {
+	case string:
+		text = []byte(src)
+	case []byte:
+		text = src
+	default:
+		return fmt.Errorf("cannot scan %T into string array", src)
+	}
+	tags := []string{}
+	if err := json.Unmarshal(text, &tags); err != nil {
+		return err
+	}
+	*t.dst = tags
+	return nil
+}
+
+// параметр JSON-массива для json_each; nil — NULL, как пустой массив в sqlc.narg
+func sqliteJSONArray[T any](items []T) (any, error) {
+	if items == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// слова для поиска через пробел с пробелами по краям, см. links.search_words
+func sqliteSearchWords(title pgtype.Text, shortName pgtype.Text, originalURL string) string {
+	words := searchWords(title.String + " " + shortName.String + " " + originalURL)
+	if len(words) == 0 {
+		return ""
+	}
+	return " " + strings.Join(words, " ") + " "
+}
+
+// префикс короткого имени без экранирования LIKE: в SQLite LIKE не различает
+// регистр, поэтому префикс сравнивается через instr
+func sqliteLikeUnescape(pattern pgtype.Text) any {
+	if !pattern.Valid {
+		return nil
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern.String {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// условия отбора ссылок, параметры ?1–?10: префикс имени, домен, период,
+// id, поисковый запрос, слова запроса, шаблон поиска, метка и папка
+const sqliteLinkFilter = `deleted_at IS NULL
+AND (?1 IS NULL OR instr(short_name, ?1) = 1)
+AND (?2 IS NULL OR host LIKE '%' || ?2 || '%' ESCAPE '\')
+AND (?3 IS NULL OR created_at >= ?3)
+AND (?4 IS NULL OR created_at <= ?4)
+AND (?5 IS NULL OR id IN (SELECT value FROM json_each(?5)))
+AND (?6 IS NULL
+	OR (json_array_length(?7) > 0 AND NOT EXISTS (
+		SELECT 1 FROM json_each(?7) w WHERE instr(search_words, ' ' || w.value || ' ') = 0))
+	OR original_url LIKE '%' || ?8 || '%' ESCAPE '\'
+	OR short_name LIKE '%' || ?8 || '%' ESCAPE '\'
+	OR title LIKE '%' || ?8 || '%' ESCAPE '\')
+AND (?9 IS NULL OR EXISTS (
+	SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.link_id = links.id AND t.name = ?9))
+AND (?10 IS NULL OR folder_id = ?10)`
+
+// колонки ссылки со списком меток
+const sqliteLinkColumns = `id, original_url, short_name, short_url, title, folder_id,
+	(SELECT json_group_array(t.name ORDER BY t.name) FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = links.id) AS tags`
+
+// колонки таблицы links в порядке generated.Link
+const sqliteLinkRow = `id, original_url, short_name, short_url, created_at, title, deleted_at, destination_version, folder_id`
+
+// параметры ?1–?10 для sqliteLinkFilter
+func sqliteLinkArgs(cond linkConditions) ([]any, error) {
+	ids, err := sqliteJSONArray(cond.Ids)
+	if err != nil {
+		return nil, err
+	}
+	terms, err := sqliteJSONArray(searchWords(cond.Search.String))
+	if err != nil {
+		return nil, err
+	}
+	if terms == nil {
+		terms = "[]"
+	}
+	return []any{
+		sqliteLikeUnescape(cond.ShortNamePrefix),
+		cond.Domain,
+		sqliteTimestamptz(cond.CreatedFrom),
+		sqliteTimestamptz(cond.CreatedTo),
+		ids,
+		cond.Search,
+		terms,
+		cond.SearchPattern,
+		cond.Tag,
+		cond.FolderID,
+	}, nil
+}
+
+func scanSQLiteLink(row interface{ Scan(...any) error }) (generated.Link, error) {
+	var link generated.Link
+	err := row.Scan(
+		&link.ID,
+		&link.OriginalUrl,
+		&link.ShortName,
+		&link.ShortUrl,
+		sqliteTimestamp{&link.CreatedAt},
+		&link.Title,
+		sqliteTimestamp{&link.DeletedAt},
+		&link.DestinationVersion,
+		&link.FolderID,
+	)
+	return link, sqliteError(err)
+}
+
+func (s *sqliteStore) CounterLinks(ctx context.Context, arg generated.CounterLinksParams) (int64, error) {
+	args, err := sqliteLinkArgs(linkConditions{
+		ShortNamePrefix: arg.ShortNamePrefix,
+		Domain:          arg.Domain,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		Ids:             arg.Ids,
+		Search:          arg.Search,
+		SearchPattern:   arg.SearchPattern,
+		Tag:             arg.Tag,
+		FolderID:        arg.FolderID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM links WHERE "+sqliteLinkFilter, args...).Scan(&count)
+	return count, sqliteError(err)
+}
+
+func (s *sqliteStore) CreateLink(ctx context.Context, arg generated.CreateLinkParams) (generated.Link, error) {
+	row := s.conn().QueryRowContext(ctx, `INSERT INTO links (
+original_url, short_name, title, folder_id, created_at, search_words
+) VALUES (
+?, ?, ?, ?, ?, ?
+)
+RETURNING `+sqliteLinkRow,
+		arg.OriginalUrl,
+		arg.ShortName,
+		arg.Title,
+		arg.FolderID,
+		sqliteTime(s.now()),
+		sqliteSearchWords(arg.Title, arg.ShortName, arg.OriginalUrl),
+	)
+	return scanSQLiteLink(row)
+}
+
+func (s *sqliteStore) GetLink(ctx context.Context, id int64) (generated.GetLinkRow, error) {
+	var link generated.GetLinkRow
+	err := s.conn().QueryRowContext(ctx, "SELECT "+sqliteLinkColumns+" FROM links WHERE id = ? AND deleted_at IS NULL LIMIT 1", id).Scan(
+		&link.ID,
+		&link.OriginalUrl,
+		&link.ShortName,
+		&link.ShortUrl,
+		&link.Title,
+		&link.FolderID,
+		sqliteStrings{&link.Tags},
+	)
+	return link, sqliteError(err)
+}
+
+// FOR UPDATE не нужен: у хранилища одно соединение, и транзакции
+// выполняются по очереди
+func (s *sqliteStore) GetLinkForUpdate(ctx context.Context, id int64) (generated.GetLinkForUpdateRow, error) {
+	link, err := s.GetLink(ctx, id)
+	return generated.GetLinkForUpdateRow(link), err
+}
+
+func (s *sqliteStore) GetLinkFromCode(ctx context.Context, shortName pgtype.Text) (generated.GetLinkFromCodeRow, error) {
+	var link generated.GetLinkFromCodeRow
+	err := s.conn().QueryRowContext(ctx, "SELECT id, original_url, deleted_at, destination_version FROM links WHERE short_name = ?", shortName).Scan(
+		&link.ID,
+		&link.OriginalUrl,
+		sqliteTimestamp{&link.DeletedAt},
+		&link.DestinationVersion,
+	)
+	return link, sqliteError(err)
+}
+
+func (s *sqliteStore) LastLink(ctx context.Context) (generated.Link, error) {
+	return scanSQLiteLink(s.conn().QueryRowContext(ctx, "SELECT "+sqliteLinkRow+" FROM links ORDER BY id DESC LIMIT 1"))
+}
+
+func (s *sqliteStore) ListLinks(ctx context.Context, arg generated.ListLinksParams) ([]generated.ListLinksRow, error) {
+	args, err := sqliteLinkArgs(linkConditions{
+		ShortNamePrefix: arg.ShortNamePrefix,
+		Domain:          arg.Domain,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		Ids:             arg.Ids,
+		Search:          arg.Search,
+		SearchPattern:   arg.SearchPattern,
+		Tag:             arg.Tag,
+		FolderID:        arg.FolderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// NULL после значений по возрастанию и перед ними по убыванию, как в Postgres
+	rows, err := s.conn().QueryContext(ctx, "SELECT "+sqliteLinkColumns+" FROM links WHERE "+sqliteLinkFilter+`
+ORDER BY
+	CASE WHEN ?11 = 'original_url' AND NOT ?12 THEN original_url END ASC,
+	CASE WHEN ?11 = 'original_url' AND ?12 THEN original_url END DESC,
+	CASE WHEN ?11 = 'short_name' AND NOT ?12 THEN short_name END ASC NULLS LAST,
+	CASE WHEN ?11 = 'short_name' AND ?12 THEN short_name END DESC NULLS FIRST,
+	CASE WHEN ?11 = 'created_at' AND NOT ?12 THEN created_at END ASC,
+	CASE WHEN ?11 = 'created_at' AND ?12 THEN created_at END DESC,
+	CASE WHEN ?12 THEN id END DESC,
+	id
+LIMIT ?13 OFFSET ?14`, append(args, arg.SortField, arg.SortDesc, arg.Limit, arg.Offset)...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.ListLinksRow
+	for rows.Next() {
+		var i generated.ListLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalUrl,
+			&i.ShortName,
+			&i.ShortUrl,
+			&i.Title,
+			&i.FolderID,
+			sqliteStrings{&i.Tags},
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) ListLinksByCursorAsc(ctx context.Context, arg generated.ListLinksByCursorAscParams) ([]generated.ListLinksByCursorAscRow, error) {
+	return s.listLinksByCursor(ctx, arg, "AND id > ?11 ORDER BY id")
+}
+
+func (s *sqliteStore) ListLinksByCursorDesc(ctx context.Context, arg generated.ListLinksByCursorDescParams) ([]generated.ListLinksByCursorDescRow, error) {
+	items, err := s.listLinksByCursor(ctx, generated.ListLinksByCursorAscParams(arg), "AND id < ?11 ORDER BY id DESC")
+	var rows []generated.ListLinksByCursorDescRow
+	for _, item := range items {
+		rows = append(rows, generated.ListLinksByCursorDescRow(item))
+	}
+	return rows, err
+}
+
+// у запросов по курсору в обе стороны одинаковые параметры и строки,
+// отличаются только условие на id и порядок
+func (s *sqliteStore) listLinksByCursor(ctx context.Context, arg generated.ListLinksByCursorAscParams, keyset string) ([]generated.ListLinksByCursorAscRow, error) {
+	args, err := sqliteLinkArgs(linkConditions{
+		ShortNamePrefix: arg.ShortNamePrefix,
+		Domain:          arg.Domain,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		Ids:             arg.Ids,
+		Search:          arg.Search,
+		SearchPattern:   arg.SearchPattern,
+		Tag:             arg.Tag,
+		FolderID:        arg.FolderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.conn().QueryContext(ctx, "SELECT "+sqliteLinkColumns+" FROM links WHERE "+sqliteLinkFilter+"\n"+keyset+" LIMIT ?12", append(args, arg.CursorID, arg.Limit)...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.ListLinksByCursorAscRow
+	for rows.Next() {
+		var i generated.ListLinksByCursorAscRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalUrl,
+			&i.ShortName,
+			&i.ShortUrl,
+			&i.Title,
+			&i.FolderID,
+			sqliteStrings{&i.Tags},
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) SearchLinks(ctx context.Context, arg generated.SearchLinksParams) ([]generated.SearchLinksRow, error) {
+	args, err := sqliteLinkArgs(linkConditions{
+		Search:        pgtype.Text{String: arg.Search, Valid: true},
+		SearchPattern: pgtype.Text{String: arg.SearchPattern, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.conn().QueryContext(ctx, "SELECT id, original_url, short_name, short_url, title FROM links WHERE "+sqliteLinkFilter, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.SearchLinksRow
+	for rows.Next() {
+		var i generated.SearchLinksRow
+		if err := rows.Scan(&i.ID, &i.OriginalUrl, &i.ShortName, &i.ShortUrl, &i.Title); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, sqliteError(err)
+	}
+	return rankSearchResults(items, arg.Search, arg.Limit, arg.Offset), nil
+}
+
+func (s *sqliteStore) SoftDeleteLink(ctx context.Context, id int64) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "UPDATE links SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", sqliteTime(s.now()), id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) RestoreLink(ctx context.Context, id int64) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "UPDATE links SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) CounterDeletedLinks(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM links WHERE deleted_at IS NOT NULL").Scan(&count)
+	return count, sqliteError(err)
+}
+
+func (s *sqliteStore) ListDeletedLinks(ctx context.Context, arg generated.ListDeletedLinksParams) ([]generated.ListDeletedLinksRow, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT id, original_url, short_name, short_url, title, deleted_at
+FROM links
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT ? OFFSET ?`, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.ListDeletedLinksRow
+	for rows.Next() {
+		var i generated.ListDeletedLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OriginalUrl,
+			&i.ShortName,
+			&i.ShortUrl,
+			&i.Title,
+			sqliteTimestamp{&i.DeletedAt},
+		); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+// переходы, агрегаты и история адресов удаляются внешними ключами
+func (s *sqliteStore) PurgeDeletedLinks(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "DELETE FROM links WHERE deleted_at IS NOT NULL AND deleted_at < ?", sqliteTimestamptz(deletedAt))
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) UpdateLink(ctx context.Context, arg generated.UpdateLinkParams) error {
+	_, err := s.conn().ExecContext(ctx, `UPDATE links
+SET original_url = ?2, short_name = ?3, title = ?4, folder_id = ?5, search_words = ?6
+WHERE id = ?1`,
+		arg.ID,
+		arg.OriginalUrl,
+		arg.ShortName,
+		arg.Title,
+		arg.FolderID,
+		sqliteSearchWords(arg.Title, arg.ShortName, arg.OriginalUrl),
+	)
+	return sqliteError(err)
+}
+
+func (s *sqliteStore) UpdateShortName(ctx context.Context, arg generated.UpdateShortNameParams) error {
+	_, err := s.conn().ExecContext(ctx, "UPDATE links SET short_url = ? WHERE id = ?", arg.ShortUrl, arg.ID)
+	return sqliteError(err)
+}
+
+func (s *sqliteStore) CreateFolder(ctx context.Context, name string) (generated.Folder, error) {
+	var folder generated.Folder
+	err := s.conn().QueryRowContext(ctx, "INSERT INTO folders (name, created_at) VALUES (?, ?) RETURNING id, name, created_at", name, sqliteTime(s.now())).
+		Scan(&folder.ID, &folder.Name, sqliteTimestamp{&folder.CreatedAt})
+	return folder, sqliteError(err)
+}
+
+func (s *sqliteStore) DeleteFolder(ctx context.Context, id int64) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "DELETE FROM folders WHERE id = ?", id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) ListFolders(ctx context.Context) ([]generated.ListFoldersRow, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT f.id, f.name, f.created_at,
+	(SELECT COUNT(*) FROM links l WHERE l.folder_id = f.id AND l.deleted_at IS NULL) AS links
+FROM folders f
+ORDER BY f.name`)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.ListFoldersRow
+	for rows.Next() {
+		var i generated.ListFoldersRow
+		if err := rows.Scan(&i.ID, &i.Name, sqliteTimestamp{&i.CreatedAt}, &i.Links); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) UpdateFolder(ctx context.Context, arg generated.UpdateFolderParams) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "UPDATE folders SET name = ? WHERE id = ?", arg.Name, arg.ID)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) AddLinkDestination(ctx context.Context, arg generated.AddLinkDestinationParams) (int32, error) {
+	var version int32
+	err := s.inTx(ctx, func(tx *sqliteStore) error {
+		err := tx.tx.QueryRowContext(ctx, `INSERT INTO link_destinations (link_id, version, original_url, created_at)
+SELECT ?1, COALESCE(MAX(version), 0) + 1, ?2, ?3
+FROM link_destinations
+WHERE link_id = ?1
+RETURNING version`, arg.LinkID, arg.OriginalUrl, sqliteTime(s.now())).Scan(&version)
+		if err != nil {
+			return sqliteError(err)
+		}
+		_, err = tx.tx.ExecContext(ctx, "UPDATE links SET destination_version = ? WHERE id = ?", version, arg.LinkID)
+		return sqliteError(err)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *sqliteStore) ListLinkDestinations(ctx context.Context, linkID int64) ([]generated.ListLinkDestinationsRow, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT d.version, d.original_url, d.created_at,
+	d.version = l.destination_version AS active,
+	(SELECT COUNT(*) FROM link_visits v WHERE v.link_id = d.link_id AND v.destination_version = d.version) AS clicks
+FROM link_destinations d
+JOIN links l ON l.id = d.link_id
+WHERE d.link_id = ?
+ORDER BY d.version DESC`, linkID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.ListLinkDestinationsRow
+	for rows.Next() {
+		var i generated.ListLinkDestinationsRow
+		if err := rows.Scan(
+			&i.Version,
+			&i.OriginalUrl,
+			sqliteTimestamp{&i.CreatedAt},
+			&i.Active,
+			&i.Clicks,
+		); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+// в отличие от Postgres, search_words не вычисляется базой и
+// пересчитывается по новому адресу
+func (s *sqliteStore) RollbackLinkDestination(ctx context.Context, arg generated.RollbackLinkDestinationParams) (int64, error) {
+	var updated int64
+	err := s.inTx(ctx, func(tx *sqliteStore) error {
+		var (
+			originalURL string
+			title       pgtype.Text
+			shortName   pgtype.Text
+		)
+		err := tx.tx.QueryRowContext(ctx, `SELECT d.original_url, l.title, l.short_name
+FROM links l
+JOIN link_destinations d ON d.link_id = l.id
+WHERE l.id = ? AND l.deleted_at IS NULL AND d.version = ?`, arg.LinkID, arg.Version).Scan(&originalURL, &title, &shortName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return sqliteError(err)
+		}
+		result, err := tx.tx.ExecContext(ctx, "UPDATE links SET original_url = ?, destination_version = ?, search_words = ? WHERE id = ?",
+			originalURL, arg.Version, sqliteSearchWords(title, shortName, originalURL), arg.LinkID)
+		if err != nil {
+			return sqliteError(err)
+		}
+		updated, err = result.RowsAffected()
+		return err
+	})
+	return updated, err
+}
+
+func (s *sqliteStore) SetLinkTags(ctx context.Context, arg generated.SetLinkTagsParams) error {
+	names, err := sqliteJSONArray(arg.Names)
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		names = "[]"
+	}
+	// WHERE true отделяет SELECT от ON CONFLICT при разборе
+	statements := []string{
+		`INSERT INTO tags (name, created_at)
+SELECT DISTINCT value, ?3 FROM json_each(?1) WHERE true
+ON CONFLICT (name) DO NOTHING`,
+		`DELETE FROM link_tags
+WHERE link_id = ?2
+AND tag_id NOT IN (SELECT id FROM tags WHERE name IN (SELECT value FROM json_each(?1)))`,
+		`INSERT INTO link_tags (link_id, tag_id)
+SELECT ?2, id FROM tags WHERE name IN (SELECT value FROM json_each(?1))
+ON CONFLICT DO NOTHING`,
+	}
+	return s.inTx(ctx, func(tx *sqliteStore) error {
+		for _, statement := range statements {
+			if _, err := tx.tx.ExecContext(ctx, statement, names, arg.LinkID, sqliteTime(s.now())); err != nil {
+				return sqliteError(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) CreateTag(ctx context.Context, name string) (generated.Tag, error) {
+	var tag generated.Tag
+	err := s.conn().QueryRowContext(ctx, "INSERT INTO tags (name, created_at) VALUES (?, ?) RETURNING id, name, created_at", name, sqliteTime(s.now())).
+		Scan(&tag.ID, &tag.Name, sqliteTimestamp{&tag.CreatedAt})
+	return tag, sqliteError(err)
+}
+
+func (s *sqliteStore) DeleteTag(ctx context.Context, id int64) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) ListTags(ctx context.Context) ([]generated.ListTagsRow, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT t.id, t.name, t.created_at,
+	(SELECT COUNT(*) FROM link_tags lt JOIN links l ON l.id = lt.link_id WHERE lt.tag_id = t.id AND l.deleted_at IS NULL) AS links
+FROM tags t
+ORDER BY t.name`)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.ListTagsRow
+	for rows.Next() {
+		var i generated.ListTagsRow
+		if err := rows.Scan(&i.ID, &i.Name, sqliteTimestamp{&i.CreatedAt}, &i.Links); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) TagStats(ctx context.Context, arg generated.TagStatsParams) ([]generated.TagStatsRow, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT t.id, t.name,
+	COUNT(DISTINCT lt.link_id) AS links,
+	COALESCE(SUM(v.clicks), 0) AS clicks,
+	COALESCE(SUM(v.unique_clicks), 0) AS unique_clicks
+FROM tags t
+LEFT JOIN link_tags lt ON lt.tag_id = t.id
+LEFT JOIN (
+	SELECT link_id,
+		COUNT(*) AS clicks,
+		COUNT(DISTINCT visitor_hash) AS unique_clicks
+	FROM link_visits
+	WHERE `+sqliteStatsVisitFilter+`
+	GROUP BY link_id
+	UNION ALL
+	SELECT link_id,
+		SUM(clicks) AS clicks,
+		SUM(unique_clicks) AS unique_clicks
+	FROM link_visit_daily
+	WHERE `+sqliteStatsDailyFilter+`
+	GROUP BY link_id
+) AS v ON v.link_id = lt.link_id
+GROUP BY t.id, t.name
+ORDER BY clicks DESC, t.name`, sqliteStatsArgs("", pgtype.Int8{}, arg.IncludeBots, arg.PeriodStart, arg.PeriodEnd)...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.TagStatsRow
+	for rows.Next() {
+		var i generated.TagStatsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Links,
+			&i.Clicks,
+			&i.UniqueClicks,
+		); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) UpdateTag(ctx context.Context, arg generated.UpdateTagParams) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "UPDATE tags SET name = ? WHERE id = ?", arg.Name, arg.ID)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+// условия отбора переходов, параметры ?1–?6: ссылка, период, статус,
+// шаблоны referer и User-Agent
+const sqliteVisitFilter = `(?1 IS NULL OR link_id = ?1)
+AND (?2 IS NULL OR created_at >= ?2)
+AND (?3 IS NULL OR created_at <= ?3)
+AND (?4 IS NULL OR status = ?4)
+AND (?5 IS NULL OR referer LIKE '%' || ?5 || '%' ESCAPE '\')
+AND (?6 IS NULL OR user_agent LIKE '%' || ?6 || '%' ESCAPE '\')`
+
+// колонки таблицы link_visits в порядке generated.LinkVisit
+const sqliteVisitColumns = `id, link_id, ip, user_agent, referer, status, created_at, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version`
+
+func sqliteVisitArgs(cond visitConditions) []any {
+	return []any{
+		cond.LinkID,
+		sqliteTimestamptz(cond.CreatedFrom),
+		sqliteTimestamptz(cond.CreatedTo),
+		cond.Status,
+		cond.Referer,
+		cond.UserAgent,
+	}
+}
+
+func scanSQLiteVisit(row interface{ Scan(...any) error }) (generated.LinkVisit, error) {
+	var visit generated.LinkVisit
+	err := row.Scan(
+		&visit.ID,
+		&visit.LinkID,
+		&visit.Ip,
+		&visit.UserAgent,
+		&visit.Referer,
+		&visit.Status,
+		sqliteTimestamp{&visit.CreatedAt},
+		&visit.Browser,
+		&visit.BrowserVersion,
+		&visit.Os,
+		&visit.DeviceType,
+		&visit.IsBot,
+		&visit.VisitorHash,
+		&visit.DestinationVersion,
+	)
+	return visit, sqliteError(err)
+}
+
+func (s *sqliteStore) queryVisits(ctx context.Context, query string, args ...any) ([]generated.LinkVisit, error) {
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.LinkVisit
+	for rows.Next() {
+		visit, err := scanSQLiteVisit(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, visit)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) CounterVisits(ctx context.Context, arg generated.CounterVisitsParams) (int64, error) {
+	args := sqliteVisitArgs(visitConditions{
+		LinkID:      arg.LinkID,
+		CreatedFrom: arg.CreatedFrom,
+		CreatedTo:   arg.CreatedTo,
+		Status:      arg.Status,
+		Referer:     arg.Referer,
+		UserAgent:   arg.UserAgent,
+	})
+	var count int64
+	err := s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM link_visits WHERE "+sqliteVisitFilter, args...).Scan(&count)
+	return count, sqliteError(err)
+}
+
+func (s *sqliteStore) CreateLinkVisits(ctx context.Context, arg generated.CreateLinkVisitsParams) (generated.LinkVisit, error) {
+	row := s.conn().QueryRowContext(ctx, `INSERT INTO link_visits (
+link_id, ip, user_agent, referer, status, browser, browser_version, os, device_type, is_bot, visitor_hash, destination_version, created_at
+) VALUES (
+?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING `+sqliteVisitColumns,
+		arg.LinkID,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Referer,
+		arg.Status,
+		arg.Browser,
+		arg.BrowserVersion,
+		arg.Os,
+		arg.DeviceType,
+		arg.IsBot,
+		arg.VisitorHash,
+		arg.DestinationVersion,
+		sqliteTime(s.now()),
+	)
+	return scanSQLiteVisit(row)
+}
+
+func (s *sqliteStore) ListLinkVisits(ctx context.Context, arg generated.ListLinkVisitsParams) ([]generated.LinkVisit, error) {
+	args := sqliteVisitArgs(visitConditions{
+		LinkID:      arg.LinkID,
+		CreatedFrom: arg.CreatedFrom,
+		CreatedTo:   arg.CreatedTo,
+		Status:      arg.Status,
+		Referer:     arg.Referer,
+		UserAgent:   arg.UserAgent,
+	})
+	return s.queryVisits(ctx, "SELECT "+sqliteVisitColumns+" FROM link_visits WHERE "+sqliteVisitFilter+`
+ORDER BY
+	CASE WHEN ?7 = 'created_at' AND NOT ?8 THEN created_at END ASC,
+	CASE WHEN ?7 = 'created_at' AND ?8 THEN created_at END DESC,
+	CASE WHEN ?8 THEN id END DESC,
+	id
+LIMIT ?9 OFFSET ?10`, append(args, arg.SortField, arg.SortDesc, arg.Limit, arg.Offset)...)
+}
+
+func (s *sqliteStore) ListLinkVisitsByCursorAsc(ctx context.Context, arg generated.ListLinkVisitsByCursorAscParams) ([]generated.LinkVisit, error) {
+	return s.listLinkVisitsByCursor(ctx, arg, "AND id > ?7 ORDER BY id")
+}
+
+func (s *sqliteStore) ListLinkVisitsByCursorDesc(ctx context.Context, arg generated.ListLinkVisitsByCursorDescParams) ([]generated.LinkVisit, error) {
+	return s.listLinkVisitsByCursor(ctx, generated.ListLinkVisitsByCursorAscParams(arg), "AND id < ?7 ORDER BY id DESC")
+}
+
+func (s *sqliteStore) listLinkVisitsByCursor(ctx context.Context, arg generated.ListLinkVisitsByCursorAscParams, keyset string) ([]generated.LinkVisit, error) {
+	args := sqliteVisitArgs(visitConditions{
+		LinkID:      arg.LinkID,
+		CreatedFrom: arg.CreatedFrom,
+		CreatedTo:   arg.CreatedTo,
+		Status:      arg.Status,
+		Referer:     arg.Referer,
+		UserAgent:   arg.UserAgent,
+	})
+	return s.queryVisits(ctx, "SELECT "+sqliteVisitColumns+" FROM link_visits WHERE "+sqliteVisitFilter+"\n"+keyset+" LIMIT ?8", append(args, arg.CursorID, arg.Limit)...)
+}
+
+// условия отбора переходов и дневных агрегатов для статистики,
+// параметры ?2–?5: ссылка, учёт ботов и период; ?1 — измерение GroupVisits.
+// Сутки агрегата сравниваются с границами по своему началу в UTC
+const (
+	sqliteStatsVisitFilter = `(?2 IS NULL OR link_id = ?2)
+	AND (?3 OR NOT is_bot)
+	AND (?4 IS NULL OR created_at >= ?4)
+	AND (?5 IS NULL OR created_at < ?5)`
+	sqliteStatsDailyFilter = `(?2 IS NULL OR link_id = ?2)
+	AND (?3 OR NOT is_bot)
+	AND (?4 IS NULL OR day || 'T00:00:00.000000Z' >= ?4)
+	AND (?5 IS NULL OR day || 'T00:00:00.000000Z' < ?5)`
+)
+
+// параметры ?1–?5 запросов статистики
+func sqliteStatsArgs(dimension string, linkID pgtype.Int8, includeBots bool, start pgtype.Timestamptz, end pgtype.Timestamptz) []any {
+	return []any{dimension, linkID, includeBots, sqliteTimestamptz(start), sqliteTimestamptz(end)}
+}
+
+func (s *sqliteStore) CountVisits(ctx context.Context, arg generated.CountVisitsParams) (generated.CountVisitsRow, error) {
+	var totals generated.CountVisitsRow
+	err := s.conn().QueryRowContext(ctx, `SELECT COALESCE(SUM(clicks), 0) AS clicks,
+	COALESCE(SUM(unique_clicks), 0) AS unique_clicks
+FROM (
+	SELECT COUNT(*) AS clicks,
+		COUNT(DISTINCT link_id || ':' || visitor_hash) AS unique_clicks
+	FROM link_visits
+	WHERE `+sqliteStatsVisitFilter+`
+	UNION ALL
+	SELECT SUM(clicks) AS clicks,
+		SUM(unique_clicks) AS unique_clicks
+	FROM link_visit_daily
+	WHERE `+sqliteStatsDailyFilter+`
+) AS totals`, sqliteStatsArgs("", arg.LinkID, arg.IncludeBots, arg.PeriodStart, arg.PeriodEnd)...).
+		Scan(&totals.Clicks, &totals.UniqueClicks)
+	return totals, sqliteError(err)
+}
+
+func (s *sqliteStore) GroupVisits(ctx context.Context, arg generated.GroupVisitsParams) ([]generated.GroupVisitsRow, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT value,
+	SUM(clicks) AS clicks,
+	SUM(unique_clicks) AS unique_clicks
+FROM (
+	SELECT (CASE ?1
+			WHEN 'browser' THEN COALESCE(browser, '')
+			WHEN 'browser_version' THEN COALESCE(browser_version, '')
+			WHEN 'os' THEN COALESCE(os, '')
+			WHEN 'device_type' THEN COALESCE(device_type, '')
+			WHEN 'is_bot' THEN CASE WHEN is_bot THEN 'true' ELSE 'false' END
+			ELSE ''
+		END) AS value,
+		COUNT(*) AS clicks,
+		COUNT(DISTINCT link_id || ':' || visitor_hash) AS unique_clicks
+	FROM link_visits
+	WHERE `+sqliteStatsVisitFilter+`
+	GROUP BY 1
+	UNION ALL
+	SELECT (CASE ?1
+			WHEN 'browser' THEN browser
+			WHEN 'browser_version' THEN browser_version
+			WHEN 'os' THEN os
+			WHEN 'device_type' THEN device_type
+			WHEN 'is_bot' THEN CASE WHEN is_bot THEN 'true' ELSE 'false' END
+			ELSE ''
+		END) AS value,
+		SUM(clicks) AS clicks,
+		SUM(unique_clicks) AS unique_clicks
+	FROM link_visit_daily
+	WHERE `+sqliteStatsDailyFilter+`
+	GROUP BY 1
+) AS grouped
+GROUP BY value
+ORDER BY clicks DESC, value`, sqliteStatsArgs(arg.Dimension, arg.LinkID, arg.IncludeBots, arg.PeriodStart, arg.PeriodEnd)...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.GroupVisitsRow
+	for rows.Next() {
+		var i generated.GroupVisitsRow
+		if err := rows.Scan(&i.Value, &i.Clicks, &i.UniqueClicks); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+// свёртка в одной транзакции: агрегаты дополняются переходами до cutoff,
+// затем эти переходы удаляются. Время хранится в UTC, поэтому первые
+// десять символов created_at — сутки UTC. WHERE отделяет SELECT
+// от ON CONFLICT при разборе
+func (s *sqliteStore) RollupVisits(ctx context.Context, cutoff pgtype.Timestamptz) (int64, error) {
+	var moved int64
+	err := s.inTx(ctx, func(tx *sqliteStore) error {
+		_, err := tx.tx.ExecContext(ctx, `INSERT INTO link_visit_daily (
+link_id, day, browser, browser_version, os, device_type, is_bot, clicks, unique_clicks
+)
+SELECT link_id,
+	substr(created_at, 1, 10),
+	COALESCE(browser, ''),
+	COALESCE(browser_version, ''),
+	COALESCE(os, ''),
+	COALESCE(device_type, ''),
+	is_bot,
+	COUNT(*),
+	COUNT(DISTINCT visitor_hash)
+FROM link_visits
+WHERE created_at < ?1
+GROUP BY 1, 2, 3, 4, 5, 6, 7
+ON CONFLICT (link_id, day, browser, browser_version, os, device_type, is_bot) DO UPDATE
+SET clicks = link_visit_daily.clicks + excluded.clicks,
+	unique_clicks = link_visit_daily.unique_clicks + excluded.unique_clicks`, sqliteTimestamptz(cutoff))
+		if err != nil {
+			return sqliteError(err)
+		}
+		result, err := tx.tx.ExecContext(ctx, "DELETE FROM link_visits WHERE created_at < ?", sqliteTimestamptz(cutoff))
+		if err != nil {
+			return sqliteError(err)
+		}
+		moved, err = result.RowsAffected()
+		return err
+	})
+	return moved, err
+}
+
+func (s *sqliteStore) CreateBotSignature(ctx context.Context, pattern string) (generated.BotSignature, error) {
+	var signature generated.BotSignature
+	err := s.conn().QueryRowContext(ctx, "INSERT INTO bot_signatures (pattern, created_at) VALUES (?, ?) RETURNING id, pattern, created_at", pattern, sqliteTime(s.now())).
+		Scan(&signature.ID, &signature.Pattern, sqliteTimestamp{&signature.CreatedAt})
+	return signature, sqliteError(err)
+}
+
+func (s *sqliteStore) DeleteBotSignature(ctx context.Context, id int64) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "DELETE FROM bot_signatures WHERE id = ?", id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) ListBotSignatures(ctx context.Context) ([]generated.BotSignature, error) {
+	rows, err := s.conn().QueryContext(ctx, "SELECT id, pattern, created_at FROM bot_signatures ORDER BY id")
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.BotSignature
+	for rows.Next() {
+		var i generated.BotSignature
+		if err := rows.Scan(&i.ID, &i.Pattern, sqliteTimestamp{&i.CreatedAt}); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) CreateVisitorSalt(ctx context.Context, arg generated.CreateVisitorSaltParams) error {
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO visitor_salts (day, salt) VALUES (?, ?) ON CONFLICT (day) DO NOTHING", saltDay(arg.Day), arg.Salt)
+	return sqliteError(err)
+}
+
+func (s *sqliteStore) DeleteVisitorSaltsBefore(ctx context.Context, day pgtype.Date) error {
+	_, err := s.conn().ExecContext(ctx, "DELETE FROM visitor_salts WHERE day < ?", saltDay(day))
+	return sqliteError(err)
+}
+
+func (s *sqliteStore) GetVisitorSalt(ctx context.Context, day pgtype.Date) ([]byte, error) {
+	var salt []byte
+	err := s.conn().QueryRowContext(ctx, "SELECT salt FROM visitor_salts WHERE day = ?", saltDay(day)).Scan(&salt)
+	return salt, sqliteError(err)
+}
+
+func (s *sqliteStore) CreateAuditEntry(ctx context.Context, arg generated.CreateAuditEntryParams) error {
+	_, err := s.conn().ExecContext(ctx, `INSERT INTO link_audit (
+link_id, action, actor, ip, changes, created_at
+) VALUES (
+?, ?, ?, ?, ?, ?
+)`, arg.LinkID, arg.Action, arg.Actor, arg.Ip, string(arg.Changes), sqliteTime(s.now()))
+	return sqliteError(err)
+}
+
+// условия отбора журнала, параметры ?1–?2: ссылка и автор
+const sqliteAuditFilter = `(?1 IS NULL OR link_id = ?1)
+AND (?2 IS NULL OR actor = ?2)`
+
+func (s *sqliteStore) CounterAuditEntries(ctx context.Context, arg generated.CounterAuditEntriesParams) (int64, error) {
+	var count int64
+	err := s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM link_audit WHERE "+sqliteAuditFilter, arg.LinkID, arg.Actor).Scan(&count)
+	return count, sqliteError(err)
+}
+
+func (s *sqliteStore) ListAuditEntries(ctx context.Context, arg generated.ListAuditEntriesParams) ([]generated.LinkAudit, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT id, link_id, action, actor, ip, changes, created_at
+FROM link_audit
+WHERE `+sqliteAuditFilter+`
+ORDER BY id DESC
+LIMIT ?3 OFFSET ?4`, arg.LinkID, arg.Actor, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.LinkAudit
+	for rows.Next() {
+		var i generated.LinkAudit
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkID,
+			&i.Action,
+			&i.Actor,
+			&i.Ip,
+			&i.Changes,
+			sqliteTimestamp{&i.CreatedAt},
+		); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func scanSQLiteWebhook(row interface{ Scan(...any) error }) (generated.Webhook, error) {
+	var webhook generated.Webhook
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.Url,
+		&webhook.Secret,
+		sqliteStrings{&webhook.Events},
+		sqliteTimestamp{&webhook.CreatedAt},
+	)
+	return webhook, sqliteError(err)
+}
+
+func (s *sqliteStore) CreateWebhook(ctx context.Context, arg generated.CreateWebhookParams) (generated.Webhook, error) {
+	events, err := sqliteJSONArray(arg.Events)
+	if err != nil {
+		return generated.Webhook{}, err
+	}
+	return scanSQLiteWebhook(s.conn().QueryRowContext(ctx, `INSERT INTO webhooks (
+url, secret, events, created_at
+) VALUES (
+?, ?, ?, ?
+)
+RETURNING id, url, secret, events, created_at`, arg.Url, arg.Secret, events, sqliteTime(s.now())))
+}
+
+func (s *sqliteStore) DeleteWebhook(ctx context.Context, id int64) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqliteStore) ListWebhooks(ctx context.Context) ([]generated.Webhook, error) {
+	rows, err := s.conn().QueryContext(ctx, "SELECT id, url, secret, events, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.Webhook
+	for rows.Next() {
+		webhook, err := scanSQLiteWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, webhook)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+// время очереди задаётся явно: значение по умолчанию в миллисекундах
+// не сравнивается со строками в формате sqliteTimeLayout
+func (s *sqliteStore) EnqueueWebhookEvent(ctx context.Context, arg generated.EnqueueWebhookEventParams) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, `INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, created_at)
+SELECT id, ?1, ?2, ?3, ?3
+FROM webhooks
+WHERE EXISTS (SELECT 1 FROM json_each(webhooks.events) WHERE value = ?1)`, arg.Event, string(arg.Payload), sqliteTime(s.now()))
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
+
+// выборка и продление доставок в одной транзакции; писатель в SQLite
+// один, поэтому SKIP LOCKED не нужен
+func (s *sqliteStore) ClaimWebhookDeliveries(ctx context.Context, arg generated.ClaimWebhookDeliveriesParams) ([]generated.ClaimWebhookDeliveriesRow, error) {
+	var items []generated.ClaimWebhookDeliveriesRow
+	err := s.inTx(ctx, func(tx *sqliteStore) error {
+		rows, err := tx.tx.QueryContext(ctx, `SELECT d.id, d.event, d.payload, d.attempts, w.url, w.secret
+FROM webhook_deliveries d
+JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.status = 'pending' AND d.next_attempt_at <= ?
+ORDER BY d.next_attempt_at, d.id
+LIMIT ?`, sqliteTimestamptz(arg.Now), arg.Limit)
+		if err != nil {
+			return sqliteError(err)
+		}
+		defer rows.Close()
+		var ids []int64
+		for rows.Next() {
+			var i generated.ClaimWebhookDeliveriesRow
+			if err := rows.Scan(
+				&i.ID,
+				&i.Event,
+				&i.Payload,
+				&i.Attempts,
+				&i.Url,
+				&i.Secret,
+			); err != nil {
+				return sqliteError(err)
+			}
+			items = append(items, i)
+			ids = append(ids, i.ID)
+		}
+		if err := rows.Err(); err != nil {
+			return sqliteError(err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		claimed, err := sqliteJSONArray(ids)
+		if err != nil {
+			return err
+		}
+		_, err = tx.tx.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN (SELECT value FROM json_each(?))", sqliteTimestamptz(arg.LeaseUntil), claimed)
+		return sqliteError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) CompleteWebhookDelivery(ctx context.Context, arg generated.CompleteWebhookDeliveryParams) error {
+	_, err := s.conn().ExecContext(ctx, `UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+	status = ?1,
+	response_status = ?2,
+	last_error = ?3,
+	next_attempt_at = ?4,
+	delivered_at = CASE WHEN ?1 = 'delivered' THEN ?6 END
+WHERE id = ?5`,
+		arg.Status,
+		arg.ResponseStatus,
+		arg.LastError,
+		sqliteTimestamptz(arg.NextAttemptAt),
+		arg.ID,
+		sqliteTime(s.now()),
+	)
+	return sqliteError(err)
+}
+
+func (s *sqliteStore) CounterWebhookDeliveries(ctx context.Context, webhookID int64) (int64, error) {
+	var count int64
+	err := s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ?", webhookID).Scan(&count)
+	return count, sqliteError(err)
+}
+
+func (s *sqliteStore) ListWebhookDeliveries(ctx context.Context, arg generated.ListWebhookDeliveriesParams) ([]generated.WebhookDelivery, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY id DESC
+LIMIT ? OFFSET ?`, arg.WebhookID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	defer rows.Close()
+	var items []generated.WebhookDelivery
+	for rows.Next() {
+		var i generated.WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			sqliteTimestamp{&i.NextAttemptAt},
+			&i.ResponseStatus,
+			&i.LastError,
+			sqliteTimestamp{&i.CreatedAt},
+			sqliteTimestamp{&i.DeliveredAt},
+		); err != nil {
+			return nil, sqliteError(err)
+		}
+		items = append(items, i)
+	}
+	return items, sqliteError(rows.Err())
+}
+
+func (s *sqliteStore) RedeliverWebhookDelivery(ctx context.Context, arg generated.RedeliverWebhookDeliveryParams) (int64, error) {
+	result, err := s.conn().ExecContext(ctx, "UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = ? WHERE id = ? AND webhook_id = ?",
+		sqliteTime(s.now()), arg.ID, arg.WebhookID)
+	if err != nil {
+		return 0, sqliteError(err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// измерения, по которым допускается группировка статистики
+var statsDimensions = map[string]bool{
+	"browser":         true,
+	"browser_version": true,
+	"os":              true,
+	"device_type":     true,
+	"is_bot":          true,
+}
+
+// общая статистика переходов по всем ссылкам
+func visitStats(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeStats(c, db, pgtype.Int8{})
+	}
+}
+
+// статистика переходов по одной ссылке
+func linkStats(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		// проверяем наличие записи
+		_, err = db.GetLink(c.Request.Context(), id)
+		if err != nil {
+			linkLookupError(c, err)
+			return
+		}
+		writeStats(c, db, pgtype.Int8{Int64: id, Valid: true})
+	}
+}
+
+// подсчёт переходов и уникальных посетителей за период и, при указании
+// group_by, разбивка по измерению; боты исключаются, если не передан include_bots=true.
+// Уникальные посетители считаются по суткам и складываются за период:
+// соль хеша меняется каждый день, и визиты одного посетителя в разные
+// дни связать нельзя, поэтому unique_clicks — сумма дневных значений
+func writeStats(c *gin.Context, db Store, linkID pgtype.Int8) {
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !statsDimensions[groupBy] {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "group_by must be one of: browser, browser_version, os, device_type, is_bot")
+		return
+	}
+	// по умолчанию переходы ботов не учитываются
+	includeBots, err := strconv.ParseBool(c.DefaultQuery("include_bots", "false"))
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "include_bots must be true or false")
+		return
+	}
+	// период статистики: from включительно, to до конца указанного дня
+	periodStart, err := parsePeriodBound(c.Query("from"), false)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "from must be a date (2006-01-02) or RFC 3339 time")
+		return
+	}
+	periodEnd, err := parsePeriodBound(c.Query("to"), true)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "to must be a date (2006-01-02) or RFC 3339 time")
+		return
+	}
+	var countParams generated.CountVisitsParams
+	countParams.LinkID = linkID
+	countParams.IncludeBots = includeBots
+	countParams.PeriodStart = periodStart
+	countParams.PeriodEnd = periodEnd
+	totals, err := db.CountVisits(c.Request.Context(), countParams)
+	if err != nil {
+		dbError(c, "unable to count visits", err)
+		return
+	}
+	res := gin.H{"clicks": totals.Clicks, "unique_clicks": totals.UniqueClicks}
+	if groupBy != "" {
+		var groupParams generated.GroupVisitsParams
+		groupParams.Dimension = groupBy
+		groupParams.LinkID = linkID
+		groupParams.IncludeBots = includeBots
+		groupParams.PeriodStart = periodStart
+		groupParams.PeriodEnd = periodEnd
+		groups, err := db.GroupVisits(c.Request.Context(), groupParams)
+		if err != nil {
+			dbError(c, "unable to group visits", err)
+			return
+		}
+		if groups == nil {
+			groups = []generated.GroupVisitsRow{}
+		}
+		res["group_by"] = groupBy
+		res["groups"] = groups
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// разбор границы периода: дата или время в формате RFC 3339.
+// Для верхней границы дата означает конец указанного дня
+func parsePeriodBound(value string, end bool) (pgtype.Timestamptz, error) {
+	if value == "" {
+		return pgtype.Timestamptz{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return pgtype.Timestamptz{Time: t, Valid: true}, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return pgtype.Timestamptz{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return pgtype.Timestamptz{Time: t, Valid: true}, nil
+}
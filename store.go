@@ -0,0 +1,119 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// хранилище сервиса. Через него работают все обработчики и фоновые
+// задачи. Методы повторяют sqlc-запросы, postgresStore
+// добавляет к *generated.Queries только транзакции. Реализации возвращают
+// те же ошибки, что и Postgres: pgx.ErrNoRows для отсутствующей записи
+// и *pgconn.PgError с кодом нарушения уникальности или внешнего ключа
+type Store interface {
+	// выполнение fn в транзакции: изменения через tx сохраняются вместе
+	// с записью журнала или не сохраняются совсем. Вложенный вызов работает
+	// как точка сохранения, поэтому после ошибки в нём транзакцию можно
+	// продолжить. Внутри fn нельзя обращаться к внешнему хранилищу
+	InTx(ctx context.Context, fn func(tx Store) error) error
+	// ссылки
+	CounterLinks(ctx context.Context, arg generated.CounterLinksParams) (int64, error)
+	CreateLink(ctx context.Context, arg generated.CreateLinkParams) (generated.Link, error)
+	GetLink(ctx context.Context, id int64) (generated.GetLinkRow, error)
+	GetLinkForUpdate(ctx context.Context, id int64) (generated.GetLinkForUpdateRow, error)
+	GetLinkFromCode(ctx context.Context, shortName pgtype.Text) (generated.GetLinkFromCodeRow, error)
+	LastLink(ctx context.Context) (generated.Link, error)
+	ListLinks(ctx context.Context, arg generated.ListLinksParams) ([]generated.ListLinksRow, error)
+	ListLinksByCursorAsc(ctx context.Context, arg generated.ListLinksByCursorAscParams) ([]generated.ListLinksByCursorAscRow, error)
+	ListLinksByCursorDesc(ctx context.Context, arg generated.ListLinksByCursorDescParams) ([]generated.ListLinksByCursorDescRow, error)
+	SearchLinks(ctx context.Context, arg generated.SearchLinksParams) ([]generated.SearchLinksRow, error)
+	RestoreLink(ctx context.Context, id int64) (int64, error)
+	SoftDeleteLink(ctx context.Context, id int64) (int64, error)
+	UpdateLink(ctx context.Context, arg generated.UpdateLinkParams) error
+	UpdateShortName(ctx context.Context, arg generated.UpdateShortNameParams) error
+	// корзина
+	CounterDeletedLinks(ctx context.Context) (int64, error)
+	ListDeletedLinks(ctx context.Context, arg generated.ListDeletedLinksParams) ([]generated.ListDeletedLinksRow, error)
+	PurgeDeletedLinks(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error)
+	// папки
+	CreateFolder(ctx context.Context, name string) (generated.Folder, error)
+	DeleteFolder(ctx context.Context, id int64) (int64, error)
+	ListFolders(ctx context.Context) ([]generated.ListFoldersRow, error)
+	UpdateFolder(ctx context.Context, arg generated.UpdateFolderParams) (int64, error)
+	// история адресов
+	AddLinkDestination(ctx context.Context, arg generated.AddLinkDestinationParams) (int32, error)
+	ListLinkDestinations(ctx context.Context, linkID int64) ([]generated.ListLinkDestinationsRow, error)
+	RollbackLinkDestination(ctx context.Context, arg generated.RollbackLinkDestinationParams) (int64, error)
+	// метки
+	CreateTag(ctx context.Context, name string) (generated.Tag, error)
+	DeleteTag(ctx context.Context, id int64) (int64, error)
+	ListTags(ctx context.Context) ([]generated.ListTagsRow, error)
+	SetLinkTags(ctx context.Context, arg generated.SetLinkTagsParams) error
+	TagStats(ctx context.Context, arg generated.TagStatsParams) ([]generated.TagStatsRow, error)
+	UpdateTag(ctx context.Context, arg generated.UpdateTagParams) (int64, error)
+	// переходы
+	CounterVisits(ctx context.Context, arg generated.CounterVisitsParams) (int64, error)
+	CreateLinkVisits(ctx context.Context, arg generated.CreateLinkVisitsParams) (generated.LinkVisit, error)
+	ListLinkVisits(ctx context.Context, arg generated.ListLinkVisitsParams) ([]generated.LinkVisit, error)
+	ListLinkVisitsByCursorAsc(ctx context.Context, arg generated.ListLinkVisitsByCursorAscParams) ([]generated.LinkVisit, error)
+	ListLinkVisitsByCursorDesc(ctx context.Context, arg generated.ListLinkVisitsByCursorDescParams) ([]generated.LinkVisit, error)
+	// статистика и свёртка старых переходов в дневные агрегаты
+	CountVisits(ctx context.Context, arg generated.CountVisitsParams) (generated.CountVisitsRow, error)
+	GroupVisits(ctx context.Context, arg generated.GroupVisitsParams) ([]generated.GroupVisitsRow, error)
+	RollupVisits(ctx context.Context, cutoff pgtype.Timestamptz) (int64, error)
+	// сигнатуры ботов
+	CreateBotSignature(ctx context.Context, pattern string) (generated.BotSignature, error)
+	DeleteBotSignature(ctx context.Context, id int64) (int64, error)
+	ListBotSignatures(ctx context.Context) ([]generated.BotSignature, error)
+	// соль для хеша посетителей
+	CreateVisitorSalt(ctx context.Context, arg generated.CreateVisitorSaltParams) error
+	DeleteVisitorSaltsBefore(ctx context.Context, day pgtype.Date) error
+	GetVisitorSalt(ctx context.Context, day pgtype.Date) ([]byte, error)
+	// журнал изменений
+	CounterAuditEntries(ctx context.Context, arg generated.CounterAuditEntriesParams) (int64, error)
+	CreateAuditEntry(ctx context.Context, arg generated.CreateAuditEntryParams) error
+	ListAuditEntries(ctx context.Context, arg generated.ListAuditEntriesParams) ([]generated.LinkAudit, error)
+	// вебхуки и очередь доставки событий
+	ClaimWebhookDeliveries(ctx context.Context, arg generated.ClaimWebhookDeliveriesParams) ([]generated.ClaimWebhookDeliveriesRow, error)
+	CompleteWebhookDelivery(ctx context.Context, arg generated.CompleteWebhookDeliveryParams) error
+	CounterWebhookDeliveries(ctx context.Context, webhookID int64) (int64, error)
+	CreateWebhook(ctx context.Context, arg generated.CreateWebhookParams) (generated.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) (int64, error)
+	EnqueueWebhookEvent(ctx context.Context, arg generated.EnqueueWebhookEventParams) (int64, error)
+	ListWebhookDeliveries(ctx context.Context, arg generated.ListWebhookDeliveriesParams) ([]generated.WebhookDelivery, error)
+	ListWebhooks(ctx context.Context) ([]generated.Webhook, error)
+	RedeliverWebhookDelivery(ctx context.Context, arg generated.RedeliverWebhookDeliveryParams) (int64, error)
+}
+
+// хранилище Postgres: sqlc-запросы и соединение, в котором начинаются
+// транзакции. Вне транзакции это пул, внутри неё — сама транзакция,
+// и вложенный InTx открывает точку сохранения
+type postgresStore struct {
+	*generated.Queries
+	db interface {
+		Begin(ctx context.Context) (pgx.Tx, error)
+	}
+}
+
+func newPostgresStore(pool *pgxpool.Pool) *postgresStore {
+	return &postgresStore{Queries: generated.New(pool), db: pool}
+}
+
+var _ Store = (*postgresStore)(nil)
+
+func (s *postgresStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// после Commit откат ничего не делает
+	defer tx.Rollback(ctx)
+	if err := fn(&postgresStore{Queries: s.WithTx(tx), db: tx}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// число последних переходов, хранимых для продолжения потока по Last-Event-ID
+	streamHistorySize = 256
+	// очередь событий клиента. Клиент, не успевающий её разбирать,
+	// отключается и переподключается с Last-Event-ID
+	streamClientBuffer = 64
+	// периодичность пустых сообщений, чтобы прокси не закрывали соединение
+	streamHeartbeat = 15 * time.Second
+	// задержка переподключения для EventSource в миллисекундах
+	streamRetryMillis = 3000
+)
+
+// подписчик на переходы; linkID равен 0 для всех ссылок
+type visitSubscriber struct {
+	linkID int64
+	events chan generated.LinkVisit
+}
+
+func (s *visitSubscriber) matches(visit generated.LinkVisit) bool {
+	return s.linkID == 0 || s.linkID == visit.LinkID
+}
+
+// рассылка переходов подписчикам внутри процесса
+type visitHub struct {
+	mu          sync.Mutex
+	history     []generated.LinkVisit
+	subscribers map[*visitSubscriber]struct{}
+}
+
+func newVisitHub() *visitHub {
+	return &visitHub{subscribers: make(map[*visitSubscriber]struct{})}
+}
+
+// отправка перехода подписчикам. Публикация не ждёт медленных
+// клиентов: при переполненной очереди клиент отключается
+func (h *visitHub) publish(visit generated.LinkVisit) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.history) == streamHistorySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:len(h.history)-1]
+	}
+	h.history = append(h.history, visit)
+	for sub := range h.subscribers {
+		if !sub.matches(visit) {
+			continue
+		}
+		select {
+		case sub.events <- visit:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// подписка на переходы и пропущенные переходы с id больше lastID.
+// Пропущенные выбираются под той же блокировкой, поэтому
+// между ними и новыми событиями нет ни пропусков, ни повторов
+func (h *visitHub) subscribe(linkID int64, lastID int64) (*visitSubscriber, []generated.LinkVisit) {
+	sub := &visitSubscriber{linkID: linkID, events: make(chan generated.LinkVisit, streamClientBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var missed []generated.LinkVisit
+	if lastID > 0 {
+		for _, visit := range h.history {
+			if visit.ID > lastID && sub.matches(visit) {
+				missed = append(missed, visit)
+			}
+		}
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub, missed
+}
+
+// отписка, если клиент ещё не отключён из-за переполнения
+func (h *visitHub) unsubscribe(sub *visitSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// число подключённых клиентов
+func (h *visitHub) subscriberCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// запись перехода в формате Server-Sent Events
+func writeVisitEvent(w gin.ResponseWriter, visit generated.LinkVisit) error {
+	data, err := json.Marshal(visit)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: visit\ndata: %s\n\n", visit.ID, data)
+	return err
+}
+
+// поток новых переходов. Параметр link_id ограничивает поток одной ссылкой,
+// заголовок Last-Event-ID (или параметр last_event_id) продолжает поток
+// после указанного перехода
+func streamVisits(hub *visitHub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var linkID int64
+		if value := c.Query("link_id"); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id < 1 {
+				writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "link_id must be a positive number")
+				return
+			}
+			linkID = id
+		}
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+		var lastID int64
+		if lastEventID != "" {
+			id, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil {
+				writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "Last-Event-ID must be a visit id")
+				return
+			}
+			lastID = id
+		}
+		sub, missed := hub.subscribe(linkID, lastID)
+		defer hub.unsubscribe(sub)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// nginx не должен буферизовать поток
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMillis); err != nil {
+			return
+		}
+		for _, visit := range missed {
+			if err := writeVisitEvent(c.Writer, visit); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case visit, ok := <-sub.events:
+				// очередь закрыта: клиент не успевал читать поток
+				if !ok {
+					return
+				}
+				if err := writeVisitEvent(c.Writer, visit); err != nil {
+					return
+				}
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisitHubFilterAndResume(t *testing.T) {
+	hub := newVisitHub()
+	hub.publish(generated.LinkVisit{ID: 1, LinkID: 1})
+	hub.publish(generated.LinkVisit{ID: 2, LinkID: 2})
+	hub.publish(generated.LinkVisit{ID: 3, LinkID: 1})
+
+	// продолжение после перехода 1 только по ссылке 1
+	sub, missed := hub.subscribe(1, 1)
+	if assert.Equal(t, 1, len(missed)) {
+		assert.Equal(t, int64(3), missed[0].ID)
+	}
+	hub.publish(generated.LinkVisit{ID: 4, LinkID: 2})
+	hub.publish(generated.LinkVisit{ID: 5, LinkID: 1})
+	assert.Equal(t, int64(5), (<-sub.events).ID)
+	assert.Equal(t, 0, len(sub.events))
+
+	// без Last-Event-ID история не отправляется
+	_, missed = hub.subscribe(0, 0)
+	assert.Empty(t, missed)
+
+	hub.unsubscribe(sub)
+	_, ok := <-sub.events
+	assert.False(t, ok)
+	hub.unsubscribe(sub)
+}
+
+func TestVisitHubDropsSlowClient(t *testing.T) {
+	hub := newVisitHub()
+	sub, _ := hub.subscribe(0, 0)
+	for id := int64(1); id <= streamClientBuffer+1; id++ {
+		hub.publish(generated.LinkVisit{ID: id, LinkID: 1})
+	}
+	// очередь заполнена, лишний переход закрыл её
+	received := 0
+	for range sub.events {
+		received++
+	}
+	assert.Equal(t, streamClientBuffer, received)
+	assert.Empty(t, hub.subscribers)
+}
+
+func TestVisitHubHistoryLimit(t *testing.T) {
+	hub := newVisitHub()
+	for id := int64(1); id <= streamHistorySize+10; id++ {
+		hub.publish(generated.LinkVisit{ID: id, LinkID: 1})
+	}
+	assert.Equal(t, streamHistorySize, len(hub.history))
+	assert.Equal(t, int64(11), hub.history[0].ID)
+}
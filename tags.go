@@ -0,0 +1,242 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// код ошибки PostgreSQL при ссылке на несуществующую запись
+const pgForeignKeyViolation = "23503"
+
+// ссылка вместе с метками для ответа при создании
+type linkWithTags struct {
+	generated.Link
+	Tags []string `json:"tags"`
+}
+
+// имя метки без учёта регистра и крайних пробелов
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// метки ссылки без пустых имён и повторов, по алфавиту
+func normalizeTags(names []string) []string {
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag := normalizeTag(name)
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// id папки из запроса, NULL если папка не указана
+func folderOrNull(id *int64) pgtype.Int8 {
+	if id == nil {
+		return pgtype.Int8{}
+	}
+	return pgtype.Int8{Int64: *id, Valid: true}
+}
+
+// ошибка ссылки на несуществующую папку
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation
+}
+
+// нарушение уникальности, например занятое короткое имя
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// ограничение UNIQUE колонки links.short_name
+const shortNameConstraint = "links_short_name_key"
+
+// занятое короткое имя. Другие нарушения уникальности, например
+// совпавшая версия адреса, не означают, что имя занято
+func isShortNameViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return isUniqueViolation(err) && errors.As(err, &pgErr) && pgErr.ConstraintName == shortNameConstraint
+}
+
+// замена меток ссылки, отсутствующие метки создаются
+func setLinkTags(c *gin.Context, db Store, linkID int64, tags []string) error {
+	var tagsParams generated.SetLinkTagsParams
+	tagsParams.Names = tags
+	tagsParams.LinkID = linkID
+	return db.SetLinkTags(c.Request.Context(), tagsParams)
+}
+
+// структура для валидации имени метки или папки
+type NameRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// список меток с числом ссылок
+func listTags(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tags, err := db.ListTags(c.Request.Context())
+		if err != nil {
+			dbError(c, "unable to get tags", err)
+			return
+		}
+		if tags == nil {
+			tags = []generated.ListTagsRow{}
+		}
+		c.JSON(http.StatusOK, tags)
+	}
+}
+
+// создание метки
+func createTag(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req NameRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				errorsMap := make(map[string]string)
+				for _, e := range ve {
+					errorsMap[e.Field()] = e.Tag()
+				}
+				writeValidationProblem(c, errorsMap)
+				return
+			}
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
+			return
+		}
+		name := normalizeTag(req.Name)
+		if name == "" {
+			writeValidationProblem(c, map[string]string{"Name": "required"})
+			return
+		}
+		res, err := db.CreateTag(c.Request.Context(), name)
+		if isUniqueViolation(err) {
+			writeProblem(c, http.StatusConflict, codeTagExists, "tag already exists")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to create tag", err)
+			return
+		}
+		c.JSON(http.StatusCreated, res)
+	}
+}
+
+// переименование метки, ссылки сохраняют её
+func updateTag(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		var req NameRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				errorsMap := make(map[string]string)
+				for _, e := range ve {
+					errorsMap[e.Field()] = e.Tag()
+				}
+				writeValidationProblem(c, errorsMap)
+				return
+			}
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
+			return
+		}
+		name := normalizeTag(req.Name)
+		if name == "" {
+			writeValidationProblem(c, map[string]string{"Name": "required"})
+			return
+		}
+		var tagParams generated.UpdateTagParams
+		tagParams.ID = id
+		tagParams.Name = name
+		updated, err := db.UpdateTag(c.Request.Context(), tagParams)
+		if isUniqueViolation(err) {
+			writeProblem(c, http.StatusConflict, codeTagExists, "tag already exists")
+			return
+		}
+		if err != nil {
+			dbError(c, "unable to update tag", err)
+			return
+		}
+		if updated == 0 {
+			writeProblem(c, http.StatusNotFound, codeTagNotFound, "tag not found")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "name": name})
+	}
+}
+
+// удаление метки, со ссылок она снимается
+func deleteTag(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		deleted, err := db.DeleteTag(c.Request.Context(), id)
+		if err != nil {
+			dbError(c, "unable to delete tag", err)
+			return
+		}
+		if deleted == 0 {
+			writeProblem(c, http.StatusNotFound, codeTagNotFound, "tag not found")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// статистика переходов по меткам. Переход по ссылке с несколькими
+// метками засчитывается каждой из них
+func tagStats(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// по умолчанию переходы ботов не учитываются
+		includeBots, err := strconv.ParseBool(c.DefaultQuery("include_bots", "false"))
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "include_bots must be true or false")
+			return
+		}
+		periodStart, err := parsePeriodBound(c.Query("from"), false)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "from must be a date (2006-01-02) or RFC 3339 time")
+			return
+		}
+		periodEnd, err := parsePeriodBound(c.Query("to"), true)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "to must be a date (2006-01-02) or RFC 3339 time")
+			return
+		}
+		var statsParams generated.TagStatsParams
+		statsParams.IncludeBots = includeBots
+		statsParams.PeriodStart = periodStart
+		statsParams.PeriodEnd = periodEnd
+		stats, err := db.TagStats(c.Request.Context(), statsParams)
+		if err != nil {
+			dbError(c, "unable to count visits by tag", err)
+			return
+		}
+		if stats == nil {
+			stats = []generated.TagStatsRow{}
+		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
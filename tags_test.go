@@ -0,0 +1,12 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"promo", "q3"}, normalizeTags([]string{" Q3", "promo", "PROMO", ""}))
+	assert.Equal(t, []string{}, normalizeTags(nil))
+}
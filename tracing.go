@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// имя сервиса по умолчанию, переопределяется OTEL_SERVICE_NAME
+	tracingServiceName = "url-shortener"
+	// имя инструментирования запросов к БД
+	dbTracerName = "code/db"
+	// значения OTEL_TRACES_EXPORTER
+	tracesExporterOTLP   = "otlp"
+	tracesExporterStdout = "stdout"
+	tracesExporterNone   = "none"
+)
+
+// выбор экспортёра трассировок. Без OTEL_TRACES_EXPORTER трассировки
+// отправляются по OTLP, только если задан адрес коллектора
+func loadTracesExporter() string {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if value != "" {
+		return value
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return tracesExporterOTLP
+	}
+	return tracesExporterNone
+}
+
+// настройка OpenTelemetry: распространение контекста W3C и экспорт
+// трассировок. Возвращает функцию, отправляющую оставшиеся спаны
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	// заголовки traceparent и tracestate принимаются даже без экспорта,
+	// чтобы не разрывать трассировку вызывающего сервиса
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch loadTracesExporter() {
+	case tracesExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case tracesExporterOTLP:
+		// адрес и заголовки берутся из стандартных переменных OTEL_EXPORTER_OTLP_*
+		exporter, err = otlptracehttp.New(ctx)
+	case tracesExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER must be one of: %s, %s, %s", tracesExporterOTLP, tracesExporterStdout, tracesExporterNone)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", tracingServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// трассировка HTTP-запросов; сбор метрик и проверки состояния не трассируются
+func tracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(tracingServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		switch r.URL.Path {
+		case "/metrics", "/healthz", "/readyz":
+			return false
+		}
+		return true
+	}))
+}
+
+// трассировка запросов pgx. Все вызовы generated.Queries проходят
+// через соединение, поэтому каждый получает свой спан
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func newQueryTracer() *queryTracer {
+	return &queryTracer{tracer: otel.Tracer(dbTracerName)}
+}
+
+// имя запроса sqlc из комментария "-- name: GetLink :one"
+func queryName(sql string) string {
+	const prefix = "-- name: "
+	if !strings.HasPrefix(sql, prefix) {
+		return ""
+	}
+	line, _, _ := strings.Cut(sql[len(prefix):], "\n")
+	name, _, _ := strings.Cut(line, " ")
+	return name
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	spanName := "postgresql"
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.query.text", data.SQL),
+	}
+	if name := queryName(data.SQL); name != "" {
+		spanName = name
+		attrs = append(attrs, attribute.String("db.operation.name", name))
+	}
+	ctx, _ = t.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	} else {
+		span.SetAttributes(attribute.Int64("db.response.affected_rows", data.CommandTag.RowsAffected()))
+	}
+	span.End()
+}
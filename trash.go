@@ -0,0 +1,126 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"code/pagination"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// срок хранения удалённых ссылок в корзине по умолчанию
+const defaultTrashRetentionDays = 30
+
+const (
+	// периодичность очистки корзины
+	trashPurgeInterval = time.Hour
+	// имя задачи в /readyz
+	trashPurgeWorker = "trash_purge"
+)
+
+// срок хранения удалённых ссылок в днях из переменной TRASH_RETENTION_DAYS,
+// 0 отключает очистку корзины
+func loadTrashRetentionDays() int {
+	value := os.Getenv("TRASH_RETENTION_DAYS")
+	if value == "" {
+		return defaultTrashRetentionDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		slog.Warn("invalid TRASH_RETENTION_DAYS value, using the default", "value", value, "days", defaultTrashRetentionDays)
+		return defaultTrashRetentionDays
+	}
+	return days
+}
+
+// окончательное удаление ссылок, пролежавших в корзине дольше срока хранения,
+// вместе с их переходами
+func purgeDeletedLinks(ctx context.Context, db Store, days int, now time.Time) (int64, error) {
+	cutoff := pgtype.Timestamptz{Time: now.AddDate(0, 0, -days), Valid: true}
+	return db.PurgeDeletedLinks(ctx, cutoff)
+}
+
+// запуск очистки корзины в фоне
+func startTrashPurge(ctx context.Context, db Store, days int, workers *workerRegistry) {
+	if days <= 0 {
+		workers.disabled(trashPurgeWorker)
+		return
+	}
+	runPeriodically(ctx, workers, trashPurgeWorker, trashPurgeInterval, func(now time.Time) error {
+		purged, err := purgeDeletedLinks(ctx, db, days, now)
+		if err != nil {
+			slog.Error("trash purge failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("trash purge: links deleted", "links", purged)
+		}
+		return err
+	})
+}
+
+// список удалённых ссылок, последние удалённые первыми
+func listTrash(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := pagination.FromRequest(c.Request, "links")
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		var paginParams generated.ListDeletedLinksParams
+		paginParams.Limit = int32(page.Limit)
+		paginParams.Offset = int32(page.Offset)
+		links, err := db.ListDeletedLinks(c.Request.Context(), paginParams)
+		if err != nil {
+			dbError(c, "unable to get links", err)
+			return
+		}
+		count, err := db.CounterDeletedLinks(c.Request.Context())
+		if err != nil {
+			dbError(c, "unable to count the number of records", err)
+			return
+		}
+		writePage(c, page, "links", links, count)
+	}
+}
+
+// восстановление ссылки из корзины
+func restoreLink(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		var restored int64
+		var link generated.GetLinkRow
+		err = db.InTx(c.Request.Context(), func(tx Store) error {
+			var err error
+			restored, err = tx.RestoreLink(c.Request.Context(), id)
+			if err != nil || restored == 0 {
+				return err
+			}
+			link, err = tx.GetLink(c.Request.Context(), id)
+			if err != nil {
+				return failedStep("unable to get restored link", err)
+			}
+			before := snapshotFromRow(link)
+			before["deleted"] = true
+			return recordAudit(c, tx, id, auditRestore, before, snapshotFromRow(link))
+		})
+		if err != nil {
+			dbError(c, "unable to restore link", err)
+			return
+		}
+		if restored == 0 {
+			writeProblem(c, http.StatusNotFound, codeLinkNotFound, "link not found in trash")
+			return
+		}
+		c.JSON(http.StatusOK, link)
+	}
+}
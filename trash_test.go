@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTrashRetentionDays(t *testing.T) {
+	t.Setenv("TRASH_RETENTION_DAYS", "7")
+	assert.Equal(t, 7, loadTrashRetentionDays())
+	t.Setenv("TRASH_RETENTION_DAYS", "0")
+	assert.Equal(t, 0, loadTrashRetentionDays())
+	t.Setenv("TRASH_RETENTION_DAYS", "week")
+	assert.Equal(t, defaultTrashRetentionDays, loadTrashRetentionDays())
+	t.Setenv("TRASH_RETENTION_DAYS", "")
+	assert.Equal(t, defaultTrashRetentionDays, loadTrashRetentionDays())
+}
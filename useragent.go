@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// максимальная длина User-Agent, сохраняемая в link_visits
+const maxUserAgentLength = 255
+
+// типы устройств посетителя
+const (
+	deviceDesktop = "desktop"
+	deviceMobile  = "mobile"
+	deviceTablet  = "tablet"
+	deviceBot     = "bot"
+	deviceUnknown = "unknown"
+)
+
+// структурированные сведения из заголовка User-Agent,
+// признак бота выставляет botDetector
+type userAgentInfo struct {
+	Browser        string
+	BrowserVersion string
+	OS             string
+	DeviceType     string
+	IsBot          bool
+}
+
+// сигнатура браузера: токен в строке User-Agent и отображаемое имя
+type browserSignature struct {
+	token string
+	name  string
+}
+
+// порядок важен: Edge, Opera и Яндекс.Браузер содержат токен Chrome,
+// а Chrome в свою очередь содержит токен Safari
+var browserSignatures = []browserSignature{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Opera/", "Opera"},
+	{"YaBrowser/", "Yandex Browser"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"MSIE ", "Internet Explorer"},
+	{"Trident/", "Internet Explorer"},
+	{"Version/", "Safari"},
+}
+
+// разбор строки User-Agent на браузер, его версию, ОС и тип устройства
+func parseUserAgent(ua string) userAgentInfo {
+	info := userAgentInfo{DeviceType: deviceUnknown}
+	if ua == "" {
+		return info
+	}
+	info.Browser, info.BrowserVersion = detectBrowser(ua)
+	info.OS = detectOS(ua)
+	info.DeviceType = detectDevice(ua, info.OS)
+	return info
+}
+
+// определение браузера и его основной (major) версии
+func detectBrowser(ua string) (string, string) {
+	for _, sig := range browserSignatures {
+		idx := strings.Index(ua, sig.token)
+		if idx < 0 {
+			continue
+		}
+		// Safari определяем только при наличии токена Safari
+		if sig.name == "Safari" && !strings.Contains(ua, "Safari/") {
+			continue
+		}
+		version := ua[idx+len(sig.token):]
+		// у Internet Explorer 11 версия указывается в поле rv
+		if sig.token == "Trident/" {
+			version = ""
+			if rv := strings.Index(ua, "rv:"); rv >= 0 {
+				version = ua[rv+len("rv:"):]
+			}
+		}
+		return sig.name, majorVersion(version)
+	}
+	return "", ""
+}
+
+// выделение основной версии из строки вида "124.0.6367.91 Safari/537.36"
+func majorVersion(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
+
+// определение операционной системы
+func detectOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "Windows Phone"):
+		return "Windows Phone"
+	case strings.Contains(ua, "Windows"):
+		return "Windows"
+	case strings.Contains(ua, "Android"):
+		return "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return "iOS"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "CrOS"):
+		return "ChromeOS"
+	case strings.Contains(ua, "Linux"):
+		return "Linux"
+	}
+	return ""
+}
+
+// определение типа устройства
+func detectDevice(ua string, osName string) string {
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"):
+		return deviceTablet
+	// планшеты на Android не передают токен Mobile
+	case osName == "Android" && !strings.Contains(ua, "Mobile"):
+		return deviceTablet
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), osName == "Windows Phone":
+		return deviceMobile
+	case osName != "":
+		return deviceDesktop
+	}
+	return deviceUnknown
+}
+
+// обрезка строки до заданного числа символов
+func truncateString(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit])
+}
+
+// преобразование строки в pgtype.Text, пустая строка сохраняется как NULL
+func textOrNull(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want userAgentInfo
+	}{
+		{
+			"chrome on windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.91 Safari/537.36",
+			userAgentInfo{Browser: "Chrome", BrowserVersion: "124", OS: "Windows", DeviceType: deviceDesktop},
+		},
+		{
+			"safari on iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			userAgentInfo{Browser: "Safari", BrowserVersion: "17", OS: "iOS", DeviceType: deviceMobile},
+		},
+		{
+			"edge on windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67",
+			userAgentInfo{Browser: "Edge", BrowserVersion: "124", OS: "Windows", DeviceType: deviceDesktop},
+		},
+		{
+			"firefox on linux",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			userAgentInfo{Browser: "Firefox", BrowserVersion: "125", OS: "Linux", DeviceType: deviceDesktop},
+		},
+		{
+			"chrome on android tablet",
+			"Mozilla/5.0 (Linux; Android 13; SM-X200) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			userAgentInfo{Browser: "Chrome", BrowserVersion: "124", OS: "Android", DeviceType: deviceTablet},
+		},
+		{
+			"googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			userAgentInfo{DeviceType: deviceUnknown},
+		},
+		{"empty", "", userAgentInfo{DeviceType: deviceUnknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseUserAgent(tt.ua))
+		})
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	assert.Equal(t, "abc", truncateString("abc", 5))
+	assert.Equal(t, "аб", truncateString("абв", 2))
+}
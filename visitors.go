@@ -0,0 +1,83 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// длина соли для хеширования посетителей в байтах
+const visitorSaltSize = 32
+
+// вычисление анонимного идентификатора посетителя.
+// Соль меняется каждые сутки (UTC), а старые соли удаляются из БД,
+// поэтому по хешу нельзя восстановить IP и User-Agent
+// и нельзя связать визиты одного посетителя за разные дни
+type visitorHasher struct {
+	mu   sync.Mutex
+	day  time.Time
+	salt []byte
+}
+
+// создание хешера посетителей
+func newVisitorHasher() *visitorHasher {
+	return &visitorHasher{}
+}
+
+// хеш посетителя по IP и User-Agent с солью текущих суток
+func (h *visitorHasher) hash(ctx context.Context, db Store, ip string, userAgent string, now time.Time) (string, error) {
+	salt, err := h.saltFor(ctx, db, now)
+	if err != nil {
+		return "", err
+	}
+	return visitorHash(salt, ip, userAgent), nil
+}
+
+// получение соли текущих суток, при смене суток соль создаётся заново
+func (h *visitorHasher) saltFor(ctx context.Context, db Store, now time.Time) ([]byte, error) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.salt != nil && h.day.Equal(day) {
+		return h.salt, nil
+	}
+	salt := make([]byte, visitorSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	dayDate := pgtype.Date{Time: day, Valid: true}
+	// при нескольких экземплярах сервиса сохраняется соль, созданная первой
+	var saltParams generated.CreateVisitorSaltParams
+	saltParams.Day = dayDate
+	saltParams.Salt = salt
+	if err := db.CreateVisitorSalt(ctx, saltParams); err != nil {
+		return nil, err
+	}
+	salt, err := db.GetVisitorSalt(ctx, dayDate)
+	if err != nil {
+		return nil, err
+	}
+	// удаляем соли прошедших суток
+	if err := db.DeleteVisitorSaltsBefore(ctx, dayDate); err != nil {
+		return nil, err
+	}
+	h.day = day
+	h.salt = salt
+	return salt, nil
+}
+
+// HMAC-SHA256 от IP и User-Agent
+func visitorHash(salt []byte, ip string, userAgent string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+	return hex.EncodeToString(mac.Sum(nil))
+}
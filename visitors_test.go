@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisitorHash(t *testing.T) {
+	salt := []byte("daily-salt")
+	ua := "Mozilla/5.0 (X11; Linux x86_64) Firefox/125.0"
+	first := visitorHash(salt, "203.0.113.7", ua)
+	// хеш стабилен в пределах одной соли
+	assert.Equal(t, first, visitorHash(salt, "203.0.113.7", ua))
+	assert.Len(t, first, 64)
+	assert.NotContains(t, first, "203.0.113.7")
+	// другой посетитель или другая соль дают другой хеш
+	assert.NotEqual(t, first, visitorHash(salt, "203.0.113.8", ua))
+	assert.NotEqual(t, first, visitorHash([]byte("next-day-salt"), "203.0.113.7", ua))
+}
+
+func TestParsePeriodBound(t *testing.T) {
+	start, err := parsePeriodBound("2026-05-26", false)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 26, 0, 0, 0, 0, time.UTC), start.Time)
+	end, err := parsePeriodBound("2026-05-26", true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 27, 0, 0, 0, 0, time.UTC), end.Time)
+	exact, err := parsePeriodBound("2026-05-26T12:35:00Z", true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 26, 12, 35, 0, 0, time.UTC), exact.Time)
+	empty, err := parsePeriodBound("", false)
+	assert.NoError(t, err)
+	assert.False(t, empty.Valid)
+	_, err = parsePeriodBound("yesterday", false)
+	assert.Error(t, err)
+}
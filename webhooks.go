@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bytes"
+	generated "code/db/generated"
+	"code/pagination"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// события, на которые можно подписаться
+const (
+	eventLinkCreated = "link.created"
+	eventLinkUpdated = "link.updated"
+	eventLinkDeleted = "link.deleted"
+	eventLinkVisited = "link.visited"
+)
+
+// заголовки запроса с событием
+const (
+	webhookEventHeader     = "X-Webhook-Event"
+	webhookDeliveryHeader  = "X-Webhook-Delivery"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// состояния доставки
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const (
+	// периодичность разбора очереди доставки
+	webhookDispatchInterval = 5 * time.Second
+	// имя задачи в /readyz
+	webhookDispatcherWorker = "webhook_dispatcher"
+	// число доставок, забираемых из очереди за раз
+	webhookBatchSize = 20
+	// после стольких неудачных попыток доставка считается проваленной
+	webhookMaxAttempts = 8
+	// задержка перед повтором удваивается с каждой попыткой до webhookRetryMax
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = 6 * time.Hour
+	// время ожидания ответа получателя
+	webhookTimeout = 10 * time.Second
+	// доставка, взятая в работу, недоступна другим обработчикам это время.
+	// Оно больше времени отправки целой пачки, поэтому аренда не истекает,
+	// пока пачка отправляется. Если процесс упал во время отправки,
+	// доставка будет повторена
+	webhookLease = webhookBatchSize*webhookTimeout + time.Minute
+	// максимальная длина сохраняемого текста ошибки
+	maxWebhookErrorLength = 500
+	// переходы, ожидающие записи в очередь доставки. При переполнении
+	// новые события отбрасываются, чтобы не задерживать перенаправление
+	webhookEventBuffer = 1024
+)
+
+// настройки вебхуков
+type webhookConfig struct {
+	// разрешить получателей с адресом http://, например в закрытой сети
+	AllowHTTP bool
+}
+
+// чтение настроек вебхуков из переменной окружения WEBHOOK_ALLOW_HTTP
+func loadWebhookConfig() webhookConfig {
+	value := os.Getenv("WEBHOOK_ALLOW_HTTP")
+	if value == "" {
+		return webhookConfig{}
+	}
+	allowHTTP, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid WEBHOOK_ALLOW_HTTP value, only https webhooks are allowed", "value", value)
+		return webhookConfig{}
+	}
+	return webhookConfig{AllowHTTP: allowHTTP}
+}
+
+var (
+	errWebhookScheme  = errors.New("webhook url must use https")
+	errWebhookAddress = errors.New("webhook url must not point to a loopback, link-local or private address")
+)
+
+// общее адресное пространство провайдеров 100.64.0.0/10, netip не считает его частным
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// адрес в интернете: не loopback, не link-local (в том числе 169.254.169.254
+// с метаданными облака), не частная сеть и не multicast
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// проверка адреса получателя: схема https, http только с AllowHTTP,
+// и хост не из внутренней сети. Имя хоста здесь проверяется только на
+// localhost: адрес, в который оно разрешается, проверяет webhookDialControl
+// при каждом подключении
+func (cfg webhookConfig) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if err := cfg.checkScheme(u); err != nil {
+		return err
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errWebhookAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// схема https или http, если она разрешена
+func (cfg webhookConfig) checkScheme(u *url.URL) error {
+	if u.Scheme == "https" || (u.Scheme == "http" && cfg.AllowHTTP) {
+		return nil
+	}
+	return errWebhookScheme
+}
+
+// проверка адреса перед подключением к получателю. Вызывается после
+// разрешения имени, поэтому имя, указывающее на внутреннюю сеть, или
+// подмена DNS между проверками не дают обратиться к внутренним сервисам
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addr) {
+		return fmt.Errorf("%w: %s", errWebhookAddress, addr)
+	}
+	return nil
+}
+
+// клиент доставки: подключается только к адресам в интернете, без прокси
+// из окружения и без перенаправлений, которые увели бы запрос на другой адрес
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// тело запроса с событием
+type webhookPayload struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// параметры записи события в очередь доставки
+func webhookEventParams(event string, data any) (generated.EnqueueWebhookEventParams, error) {
+	var eventParams generated.EnqueueWebhookEventParams
+	payload, err := json.Marshal(webhookPayload{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return eventParams, err
+	}
+	eventParams.Event = event
+	eventParams.Payload = payload
+	return eventParams, nil
+}
+
+// постановка события для всех подписанных вебхуков в транзакции изменения
+// ссылки: событие сохраняется тогда и только тогда, когда сохранено изменение
+func enqueueWebhookEvent(ctx context.Context, tx Store, event string, data any) error {
+	eventParams, err := webhookEventParams(event, data)
+	if err != nil {
+		return failedStep("unable to encode webhook payload", err)
+	}
+	_, err = tx.EnqueueWebhookEvent(ctx, eventParams)
+	return failedStep("unable to enqueue webhook event", err)
+}
+
+// событие для записи в очередь доставки. Пустое событие с flushed
+// только отмечает, что записаны все события до него
+type webhookEvent struct {
+	params  generated.EnqueueWebhookEventParams
+	flushed chan struct{}
+}
+
+// запись событий о переходах в очередь доставки в фоне: перенаправление
+// не ждёт запроса к БД. Остальные события пишутся в транзакции изменения
+type webhookEvents struct {
+	db     Store
+	events chan webhookEvent
+	done   chan struct{}
+	// события, отброшенные из-за переполненного буфера
+	dropped atomic.Int64
+}
+
+func newWebhookEvents(db Store) *webhookEvents {
+	q := &webhookEvents{db: db, events: make(chan webhookEvent, webhookEventBuffer), done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+// постановка события для всех подписанных вебхуков. Ошибка не отменяет
+// уже выполненное действие и только логируется
+func (q *webhookEvents) publish(ctx context.Context, event string, data any) {
+	eventParams, err := webhookEventParams(event, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "unable to encode webhook payload", "event", event, "error", err)
+		return
+	}
+	select {
+	case q.events <- webhookEvent{params: eventParams}:
+	default:
+		q.dropped.Add(1)
+		slog.WarnContext(ctx, "webhook event buffer is full, event dropped", "event", event)
+	}
+}
+
+// запись оставшихся событий при остановке сервиса. После вызова
+// publish недоступен, поэтому он вызывается после остановки HTTP-сервера
+func (q *webhookEvents) close() {
+	close(q.events)
+	<-q.done
+}
+
+// запись событий по одному в порядке поступления
+func (q *webhookEvents) run() {
+	defer close(q.done)
+	for e := range q.events {
+		if e.flushed != nil {
+			close(e.flushed)
+			continue
+		}
+		if _, err := q.db.EnqueueWebhookEvent(context.Background(), e.params); err != nil {
+			slog.Error("unable to enqueue webhook event", "event", e.params.Event, "error", err)
+		}
+	}
+}
+
+// подпись тела запроса: HMAC-SHA256 от "timestamp.body" на секрете вебхука
+func signWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// задержка перед следующей попыткой после attempts неудачных
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return delay
+}
+
+// отправка событий из очереди получателям
+type webhookDispatcher struct {
+	db     Store
+	config webhookConfig
+	client *http.Client
+	// текущее время для подписи и следующей попытки каждой доставки
+	now func() time.Time
+}
+
+func newWebhookDispatcher(db Store, config webhookConfig) *webhookDispatcher {
+	return &webhookDispatcher{db: db, config: config, client: newWebhookClient(), now: time.Now}
+}
+
+// отправка доставок, время которых наступило к now. Возвращает число
+// обработанных доставок. Записи забираются с SKIP LOCKED,
+// поэтому очередь могут разбирать несколько экземпляров сервиса
+func (d *webhookDispatcher) dispatch(ctx context.Context, now time.Time) (int, error) {
+	var claimParams generated.ClaimWebhookDeliveriesParams
+	claimParams.LeaseUntil = pgtype.Timestamptz{Time: now.Add(webhookLease), Valid: true}
+	claimParams.Now = pgtype.Timestamptz{Time: now, Valid: true}
+	claimParams.Limit = webhookBatchSize
+	deliveries, err := d.db.ClaimWebhookDeliveries(ctx, claimParams)
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery, d.now())
+	}
+	return len(deliveries), nil
+}
+
+// одна попытка доставки и сохранение её результата
+func (d *webhookDispatcher) deliver(ctx context.Context, delivery generated.ClaimWebhookDeliveriesRow, now time.Time) {
+	var resultParams generated.CompleteWebhookDeliveryParams
+	resultParams.ID = delivery.ID
+	resultParams.NextAttemptAt = pgtype.Timestamptz{Time: now, Valid: true}
+	status, err := d.send(ctx, delivery, now)
+	if status != 0 {
+		resultParams.ResponseStatus = pgtype.Int4{Int32: int32(status), Valid: true}
+	}
+	attempts := int(delivery.Attempts) + 1
+	switch {
+	case err == nil:
+		resultParams.Status = deliveryDelivered
+	case attempts >= webhookMaxAttempts:
+		resultParams.Status = deliveryFailed
+		resultParams.LastError = textOrNull(truncateString(err.Error(), maxWebhookErrorLength))
+	default:
+		resultParams.Status = deliveryPending
+		resultParams.LastError = textOrNull(truncateString(err.Error(), maxWebhookErrorLength))
+		resultParams.NextAttemptAt = pgtype.Timestamptz{Time: now.Add(webhookRetryDelay(attempts)), Valid: true}
+	}
+	if err := d.db.CompleteWebhookDelivery(ctx, resultParams); err != nil {
+		slog.ErrorContext(ctx, "unable to save webhook delivery result", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// отправка подписанного запроса. Успешной считается доставка с ответом 2xx
+func (d *webhookDispatcher) send(ctx context.Context, delivery generated.ClaimWebhookDeliveriesRow, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	// схема проверяется снова: настройки могли измениться после подписки.
+	// Адрес проверяет клиент при подключении
+	if err := d.config.checkScheme(req.URL); err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// тело ответа не нужно, но дочитывается для повторного использования соединения
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// запуск разбора очереди доставки в фоне
+func startWebhookDispatcher(ctx context.Context, db Store, config webhookConfig, workers *workerRegistry) {
+	dispatcher := newWebhookDispatcher(db, config)
+	runPeriodically(ctx, workers, webhookDispatcherWorker, webhookDispatchInterval, func(_ time.Time) error {
+		// очередь разбирается пачками, пока в ней есть готовые к отправке
+		// записи; каждая пачка забирается на текущее время
+		for {
+			processed, err := dispatcher.dispatch(ctx, time.Now())
+			if err != nil {
+				slog.Error("webhook dispatch failed", "error", err)
+				return err
+			}
+			if processed < webhookBatchSize {
+				return nil
+			}
+		}
+	})
+}
+
+// вебхук для ответа API, секрет не показывается
+type webhookView struct {
+	ID        int64              `json:"id"`
+	Url       string             `json:"url"`
+	Events    []string           `json:"events"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+// структура для валидации подписки на события
+type WebhookRequest struct {
+	Url    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=link.created link.updated link.deleted link.visited"`
+	// секрет подписи, если не указан, генерируется
+	Secret string `json:"secret" binding:"omitempty,min=16,max=128"`
+}
+
+// случайный секрет подписи
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// список вебхуков
+func listWebhooks(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhooks, err := db.ListWebhooks(c.Request.Context())
+		if err != nil {
+			dbError(c, "unable to get webhooks", err)
+			return
+		}
+		views := make([]webhookView, 0, len(webhooks))
+		for _, webhook := range webhooks {
+			views = append(views, webhookView{
+				ID:        webhook.ID,
+				Url:       webhook.Url,
+				Events:    webhook.Events,
+				CreatedAt: webhook.CreatedAt,
+			})
+		}
+		c.JSON(http.StatusOK, views)
+	}
+}
+
+// подписка на события. Секрет возвращается только в ответе на создание
+func createWebhook(db Store, config webhookConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req WebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				errorsMap := make(map[string]string)
+				for _, e := range ve {
+					errorsMap[e.Field()] = e.Tag()
+				}
+				writeValidationProblem(c, errorsMap)
+				return
+			}
+			writeProblem(c, http.StatusBadRequest, codeInvalidRequest, "malformed request body")
+			return
+		}
+		// доставка не должна обращаться к внутренним сервисам
+		switch err := config.checkURL(req.Url); {
+		case errors.Is(err, errWebhookScheme):
+			writeValidationProblem(c, map[string]string{"Url": "https"})
+			return
+		case err != nil:
+			writeValidationProblem(c, map[string]string{"Url": "public_address"})
+			return
+		}
+		secret := req.Secret
+		if secret == "" {
+			var err error
+			secret, err = newWebhookSecret()
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "unable to generate webhook secret", "error", err)
+				writeProblem(c, http.StatusInternalServerError, codeInternal, "unable to generate webhook secret")
+				return
+			}
+		}
+		events := slices.Clone(req.Events)
+		slices.Sort(events)
+		var webhookParams generated.CreateWebhookParams
+		webhookParams.Url = req.Url
+		webhookParams.Secret = secret
+		webhookParams.Events = slices.Compact(events)
+		res, err := db.CreateWebhook(c.Request.Context(), webhookParams)
+		if err != nil {
+			dbError(c, "unable to create webhook", err)
+			return
+		}
+		c.JSON(http.StatusCreated, res)
+	}
+}
+
+// удаление вебхука вместе с журналом доставки
+func deleteWebhook(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		deleted, err := db.DeleteWebhook(c.Request.Context(), id)
+		if err != nil {
+			dbError(c, "unable to delete webhook", err)
+			return
+		}
+		if deleted == 0 {
+			writeProblem(c, http.StatusNotFound, codeWebhookNotFound, "webhook not found")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// доставка для ответа API
+type webhookDeliveryEntry struct {
+	ID             int64              `json:"id"`
+	Event          string             `json:"event"`
+	Payload        json.RawMessage    `json:"payload"`
+	Status         string             `json:"status"`
+	Attempts       int32              `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	ResponseStatus pgtype.Int4        `json:"response_status"`
+	LastError      pgtype.Text        `json:"last_error"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	DeliveredAt    pgtype.Timestamptz `json:"delivered_at"`
+}
+
+// журнал доставки событий вебхука, последние события первыми
+func listWebhookDeliveries(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		page, err := pagination.FromRequest(c.Request, "webhook_deliveries")
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		var paginParams generated.ListWebhookDeliveriesParams
+		paginParams.WebhookID = id
+		paginParams.Limit = int32(page.Limit)
+		paginParams.Offset = int32(page.Offset)
+		rows, err := db.ListWebhookDeliveries(c.Request.Context(), paginParams)
+		if err != nil {
+			dbError(c, "unable to get webhook deliveries", err)
+			return
+		}
+		count, err := db.CounterWebhookDeliveries(c.Request.Context(), id)
+		if err != nil {
+			dbError(c, "unable to count the number of records", err)
+			return
+		}
+		deliveries := make([]webhookDeliveryEntry, 0, len(rows))
+		for _, row := range rows {
+			deliveries = append(deliveries, webhookDeliveryEntry{
+				ID:             row.ID,
+				Event:          row.Event,
+				Payload:        row.Payload,
+				Status:         row.Status,
+				Attempts:       row.Attempts,
+				NextAttemptAt:  row.NextAttemptAt,
+				ResponseStatus: row.ResponseStatus,
+				LastError:      row.LastError,
+				CreatedAt:      row.CreatedAt,
+				DeliveredAt:    row.DeliveredAt,
+			})
+		}
+		writePage(c, page, "webhook_deliveries", deliveries, count)
+	}
+}
+
+// повторная отправка события: доставка снова ставится в очередь
+// с полным числом попыток
+func redeliverWebhookDelivery(db Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "id must be a number")
+			return
+		}
+		deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, codeInvalidParameter, "delivery_id must be a number")
+			return
+		}
+		var redeliverParams generated.RedeliverWebhookDeliveryParams
+		redeliverParams.ID = deliveryID
+		redeliverParams.WebhookID = id
+		updated, err := db.RedeliverWebhookDelivery(c.Request.Context(), redeliverParams)
+		if err != nil {
+			dbError(c, "unable to redeliver webhook event", err)
+			return
+		}
+		if updated == 0 {
+			writeProblem(c, http.StatusNotFound, codeWebhookDeliveryNotFound, "webhook delivery not found")
+			return
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
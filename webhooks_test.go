@@ -0,0 +1,152 @@
+package main
+
+import (
+	generated "code/db/generated"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	signature := signWebhookPayload("0123456789abcdef", 1700000000, []byte(`{"event":"link.created"}`))
+	assert.Equal(t, "sha256=b41285ee9bee31ea12f814f0f16f70a4845bab0720a9ed6c921a6f0a0d1a2a83", signature)
+	assert.NotEqual(t, signature, signWebhookPayload("0123456789abcdef", 1700000001, []byte(`{"event":"link.created"}`)))
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(4))
+	assert.Equal(t, webhookRetryMax, webhookRetryDelay(20))
+}
+
+// ожидание записи всех событий, поставленных до вызова
+func (q *webhookEvents) flush() {
+	flushed := make(chan struct{})
+	q.events <- webhookEvent{flushed: flushed}
+	<-flushed
+}
+
+func TestWebhookCheckURL(t *testing.T) {
+	config := webhookConfig{}
+	assert.NoError(t, config.checkURL("https://crm.example.com/hooks"))
+	assert.NoError(t, config.checkURL("https://93.184.216.34:8443/hooks"))
+	assert.ErrorIs(t, config.checkURL("http://crm.example.com/hooks"), errWebhookScheme)
+	assert.ErrorIs(t, config.checkURL("ftp://crm.example.com/hooks"), errWebhookScheme)
+	assert.NoError(t, webhookConfig{AllowHTTP: true}.checkURL("http://crm.example.com/hooks"))
+	// внутренние адреса
+	for _, target := range []string{
+		"https://localhost/hooks",
+		"https://api.localhost/hooks",
+		"https://127.0.0.1/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5/hooks",
+		"https://192.168.1.1/hooks",
+		"https://100.64.0.1/hooks",
+		"https://[::1]/hooks",
+		"https://[fd00::1]/hooks",
+		"https://[::ffff:127.0.0.1]/hooks",
+		"https://0.0.0.0/hooks",
+	} {
+		assert.ErrorIs(t, config.checkURL(target), errWebhookAddress, target)
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	var requests int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer receiver.Close()
+	// адрес проверяется при подключении, без checkURL
+	_, err := newWebhookClient().Post(receiver.URL, "application/json", nil)
+	assert.ErrorIs(t, err, errWebhookAddress)
+	assert.Zero(t, requests)
+}
+
+// хранилище, которое запоминает события вебхуков
+type recordingWebhookStore struct {
+	Store
+	events []generated.EnqueueWebhookEventParams
+}
+
+func (s *recordingWebhookStore) EnqueueWebhookEvent(ctx context.Context, arg generated.EnqueueWebhookEventParams) (int64, error) {
+	s.events = append(s.events, arg)
+	return 1, nil
+}
+
+func TestWebhookEvents(t *testing.T) {
+	store := &recordingWebhookStore{}
+	events := newWebhookEvents(store)
+	events.publish(context.Background(), eventLinkCreated, map[string]int{"id": 1})
+	events.publish(context.Background(), eventLinkVisited, map[string]int{"link_id": 1})
+	// события записываются в фоне в порядке публикации
+	events.flush()
+	if assert.Len(t, store.events, 2) {
+		assert.Equal(t, eventLinkCreated, store.events[0].Event)
+		assert.Equal(t, eventLinkVisited, store.events[1].Event)
+		var payload webhookPayload
+		assert.NoError(t, json.Unmarshal(store.events[1].Payload, &payload))
+		assert.Equal(t, eventLinkVisited, payload.Event)
+		assert.Equal(t, map[string]any{"link_id": float64(1)}, payload.Data)
+	}
+}
+
+func TestWebhookEventsClose(t *testing.T) {
+	store := &recordingWebhookStore{}
+	events := newWebhookEvents(store)
+	events.publish(context.Background(), eventLinkVisited, map[string]int{"link_id": 1})
+	// при остановке ожидающие события записываются
+	events.close()
+	assert.Len(t, store.events, 1)
+}
+
+func TestWebhookDispatcherUsesCurrentTime(t *testing.T) {
+	// аренда не истекает, пока отправляется целая пачка
+	assert.Greater(t, webhookLease, webhookBatchSize*webhookTimeout)
+
+	var timestamps []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamps = append(timestamps, r.Header.Get(webhookTimestampHeader))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	ctx := context.Background()
+	store := newMemoryStore()
+	var webhookParams generated.CreateWebhookParams
+	webhookParams.Url = receiver.URL
+	webhookParams.Secret = "0123456789abcdef"
+	webhookParams.Events = []string{eventLinkVisited}
+	webhook, err := store.CreateWebhook(ctx, webhookParams)
+	require.NoError(t, err)
+	eventParams, err := webhookEventParams(eventLinkVisited, map[string]int{"link_id": 1})
+	require.NoError(t, err)
+	_, err = store.EnqueueWebhookEvent(ctx, eventParams)
+	require.NoError(t, err)
+
+	// доставка подписывается и откладывается от времени отправки, а не забора пачки
+	claimed := time.Now()
+	sent := claimed.Add(3 * time.Minute)
+	dispatcher := newWebhookDispatcher(store, webhookConfig{AllowHTTP: true})
+	dispatcher.client = receiver.Client()
+	dispatcher.now = func() time.Time { return sent }
+	processed, err := dispatcher.dispatch(ctx, claimed)
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, []string{strconv.FormatInt(sent.Unix(), 10)}, timestamps)
+	var listParams generated.ListWebhookDeliveriesParams
+	listParams.WebhookID = webhook.ID
+	listParams.Limit = 10
+	deliveries, err := store.ListWebhookDeliveries(ctx, listParams)
+	require.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.WithinDuration(t, sent.Add(webhookRetryDelay(1)), deliveries[0].NextAttemptAt.Time, time.Second)
+	}
+}